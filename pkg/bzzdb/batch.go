@@ -0,0 +1,215 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb
+
+import (
+	"encoding/hex"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+// batchWriteConcurrency limits number of keys which are uploaded at the same
+// time when batch is written.
+const batchWriteConcurrency = 32
+
+// batch is a write-only bzzdb batch that buffers changes in memory and
+// uploads them to Swarm when Write is called.
+type batch struct {
+	db     *bzzdb
+	writes []keyValue
+	size   int
+}
+
+type keyValue struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+func (b *batch) Put(key, value []byte) error {
+	value = common.CopyBytes(value)
+	if value == nil {
+		value = []byte{}
+	}
+
+	b.writes = append(b.writes, keyValue{
+		key:   common.CopyBytes(key),
+		value: value,
+	})
+	b.size += len(key) + len(value)
+
+	return nil
+}
+
+func (b *batch) Delete(key []byte) error {
+	b.writes = append(b.writes, keyValue{
+		key:    common.CopyBytes(key),
+		delete: true,
+	})
+	b.size += len(key)
+
+	return nil
+}
+
+func (b *batch) ValueSize() int {
+	return b.size
+}
+
+//nolint:wrapcheck //relax
+func (b *batch) Write() error {
+	writes, err := b.latestWrites()
+	if err != nil {
+		return err
+	}
+
+	if len(writes) == 0 {
+		return nil
+	}
+
+	if b.db.writeBack != nil {
+		kvs := make([]keyValue, len(writes))
+		for i, w := range writes {
+			kvs[i] = keyValue{key: w.key, value: w.value, delete: w.value == nil}
+		}
+
+		return b.db.writeBack.write(kvs)
+	}
+
+	return b.db.trackSync(func() error {
+		return b.upload(writes)
+	})
+}
+
+// upload uploads latest writes of keys and applies them to key index. Writes
+// which succeeded are applied to the index even when other writes failed, so
+// that index stays in sync with keys which can be read.
+//
+//nolint:wrapcheck //relax
+func (b *batch) upload(writes []topicWrite) error {
+	errs := make([]error, len(writes))
+	semC := make(chan struct{}, batchWriteConcurrency)
+
+	var wg sync.WaitGroup
+
+	for i, w := range writes {
+		semC <- struct{}{}
+
+		wg.Add(1)
+
+		go func(i int, w topicWrite) {
+			defer func() {
+				<-semC
+				wg.Done()
+			}()
+
+			if b.db.isContentAddressed(w.key, w.value) {
+				errs[i] = b.db.putContent(w.key, w.value)
+
+				return
+			}
+
+			if err := b.db.markFeedKey(w.key); err != nil {
+				errs[i] = err
+
+				return
+			}
+
+			errs[i] = b.db.writeFeedUpdate(w.topic, b.db.uploadAsync(w.value))
+		}(i, w)
+	}
+
+	wg.Wait()
+
+	var firstErr error
+
+	changes := make([]keyChange, 0, len(writes))
+
+	for i, w := range writes {
+		if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+
+			continue
+		}
+
+		changes = append(changes, keyChange{key: w.key, remove: w.value == nil})
+	}
+
+	if len(changes) > 0 {
+		if err := b.db.keys.apply(changes); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func (b *batch) Reset() {
+	b.writes = b.writes[:0]
+	b.size = 0
+}
+
+//nolint:wrapcheck //relax
+func (b *batch) Replay(w KeyValueWriter) error {
+	for _, kv := range b.writes {
+		if kv.delete {
+			if err := w.Delete(kv.key); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := w.Put(kv.key, kv.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type topicWrite struct {
+	key   []byte
+	topic client.Topic
+	value []byte
+}
+
+// latestWrites returns only last write of every key in the batch. Earlier
+// writes to the same key would be overwritten anyway, so there is no need to
+// upload them.
+func (b *batch) latestWrites() ([]topicWrite, error) {
+	positions := make(map[string]int, len(b.writes))
+	writes := make([]topicWrite, 0, len(b.writes))
+
+	for _, kv := range b.writes {
+		topic, err := makeTopic(kv.key)
+		if err != nil {
+			return nil, err
+		}
+
+		value := kv.value
+		if kv.delete {
+			value = nil
+		}
+
+		w := topicWrite{key: kv.key, topic: topic, value: value}
+		topicKey := hex.EncodeToString(topic)
+
+		if pos, ok := positions[topicKey]; ok {
+			writes[pos] = w
+
+			continue
+		}
+
+		positions[topicKey] = len(writes)
+		writes = append(writes, w)
+	}
+
+	return writes, nil
+}
@@ -9,47 +9,120 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"errors"
+	"fmt"
 	"io"
-	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethersphere/bee/pkg/crypto"
 	"github.com/ethersphere/bee/pkg/swarm"
 
+	"github.com/ethersphere/eth-on-bzz/pkg/chunker"
 	"github.com/ethersphere/eth-on-bzz/pkg/client"
 	"github.com/ethersphere/eth-on-bzz/pkg/postage"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage/stamps"
 )
 
+// stampRetries is number of times upload is retried with another postage
+// batch, when batch turns out to be unusable.
+const stampRetries = 2
+
 //nolint:gochecknoglobals
 var (
 	errBzzDBNotFound = errors.New("not found")
+	errUnknownStat   = errors.New("unknown property")
 
 	zeroSocData = make([]byte, swarm.HashSize)
 	keyPrefix   = []byte("bzzdb-")
 )
 
-//nolint:wrapcheck //relax
 func New(
 	privateKey *ecdsa.PrivateKey,
 	beeCli client.Client,
 	postage postage.Postage,
+	opts ...Option,
 ) (KeyValueStore, error) {
+	return newBzzDB(privateKey, beeCli, postage, opts...)
+}
+
+//nolint:wrapcheck //relax
+func newBzzDB(
+	privateKey *ecdsa.PrivateKey,
+	beeCli client.Client,
+	postage postage.Postage,
+	opts ...Option,
+) (*bzzdb, error) {
 	owner, err := client.OwnerFromKey(privateKey)
 	if err != nil {
 		return nil, err
 	}
 
+	o := makeOptions(opts)
+
+	cache, err := newValueCache(owner, o)
+	if err != nil {
+		return nil, err
+	}
+
+	var indexerOpts []FeedIndexerOption
+	if o.feedIndexStore != nil {
+		indexerOpts = append(indexerOpts, FeedIndexerStore(o.feedIndexStore))
+	}
+
+	indexer := NewFeedIndexer(beeCli, owner, indexerOpts...)
+	if o.epochFeeds {
+		indexer = NewEpochFeedIndexer(beeCli, owner, indexerOpts...)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &bzzdb{
-		privateKey: privateKey,
-		owner:      owner,
-		beeCli:     beeCli,
-		postage:    postage,
-		indexer:    NewFeedIndexer(beeCli, owner),
-		ctx:        ctx,
-		ctxCancel:  cancel,
-	}, nil
+	db := &bzzdb{
+		privateKey:       privateKey,
+		owner:            owner,
+		beeCli:           beeCli,
+		postage:          postage,
+		indexer:          indexer,
+		cache:            cache,
+		contentAddressed: o.contentAddressed,
+		pinning:          o.pinning,
+		usageMeter:       o.usageMeter,
+		ctx:              ctx,
+		ctxCancel:        cancel,
+	}
+	db.keys = newKeyIndex(db, indexPrefix)
+
+	if o.contentAddressed {
+		db.feedKeys = newKeyIndex(db, feedKeysPrefix)
+	}
+
+	if o.pinning && o.unpinOverwritten {
+		db.valuePins, err = newValuePins(ctx, beeCli)
+		if err != nil {
+			cancel()
+			cache.close()
+
+			return nil, err
+		}
+	}
+
+	if o.dedupChunks > 0 {
+		db.dedup = newChunkDedup(o.dedupChunks)
+	}
+
+	if o.syncWait || o.syncReport != nil {
+		db.sync = newSyncTracker(beeCli, o)
+	}
+
+	if o.writeBackPath != "" {
+		db.writeBack, err = newWriteBack(db, o)
+		if err != nil {
+			cancel()
+			cache.close()
+
+			return nil, err
+		}
+	}
+
+	return db, nil
 }
 
 // bzzdb implements ethereum KeyValueStore interface.
@@ -59,6 +132,17 @@ type bzzdb struct {
 	postage    postage.Postage
 	owner      common.Address
 	indexer    *FeedIndexer
+	keys       *keyIndex
+	feedKeys   *keyIndex // nil when content-addressed mode is disabled
+	cache      *valueCache
+	writeBack  *writeBack   // nil when write-back mode is disabled
+	dedup      *chunkDedup  // nil when chunks are not uploaded one by one
+	sync       *syncTracker // nil when sync of writes is not tracked
+	usageMeter *postage.UsageMeter
+	valuePins  *valuePins // nil when overwritten values are not unpinned
+
+	contentAddressed bool
+	pinning          bool
 
 	//nolint:containedctx // this ctx is need because methods of KeyValueStore
 	// interface do not pass down context. Single context is created in New method
@@ -69,8 +153,7 @@ type bzzdb struct {
 
 func (db *bzzdb) Has(key []byte) (bool, error) {
 	if _, err := db.Get(key); err != nil {
-		if errors.Is(err, errBzzDBNotFound) ||
-			errors.Is(err, client.ErrNotFound) {
+		if isNotFound(err) {
 			return false, nil
 		}
 
@@ -82,51 +165,189 @@ func (db *bzzdb) Has(key []byte) (bool, error) {
 
 //nolint:wrapcheck //relax
 func (db *bzzdb) Get(key []byte) ([]byte, error) {
+	if db.writeBack != nil {
+		if value, deleted, ok := db.writeBack.get(key); ok {
+			if deleted {
+				return nil, errBzzDBNotFound
+			}
+
+			return value, nil
+		}
+	}
+
+	if db.contentAddressed && len(key) == swarm.HashSize {
+		value, err := db.getContent(key)
+		if !isNotFound(err) {
+			return value, err
+		}
+	}
+
 	topic, err := makeTopic(key)
 	if err != nil {
 		return nil, err
 	}
 
-	index, exists, err := db.indexer.Current(db.ctx, topic)
+	return db.getTopic(topic)
+}
+
+// GetReader returns reader of the value of the key. Value which is not
+// buffered or cached is streamed from Swarm without being held in memory,
+// and it is not added to the cache.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) GetReader(key []byte) (io.ReadCloser, error) {
+	if db.writeBack != nil {
+		if value, deleted, ok := db.writeBack.get(key); ok {
+			if deleted {
+				return nil, errBzzDBNotFound
+			}
+
+			return io.NopCloser(bytes.NewReader(value)), nil
+		}
+	}
+
+	if db.contentAddressed && len(key) == swarm.HashSize {
+		value, err := db.getContent(key)
+		if err == nil {
+			return io.NopCloser(bytes.NewReader(value)), nil
+		}
+
+		if !isNotFound(err) {
+			return nil, err
+		}
+	}
+
+	topic, err := makeTopic(key)
 	if err != nil {
 		return nil, err
 	}
 
-	if !exists {
-		return nil, errBzzDBNotFound
+	_, ref, value, err := db.topicValue(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref.IsZero() {
+		return io.NopCloser(bytes.NewReader(value)), nil
+	}
+
+	return db.beeCli.DownloadBytes(db.ctx, ref)
+}
+
+// getTopic downloads value referenced by the latest feed update of the topic.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) getTopic(topic client.Topic) ([]byte, error) {
+	index, ref, value, err := db.topicValue(topic)
+	if err != nil || ref.IsZero() {
+		return value, err
 	}
 
-	ref, err := client.FeedUpdateReference(db.owner, topic, index)
+	value, err = db.downloadAndRead(db.beeCli.DownloadBytes, ref)
 	if err != nil {
 		return nil, err
 	}
 
+	db.cache.put(topic, index, value)
+
+	return value, nil
+}
+
+// topicValue returns index of the latest feed update of the topic, and either
+// reference of the value, or the value itself when it is cached. Reference is
+// zero when value is returned.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) topicValue(topic client.Topic) (Index, swarm.Address, []byte, error) {
+	index, exists, err := db.indexer.Current(db.ctx, topic)
+	if err != nil {
+		return 0, swarm.ZeroAddress, nil, err
+	}
+
+	if !exists {
+		return 0, swarm.ZeroAddress, nil, errBzzDBNotFound
+	}
+
+	if value, deleted, ok := db.cache.get(topic, index); ok {
+		if deleted {
+			return 0, swarm.ZeroAddress, nil, errBzzDBNotFound
+		}
+
+		return index, swarm.ZeroAddress, value, nil
+	}
+
+	ref, err := db.updateValueRef(topic, index)
+	if err != nil {
+		return 0, swarm.ZeroAddress, nil, err
+	}
+
+	if ref.IsZero() {
+		db.cache.put(topic, index, nil)
+
+		return 0, swarm.ZeroAddress, nil, errBzzDBNotFound
+	}
+
+	return index, ref, nil, nil
+}
+
+// updateValueRef downloads feed update of the topic and returns reference of
+// the value it points to. Reference is zero when update marks the topic as
+// deleted.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) updateValueRef(topic client.Topic, index Index) (swarm.Address, error) {
+	ref, err := db.indexer.UpdateReference(topic, index)
+	if err != nil {
+		return swarm.ZeroAddress, err
+	}
+
 	respData, err := db.downloadAndRead(db.beeCli.DownloadChunk, swarm.NewAddress(ref))
 	if err != nil {
-		return nil, err
+		return swarm.ZeroAddress, err
 	}
 
 	respData = client.PayloadStripTime(client.RawDataFromSocResp(respData))
 
 	if bytes.Equal(respData, zeroSocData) {
-		return nil, errBzzDBNotFound
+		return swarm.ZeroAddress, nil
 	}
 
-	respData, err = db.downloadAndRead(db.beeCli.DownloadBytes, swarm.NewAddress(respData))
-	if err != nil {
-		return nil, err
+	return swarm.NewAddress(respData), nil
+}
+
+func (db *bzzdb) Put(key []byte, value []byte) error {
+	// nil value is reserved for deleted keys, while ethdb stores it as
+	// empty value.
+	if value == nil {
+		value = []byte{}
+	}
+
+	if db.writeBack != nil {
+		return db.writeBack.write([]keyValue{{
+			key:   common.CopyBytes(key),
+			value: common.CopyBytes(value),
+		}})
 	}
 
-	return respData, nil
+	return db.writeKey(key, value)
+}
+
+// writeKey uploads the value of the key and updates key index. nil value
+// deletes the key.
+func (db *bzzdb) writeKey(key []byte, value []byte) error {
+	return db.trackSync(func() error {
+		return db.uploadKey(key, value)
+	})
 }
 
 //nolint:wrapcheck //relax
-func (db *bzzdb) Put(key []byte, value []byte) error {
-	uploadRespC := db.uploadAsync(value)
+func (db *bzzdb) uploadKey(key []byte, value []byte) error {
+	if db.isContentAddressed(key, value) {
+		if err := db.putContent(key, value); err != nil {
+			return err
+		}
 
-	batchID, err := db.postage.CurrentBatchID(db.ctx)
-	if err != nil {
-		return err
+		return db.keys.add(key)
 	}
 
 	topic, err := makeTopic(key)
@@ -134,46 +355,166 @@ func (db *bzzdb) Put(key []byte, value []byte) error {
 		return err
 	}
 
-	index, err := db.indexer.AcquireNext(db.ctx, topic)
-	if err != nil {
+	if err := db.markFeedKey(key); err != nil {
 		return err
 	}
 
-	defer db.indexer.Release(topic, index)
+	if err := db.putTopic(topic, value); err != nil {
+		return err
+	}
+
+	if value == nil {
+		return db.keys.remove(key)
+	}
+
+	return db.keys.add(key)
+}
+
+// putTopic uploads the value and points new feed update of the topic to it.
+// nil value marks the topic as deleted.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) putTopic(topic client.Topic, value []byte) error {
+	return db.writeFeedUpdate(topic, db.uploadAsync(value))
+}
 
-	socID, err := client.FeedID(topic, index)
+// writeFeedUpdate uploads next feed update for the topic, pointing to the
+// reference received from uploadRespC.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) writeFeedUpdate(
+	topic client.Topic,
+	uploadRespC <-chan uploadResp,
+) error {
+	reservation, err := db.indexer.Reserve(db.ctx, topic)
 	if err != nil {
 		return err
 	}
 
+	// Abort has no effect when reservation is committed or abandoned.
+	defer reservation.Abort()
+
 	uploadResp := <-uploadRespC
 	if err := uploadResp.err; err != nil {
 		return err
 	}
 
-	payload := client.PayloadWithTime(uploadResp.ref.Bytes(), time.Unix(0, 0))
+	if err := db.uploadUpdate(topic, reservation.Index(), uploadResp.ref); err != nil {
+		if !isRejected(err) {
+			reservation.Abandon()
+		}
 
-	data, sig, err := client.SignSocData(socID, payload, db.privateKey)
+		return err
+	}
+
+	reservation.Commit()
+
+	if db.valuePins != nil {
+		db.unpinPrevious(topic, reservation, uploadResp.ref)
+	}
+
+	return nil
+}
+
+// uploadUpdate signs and uploads feed update of the topic at the index,
+// pointing to the reference.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) uploadUpdate(topic client.Topic, index Index, ref swarm.Address) error {
+	socID, err := db.indexer.FeedID(topic, index)
 	if err != nil {
 		return err
 	}
 
-	_, err = db.beeCli.UploadSoc(db.ctx, db.owner, socID, data, sig, batchID)
+	payload := client.PayloadWithTime(ref.Bytes(), db.indexer.UpdateTime(index))
+
+	data, sig, err := client.SignSocData(socID, payload, db.privateKey)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return db.uploadSoc(socID, data, sig)
 }
 
 func (db *bzzdb) Delete(key []byte) error {
-	return db.Put(key, nil)
+	if db.writeBack != nil {
+		return db.writeBack.write([]keyValue{{
+			key:    common.CopyBytes(key),
+			delete: true,
+		}})
+	}
+
+	return db.writeKey(key, nil)
+}
+
+func (db *bzzdb) NewIterator(prefix []byte, start []byte) Iterator {
+	return newIterator(db, prefix, start)
+}
+
+func (db *bzzdb) NewBatch() Batch {
+	return &batch{db: db}
+}
+
+func (db *bzzdb) NewBatchWithSize(size int) Batch {
+	return &batch{db: db}
+}
+
+// Stat returns statistics of the value cache, chunk deduplication and
+// pinning. Supported properties are bzzdb.cache.hits, bzzdb.cache.diskhits,
+// bzzdb.cache.misses and bzzdb.cache.size, bzzdb.dedup.uploaded and
+// bzzdb.dedup.skipped when chunk deduplication is enabled, and
+// bzzdb.pins.unpinfailed, which is number of values that could not be
+// unpinned, when overwritten values are unpinned.
+func (db *bzzdb) Stat(property string) (string, error) {
+	if value, ok := db.cache.stat(property); ok {
+		return value, nil
+	}
+
+	if db.valuePins != nil {
+		if value, ok := db.valuePins.stat(property); ok {
+			return value, nil
+		}
+	}
+
+	if db.dedup != nil {
+		if value, ok := db.dedup.stat(property); ok {
+			return value, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", errUnknownStat, property)
 }
 
+// Flush waits until all writes buffered by write-back mode are uploaded to
+// Swarm. It returns immediately when write-back mode is not enabled.
+func (db *bzzdb) Flush(ctx context.Context) error {
+	if db.writeBack == nil {
+		return nil
+	}
+
+	return db.writeBack.flush(ctx)
+}
+
+// Close waits for pending writes to be uploaded before closing the database,
+// at most for close timeout of write-back mode.
 func (db *bzzdb) Close() error {
+	var writeBackErr error
+	if db.writeBack != nil {
+		writeBackErr = db.writeBack.close()
+	}
+
+	db.indexer.Close()
 	db.ctxCancel()
 
-	return nil
+	if db.sync != nil {
+		db.sync.close()
+	}
+
+	if err := db.cache.close(); err != nil {
+		return err
+	}
+
+	return writeBackErr
 }
 
 type uploadResp struct {
@@ -191,24 +532,200 @@ func (db *bzzdb) uploadAsync(value []byte) <-chan uploadResp {
 	}
 
 	go func() {
-		batchID, err := db.postage.CurrentBatchID(db.ctx)
-		if err != nil {
-			respC <- uploadResp{err: err}
+		ref, err := db.uploadBytes(value)
+		respC <- uploadResp{ref: ref, err: err}
+	}()
 
-			return
+	return respC
+}
+
+// uploadBytes uploads value stamped with current postage batch, and pins it
+// when pinning is enabled.
+func (db *bzzdb) uploadBytes(value []byte) (swarm.Address, error) {
+	upload := db.uploadWhole
+	if db.dedup != nil {
+		upload = db.uploadChunks
+	}
+
+	ref, err := upload(value)
+	if err != nil {
+		return swarm.ZeroAddress, err
+	}
+
+	return ref, db.pin(ref)
+}
+
+// uploadWhole uploads value at once via /bytes endpoint.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) uploadWhole(value []byte) (swarm.Address, error) {
+	var ref swarm.Address
+
+	err := db.withBatch(func(batchID client.BatchID) (int, error) {
+		resp, err := db.beeCli.UploadBytes(db.uploadCtx(), value, batchID)
+		ref = resp.Reference
+
+		return stamps.ChunkCount(len(value)), err
+	})
+
+	return ref, err
+}
+
+// uploadStream uploads data read from the reader via streaming /bytes
+// endpoint, so that it is not buffered by the client, and pins it when
+// pinning is enabled. Reader is rewound when upload is retried with another
+// batch.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) uploadStream(reader io.ReadSeeker, size int64) (swarm.Address, error) {
+	var ref swarm.Address
+
+	err := db.withBatch(func(batchID client.BatchID) (int, error) {
+		if _, err := reader.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+
+		resp, err := db.beeCli.UploadBytesStream(db.uploadCtx(), reader, size, batchID, nil)
+		ref = resp.Reference
+
+		return stamps.ChunkCount(int(size)), err
+	})
+	if err != nil {
+		return swarm.ZeroAddress, err
+	}
+
+	return ref, db.pin(ref)
+}
+
+// uploadChunks splits value into chunks locally and uploads only chunks
+// which were not uploaded with current postage batch before.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) uploadChunks(value []byte) (swarm.Address, error) {
+	root, chunks, err := chunker.Chunks(value)
+	if err != nil {
+		return swarm.ZeroAddress, err
+	}
+
+	err = db.withBatch(func(batchID client.BatchID) (int, error) {
+		pending := db.dedup.filter(batchID, chunks)
+		if err := db.pushChunks(batchID, pending); err != nil {
+			return 0, err
 		}
 
-		resp, err := db.beeCli.UploadBytes(db.ctx, value, batchID)
+		db.dedup.add(batchID, pending)
+
+		return len(pending), nil
+	})
+
+	return root, err
+}
+
+// pushChunks uploads chunks stamped with the batch. Multiple chunks are
+// uploaded over single stream.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) pushChunks(batchID client.BatchID, chunks []swarm.Chunk) error {
+	switch len(chunks) {
+	case 0:
+		return nil
+	case 1:
+		_, err := db.beeCli.UploadChunk(db.uploadCtx(), chunks[0].Data(), batchID)
+
+		return err
+	}
+
+	stream, err := db.beeCli.UploadChunkStream(db.uploadCtx(), batchID)
+	if err != nil {
+		return err
+	}
+
+	defer stream.Close()
+
+	for _, ch := range chunks {
+		if err := stream.UploadChunk(ch.Data()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// uploadSoc uploads Single Owner Chunk stamped with current postage batch,
+// and pins it when pinning is enabled.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) uploadSoc(id client.SocID, data []byte, sig client.SocSignature) error {
+	var ref swarm.Address
+
+	err := db.withBatch(func(batchID client.BatchID) (int, error) {
+		resp, err := db.beeCli.UploadSoc(db.uploadCtx(), db.owner, id, data, sig, batchID)
+		ref = resp.Reference
+
+		return 1, err
+	})
+	if err != nil {
+		return err
+	}
+
+	return db.pin(ref)
+}
+
+// trackSync calls write, which uploads data, and follows sync of the data
+// when sync of writes is tracked.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) trackSync(write func() error) error {
+	if db.sync == nil {
+		return write()
+	}
+
+	w, err := db.sync.begin(db.ctx)
+	if err != nil {
+		return err
+	}
+
+	return db.sync.end(db.ctx, w, write())
+}
+
+// uploadCtx returns context of uploads, which tags them when sync of writes is
+// tracked.
+func (db *bzzdb) uploadCtx() context.Context {
+	if db.sync == nil {
+		return db.ctx
+	}
+
+	return db.sync.context(db.ctx)
+}
+
+// withBatch calls upload with current postage batch, which returns number of
+// chunks stamped with the batch. When upload fails because the batch is not
+// usable anymore, the batch is invalidated and upload is retried with another
+// batch.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) withBatch(upload func(client.BatchID) (int, error)) error {
+	for attempt := 0; ; attempt++ {
+		batchID, err := db.postage.CurrentBatchID(db.ctx)
 		if err != nil {
-			respC <- uploadResp{err: err}
+			return err
+		}
 
-			return
+		chunks, err := upload(batchID)
+		if err == nil && db.usageMeter != nil {
+			db.usageMeter.Add(batchID, chunks)
 		}
 
-		respC <- uploadResp{ref: resp.Reference}
-	}()
+		if !errors.Is(err, client.ErrStampUnusable) {
+			return err
+		}
 
-	return respC
+		db.postage.Invalidate(batchID)
+
+		if attempt == stampRetries {
+			return err
+		}
+	}
 }
 
 type downloadFn = func(context.Context, swarm.Address) (io.ReadCloser, error)
@@ -220,19 +737,49 @@ func (db *bzzdb) downloadAndRead(downloadFn downloadFn, addr swarm.Address) ([]b
 		return nil, err
 	}
 
-	respData, err := io.ReadAll(respReader)
+	defer respReader.Close()
+
+	return io.ReadAll(respReader)
+}
+
+// downloadRange reads length bytes of data with the address, starting at
+// offset, into buffer of that length.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) downloadRange(addr swarm.Address, offset, length int64) ([]byte, error) {
+	reader, err := db.beeCli.DownloadBytesRange(db.ctx, addr, offset, length)
 	if err != nil {
 		return nil, err
 	}
-	defer respReader.Close()
 
-	return respData, nil
+	defer reader.Close()
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// isRejected tells whether upload failed because the node rejected it, so
+// the data was definitely not stored.
+func isRejected(err error) bool {
+	return errors.Is(err, client.ErrStampUnusable) || errors.Is(err, client.ErrRejected)
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, errBzzDBNotFound) || errors.Is(err, client.ErrNotFound)
 }
 
-//nolint:wrapcheck //relax
 func makeTopic(key []byte) (client.Topic, error) {
-	data := make([]byte, 0, len(key)+len(keyPrefix))
-	data = append(data, keyPrefix...)
+	return makePrefixedTopic(keyPrefix, key)
+}
+
+//nolint:wrapcheck //relax
+func makePrefixedTopic(prefix, key []byte) (client.Topic, error) {
+	data := make([]byte, 0, len(key)+len(prefix))
+	data = append(data, prefix...)
 	data = append(data, key...)
 
 	return crypto.LegacyKeccak256(data)
@@ -35,26 +35,57 @@ func Test_BzzDB_Integration(t *testing.T) {
 		NodeURL: getEnv(t, envNodeAddress),
 	})
 
+	// All databases share the same owner, so keys left on the node by
+	// previous databases are deleted before the database is handed out.
 	newBzzDB := func() bzzdb.KeyValueStore {
 		db, err := bzzdb.New(privateKey, beeCli, postage.New(beeCli))
 		assert.NoError(t, err)
 
+		clearDB(t, db)
+
 		return db
 	}
 
 	dbtest.TestDatabaseSuite(t, newBzzDB)
 }
 
+// getPrivateKey returns key provided by env variable, or new key if variable
+// is not set.
 func getPrivateKey(t *testing.T) *ecdsa.PrivateKey {
 	t.Helper()
 
-	keyRaw := getEnv(t, envPrivateKey)
+	keyRaw := os.Getenv(envPrivateKey)
+	if keyRaw == "" {
+		key, err := crypto.GenerateSecp256k1Key()
+		assert.NoError(t, err)
+
+		return key
+	}
+
 	key, err := crypto.DecodeSecp256k1PrivateKey([]byte(keyRaw))
 	assert.NoError(t, err)
 
 	return key
 }
 
+func clearDB(t *testing.T, db bzzdb.KeyValueStore) {
+	t.Helper()
+
+	var keys [][]byte
+
+	it := db.NewIterator(nil, nil)
+	for it.Next() {
+		keys = append(keys, append([]byte(nil), it.Key()...))
+	}
+
+	assert.NoError(t, it.Error())
+	it.Release()
+
+	for _, key := range keys {
+		assert.NoError(t, db.Delete(key))
+	}
+}
+
 func getEnv(t *testing.T, env string) string {
 	val := os.Getenv(env)
 	if val == "" {
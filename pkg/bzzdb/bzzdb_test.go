@@ -5,18 +5,72 @@
 package bzzdb_test
 
 import (
+	"context"
+	"fmt"
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethersphere/bee/pkg/crypto"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb"
-	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb/dbtest"
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
 	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
 	"github.com/ethersphere/eth-on-bzz/pkg/postage"
 )
 
-func TestBzzDB(t *testing.T) {
+func Test_BzzDBSuite(t *testing.T) {
+	t.Parallel()
+
+	runSuite(t, mock.NewClient(), nil)
+}
+
+func Test_EpochFeedsSuite(t *testing.T) {
+	t.Parallel()
+
+	runSuite(t, mock.NewClient(), func() []bzzdb.Option {
+		return []bzzdb.Option{bzzdb.WithEpochFeeds()}
+	})
+}
+
+func Test_FailedPut(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := &failingClient{Client: mock.NewClient()}
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp)
+	assert.NoError(t, err)
+
+	key := []byte("key")
+
+	assert.NoError(t, db.Put(key, []byte("value-1")))
+
+	// Failed put does not change current value
+	beeCli.fail.Store(true)
+	assert.ErrorIs(t, db.Put(key, []byte("value-2")), errUploadFailed)
+	assert.ErrorIs(t, db.Delete(key), errUploadFailed)
+	assertGet(t, db, key, []byte("value-1"))
+
+	// Failed puts do not leave gaps in the feed, so the latest value is found
+	// by new instance of database
+	beeCli.fail.Store(false)
+	assert.NoError(t, db.Put(key, []byte("value-3")))
+	assertGet(t, db, key, []byte("value-3"))
+
+	reopened, err := bzzdb.New(privateKey, beeCli, stamp)
+	assert.NoError(t, err)
+	assertGet(t, reopened, key, []byte("value-3"))
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, reopened.Close())
+}
+
+func Test_PostageRotation(t *testing.T) {
 	t.Parallel()
 
 	privateKey, err := crypto.GenerateSecp256k1Key()
@@ -24,12 +78,82 @@ func TestBzzDB(t *testing.T) {
 
 	beeCli := mock.NewClient()
 
-	newBzzDB := func() bzzdb.KeyValueStore {
-		db, err := bzzdb.New(privateKey, beeCli, postage.New(beeCli))
-		assert.NoError(t, err)
+	// Batch of the smallest depth holds only two chunks, so it saturates
+	// after the first put.
+	_, err = beeCli.BuyStamp(context.Background(), big.NewInt(1), 17, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	db, err := bzzdb.New(privateKey, beeCli, postage.New(beeCli))
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		value := []byte(fmt.Sprintf("value-%d", i))
 
-		return db
+		assert.NoError(t, db.Put(key, value))
+		assertGet(t, db, key, value)
 	}
 
-	dbtest.TestDatabaseSuite(t, newBzzDB)
+	stamps, err := beeCli.Stamps(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, stamps.Stamps, 2)
+
+	assert.NoError(t, db.Close())
+}
+
+func Test_UsageMeter(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := mock.NewClient()
+	stamp := buyStamp(t, beeCli)
+	meter := postage.NewUsageMeter(time.Minute)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithUsageMeter(meter))
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.Put([]byte("key"), []byte("value")))
+	assert.Greater(t, meter.Rate(client.BatchID(stamp)), 0.0)
+
+	assert.NoError(t, db.Close())
+}
+
+func Test_FailedPutStored(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := &failingClient{Client: mock.NewClient()}
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp)
+	assert.NoError(t, err)
+
+	key := []byte("key")
+
+	assert.NoError(t, db.Put(key, []byte("value-1")))
+
+	// Update is stored, although upload fails
+	beeCli.failStored.Store(true)
+	assert.ErrorIs(t, db.Put(key, []byte("value-2")), errUploadFailed)
+
+	// Index of update which might have been stored is not reused
+	beeCli.failStored.Store(false)
+	assert.NoError(t, db.Put(key, []byte("value-3")))
+	assertGet(t, db, key, []byte("value-3"))
+
+	owner, err := client.OwnerFromKey(privateKey)
+	assert.NoError(t, err)
+
+	topic, err := crypto.LegacyKeccak256(append([]byte("bzzdb-"), key...))
+	assert.NoError(t, err)
+
+	resp, err := beeCli.FeedIndexLatest(context.Background(), owner, topic)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), resp.Current)
+
+	assert.NoError(t, db.Close())
 }
@@ -0,0 +1,241 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+const (
+	// Cache entry on disk is stored as feed index (8 bytes), followed by
+	// deleted flag (1 byte) and value.
+	diskEntryHeaderSize = 9
+	diskEntryDeleted    = 1
+)
+
+// valueCache caches values of topics together with the feed index they were
+// read from. Entry is valid only while its index equals the current index
+// known by FeedIndexer, so writes invalidate entries without any additional
+// bookkeeping.
+//
+// Disk cache keys are prefixed with owner of the database, so that directory
+// reused by database of another owner does not serve its values.
+//
+// Cache is best effort: failures of disk cache are treated as cache misses.
+type valueCache struct {
+	maxSize int
+	disk    *leveldb.DB
+	owner   common.Address
+
+	lock    sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+
+	hits     atomic.Uint64
+	diskHits atomic.Uint64
+	misses   atomic.Uint64
+}
+
+type cacheEntry struct {
+	key     string
+	index   Index
+	value   []byte
+	deleted bool
+}
+
+func newValueCache(owner common.Address, opts options) (*valueCache, error) {
+	c := &valueCache{
+		maxSize: opts.cacheSize,
+		owner:   owner,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+
+	if opts.cachePath != "" {
+		disk, err := leveldb.OpenFile(opts.cachePath, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed opening disk cache: %w", err)
+		}
+
+		c.disk = disk
+	}
+
+	return c, nil
+}
+
+func (c *valueCache) enabled() bool {
+	return c.maxSize > 0 || c.disk != nil
+}
+
+// get returns cached value of the topic if it was read from feed update with
+// the index. Deleted topics are cached as well, so second return value
+// reports whether value was deleted.
+func (c *valueCache) get(topic client.Topic, index Index) ([]byte, bool, bool) {
+	if !c.enabled() {
+		return nil, false, false
+	}
+
+	key := string(topic)
+
+	if value, deleted, ok := c.getMemory(key, index); ok {
+		c.hits.Add(1)
+
+		return value, deleted, true
+	}
+
+	if value, deleted, ok := c.getDisk(key, index); ok {
+		c.diskHits.Add(1)
+		c.putMemory(key, index, value, deleted)
+
+		return common.CopyBytes(value), deleted, true
+	}
+
+	c.misses.Add(1)
+
+	return nil, false, false
+}
+
+// put caches value of the topic read from feed update with the index. nil
+// value marks the topic as deleted.
+func (c *valueCache) put(topic client.Topic, index Index, value []byte) {
+	if !c.enabled() {
+		return
+	}
+
+	key := string(topic)
+	deleted := value == nil
+	value = common.CopyBytes(value)
+
+	c.putMemory(key, index, value, deleted)
+	c.putDisk(key, index, value, deleted)
+}
+
+func (c *valueCache) getMemory(key string, index Index) ([]byte, bool, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false, false
+	}
+
+	entry, _ := elem.Value.(*cacheEntry)
+	if entry.index != index {
+		c.remove(elem)
+
+		return nil, false, false
+	}
+
+	c.lru.MoveToFront(elem)
+
+	return common.CopyBytes(entry.value), entry.deleted, true
+}
+
+func (c *valueCache) putMemory(key string, index Index, value []byte, deleted bool) {
+	size := len(key) + len(value)
+	if size > c.maxSize {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:     key,
+		index:   index,
+		value:   value,
+		deleted: deleted,
+	})
+	c.size += size
+
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove must be called with lock held.
+func (c *valueCache) remove(elem *list.Element) {
+	entry, _ := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= len(entry.key) + len(entry.value)
+}
+
+func (c *valueCache) getDisk(key string, index Index) ([]byte, bool, bool) {
+	if c.disk == nil {
+		return nil, false, false
+	}
+
+	data, err := c.disk.Get(c.diskKey(key), nil)
+	if err != nil || len(data) < diskEntryHeaderSize {
+		return nil, false, false
+	}
+
+	if binary.BigEndian.Uint64(data) != index {
+		return nil, false, false
+	}
+
+	return data[diskEntryHeaderSize:], data[8] == diskEntryDeleted, true
+}
+
+func (c *valueCache) putDisk(key string, index Index, value []byte, deleted bool) {
+	if c.disk == nil {
+		return
+	}
+
+	data := make([]byte, diskEntryHeaderSize, diskEntryHeaderSize+len(value))
+	binary.BigEndian.PutUint64(data, index)
+
+	if deleted {
+		data[8] = diskEntryDeleted
+	}
+
+	_ = c.disk.Put(c.diskKey(key), append(data, value...), nil)
+}
+
+func (c *valueCache) diskKey(key string) []byte {
+	return append(c.owner.Bytes(), key...)
+}
+
+// stat returns value of cache statistics property.
+func (c *valueCache) stat(property string) (string, bool) {
+	switch property {
+	case "bzzdb.cache.hits":
+		return fmt.Sprint(c.hits.Load()), true
+	case "bzzdb.cache.diskhits":
+		return fmt.Sprint(c.diskHits.Load()), true
+	case "bzzdb.cache.misses":
+		return fmt.Sprint(c.misses.Load()), true
+	case "bzzdb.cache.size":
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		return fmt.Sprint(c.size), true
+	}
+
+	return "", false
+}
+
+//nolint:wrapcheck //relax
+func (c *valueCache) close() error {
+	if c.disk == nil {
+		return nil
+	}
+
+	return c.disk.Close()
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb_test
+
+import (
+	"testing"
+
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage"
+)
+
+func Test_CacheSuite(t *testing.T) {
+	t.Parallel()
+
+	runSuite(t, mock.NewClient(), func() []bzzdb.Option {
+		return []bzzdb.Option{
+			bzzdb.WithCache(1 << 20),
+			bzzdb.WithDiskCache(t.TempDir()),
+		}
+	})
+}
+
+func Test_Cache(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := &countingClient{Client: mock.NewClient()}
+	stamp := postage.New(beeCli)
+	cachePath := t.TempDir()
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp,
+		bzzdb.WithCache(1<<20),
+		bzzdb.WithDiskCache(cachePath),
+	)
+	assert.NoError(t, err)
+
+	key := []byte("key")
+
+	assert.NoError(t, db.Put(key, []byte("value-1")))
+	assertGet(t, db, key, []byte("value-1"))
+	assertGet(t, db, key, []byte("value-1"))
+	assert.Equal(t, int64(1), beeCli.downloads.Load())
+	assertStat(t, db, "bzzdb.cache.hits", "1")
+	assertStat(t, db, "bzzdb.cache.misses", "1")
+
+	// Writing new value invalidates cached value
+	assert.NoError(t, db.Put(key, []byte("value-2")))
+	assertGet(t, db, key, []byte("value-2"))
+	assertGet(t, db, key, []byte("value-2"))
+	assert.Equal(t, int64(2), beeCli.downloads.Load())
+
+	// Deleted keys are cached as well
+	assert.NoError(t, db.Delete(key))
+
+	for i := 0; i < 2; i++ {
+		_, err = db.Get(key)
+		assert.Error(t, err)
+	}
+
+	assert.Equal(t, int64(3), beeCli.downloads.Load())
+	assertStat(t, db, "bzzdb.cache.hits", "3")
+	assertStat(t, db, "bzzdb.cache.misses", "3")
+
+	assert.NoError(t, db.Put(key, []byte("value-3")))
+	assertGet(t, db, key, []byte("value-3"))
+	assert.NoError(t, db.Close())
+
+	// Values are read from disk cache by new instance of database
+	db, err = bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithDiskCache(cachePath))
+	assert.NoError(t, err)
+
+	downloads := beeCli.downloads.Load()
+
+	assertGet(t, db, key, []byte("value-3"))
+	assert.Equal(t, downloads, beeCli.downloads.Load())
+	assertStat(t, db, "bzzdb.cache.diskhits", "1")
+
+	_, err = db.Stat("unknown")
+	assert.Error(t, err)
+
+	assert.NoError(t, db.Close())
+}
+
+func Test_CacheOwner(t *testing.T) {
+	t.Parallel()
+
+	beeCli := mock.NewClient()
+	stamp := postage.New(beeCli)
+	cachePath := t.TempDir()
+	key := []byte("key")
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithDiskCache(cachePath))
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.Put(key, []byte("value-1")))
+	assertGet(t, db, key, []byte("value-1"))
+	assert.NoError(t, db.Close())
+
+	// Database of another owner reusing the directory does not read values
+	// cached by the first one, even when its feed is at the same index.
+	otherKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	db, err = bzzdb.New(otherKey, beeCli, stamp, bzzdb.WithDiskCache(cachePath))
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.Put(key, []byte("value-2")))
+	assertGet(t, db, key, []byte("value-2"))
+	assertStat(t, db, "bzzdb.cache.diskhits", "0")
+	assert.NoError(t, db.Close())
+}
+
+func Test_CacheEviction(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := &countingClient{Client: mock.NewClient()}
+
+	// Cache fits only one value
+	db, err := bzzdb.New(privateKey, beeCli, postage.New(beeCli), bzzdb.WithCache(48))
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.Put([]byte("a"), []byte("value-a")))
+	assert.NoError(t, db.Put([]byte("b"), []byte("value-b")))
+
+	assertGet(t, db, []byte("a"), []byte("value-a"))
+	assertGet(t, db, []byte("b"), []byte("value-b"))
+	assertGet(t, db, []byte("a"), []byte("value-a"))
+	assert.Equal(t, int64(3), beeCli.downloads.Load())
+	assertStat(t, db, "bzzdb.cache.hits", "0")
+
+	assertGet(t, db, []byte("a"), []byte("value-a"))
+	assert.Equal(t, int64(3), beeCli.downloads.Load())
+	assertStat(t, db, "bzzdb.cache.hits", "1")
+
+	assert.NoError(t, db.Close())
+}
@@ -0,0 +1,208 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/ethersphere/bee/pkg/swarm"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+// Content chunk payload starts with one byte, which tells whether value is
+// stored inline or payload holds reference to the value.
+const (
+	contentInline byte = iota
+	contentReference
+)
+
+//nolint:gochecknoglobals
+var (
+	errCorruptContent = errors.New("corrupt content-addressed value")
+
+	contentPrefix = []byte("bzzdb.content-")
+)
+
+// isContentAddressed reports whether value is stored in content-addressed
+// mode, which is the case when the mode is enabled and key is keccak256 hash
+// of the value.
+func (db *bzzdb) isContentAddressed(key, value []byte) bool {
+	if !db.contentAddressed || value == nil || len(key) != swarm.HashSize {
+		return false
+	}
+
+	hash, err := crypto.LegacyKeccak256(value)
+
+	return err == nil && bytes.Equal(hash, key)
+}
+
+// putContent stores content-addressed value in Single Owner Chunk, whose id
+// is derived from the key. Unlike feed updates, address of this chunk is known
+// without looking up feed index, so value can be read with single request.
+// Values which do not fit into the chunk are uploaded separately and chunk
+// holds reference to them.
+//
+// Value is not stored as plain content addressed chunk, because Swarm address
+// is BMT hash of the value, not its keccak256 hash, so the address could not
+// be derived from the key without storing the mapping elsewhere.
+//
+// Key which was deleted or overwritten before is unmarked once the chunk is
+// uploaded, so that the chunk takes precedence over feed of the key again.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) putContent(key, value []byte) error {
+	id, err := makePrefixedTopic(contentPrefix, key)
+	if err != nil {
+		return err
+	}
+
+	payload := make([]byte, 1, swarm.ChunkSize)
+
+	if len(value) < swarm.ChunkSize {
+		payload[0] = contentInline
+		payload = append(payload, value...)
+	} else {
+		ref, err := db.uploadBytes(value)
+		if err != nil {
+			return err
+		}
+
+		// Content-addressed value is never overwritten, so it stays pinned
+		// even when other keys point to it as well.
+		if db.valuePins != nil {
+			db.valuePins.retain(ref)
+		}
+
+		payload[0] = contentReference
+		payload = append(payload, ref.Bytes()...)
+	}
+
+	data, sig, err := client.SignSocData(client.SocID(id), payload, db.privateKey)
+	if err != nil {
+		return err
+	}
+
+	if err := db.uploadSoc(client.SocID(id), data, sig); err != nil {
+		return err
+	}
+
+	// Content-addressed values never change, so they are always cached
+	// under the same index.
+	db.cache.put(id, 0, value)
+
+	marked, err := db.feedKeys.contains(key)
+	if err != nil || !marked {
+		return err
+	}
+
+	return db.feedKeys.remove(key)
+}
+
+// getContent reads content-addressed value of the key. Key which is marked
+// as stored in its feed was deleted or overwritten by value which is not its
+// hash, so when the feed has updates, the chunk is stale and not found is
+// returned, in order to read the key from the feed. Other keys are read with
+// single chunk request.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) getContent(key []byte) ([]byte, error) {
+	marked, err := db.feedKeys.contains(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if marked {
+		topic, err := makeTopic(key)
+		if err != nil {
+			return nil, err
+		}
+
+		_, exists, err := db.indexer.Current(db.ctx, topic)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			return nil, errBzzDBNotFound
+		}
+	}
+
+	return db.readContent(key)
+}
+
+// markFeedKey marks 32 byte key as stored in its feed, before the feed is
+// updated, when content-addressed mode is enabled. Feed of marked key takes
+// precedence over content-addressed value of the key.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) markFeedKey(key []byte) error {
+	if db.feedKeys == nil || len(key) != swarm.HashSize {
+		return nil
+	}
+
+	marked, err := db.feedKeys.contains(key)
+	if err != nil || marked {
+		return err
+	}
+
+	return db.feedKeys.add(key)
+}
+
+// readContent reads chunk of content-addressed value of the key.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) readContent(key []byte) ([]byte, error) {
+	id, err := makePrefixedTopic(contentPrefix, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, _, ok := db.cache.get(id, 0); ok {
+		return value, nil
+	}
+
+	addr, err := client.SocAddress(db.owner, client.SocID(id))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := db.downloadAndRead(db.beeCli.DownloadChunk, swarm.NewAddress(addr))
+	if err != nil {
+		return nil, err
+	}
+
+	payload := client.RawDataFromSocResp(data)
+	if len(payload) == 0 {
+		return nil, errCorruptContent
+	}
+
+	value := payload[1:]
+
+	switch payload[0] {
+	case contentInline:
+	case contentReference:
+		if len(value) != swarm.HashSize {
+			return nil, errCorruptContent
+		}
+
+		value, err = db.downloadAndRead(db.beeCli.DownloadBytes, swarm.NewAddress(value))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errCorruptContent
+	}
+
+	if hash, err := crypto.LegacyKeccak256(value); err != nil || !bytes.Equal(hash, key) {
+		return nil, errCorruptContent
+	}
+
+	db.cache.put(id, 0, value)
+
+	return value, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
+)
+
+func Test_ContentAddressedSuite(t *testing.T) {
+	t.Parallel()
+
+	runSuite(t, mock.NewClient(), func() []bzzdb.Option {
+		return []bzzdb.Option{bzzdb.WithContentAddressedKeys()}
+	})
+}
+
+func Test_ContentAddressed(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := &countingClient{Client: mock.NewClient()}
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithContentAddressedKeys())
+	assert.NoError(t, err)
+
+	small := []byte("trie node")
+	large := bytes.Repeat([]byte("code"), 4096)
+	smallKey := hashKey(t, small)
+	largeKey := hashKey(t, large)
+
+	// Key which is not hash of the value is stored as regular value
+	otherKey := hashKey(t, []byte("other"))
+
+	assert.NoError(t, db.Put(smallKey, small))
+	assert.NoError(t, db.Put(otherKey, small))
+
+	b := db.NewBatch()
+	assert.NoError(t, b.Put(largeKey, large))
+	assert.NoError(t, b.Write())
+
+	reopened, err := bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithContentAddressedKeys())
+	assert.NoError(t, err)
+
+	assertGet(t, reopened, smallKey, small)
+
+	// Once marks of keys stored in their feeds are loaded, content-addressed
+	// values are read with single chunk request, without feed lookup
+	downloads, lookups := beeCli.downloads.Load(), beeCli.lookups.Load()
+
+	assertGet(t, reopened, largeKey, large)
+	assert.Equal(t, downloads+1, beeCli.downloads.Load())
+	assert.Equal(t, lookups, beeCli.lookups.Load())
+
+	assertGet(t, reopened, otherKey, small)
+
+	_, err = reopened.Get(hashKey(t, []byte("missing")))
+	assert.Error(t, err)
+
+	assert.Len(t, iterateKeys(t, reopened.NewIterator(nil, nil)), 3)
+
+	// Deleted content-addressed keys are not found
+	assert.NoError(t, reopened.Delete(smallKey))
+	assert.Len(t, iterateKeys(t, reopened.NewIterator(nil, nil)), 2)
+	assertNotFound(t, reopened, smallKey)
+
+	// Value written again after deletion is found
+	assert.NoError(t, reopened.Put(smallKey, small))
+	assertGet(t, reopened, smallKey, small)
+
+	// Value which is not hash of the key hides content-addressed value
+	assert.NoError(t, reopened.Put(largeKey, small))
+	assertGet(t, reopened, largeKey, small)
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, reopened.Close())
+
+	// Changes are seen by new instance of database as well
+	reopened, err = bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithContentAddressedKeys())
+	assert.NoError(t, err)
+
+	assertGet(t, reopened, smallKey, small)
+	assertGet(t, reopened, largeKey, small)
+
+	assert.NoError(t, reopened.Delete(smallKey))
+	assertNotFound(t, reopened, smallKey)
+
+	assert.NoError(t, reopened.Close())
+}
+
+func assertNotFound(t *testing.T, db bzzdb.KeyValueStore, key []byte) {
+	t.Helper()
+
+	_, err := db.Get(key)
+	assert.Error(t, err)
+
+	has, err := db.Has(key)
+	assert.NoError(t, err)
+	assert.False(t, has)
+}
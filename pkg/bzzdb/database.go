@@ -4,7 +4,10 @@
 
 package bzzdb
 
-import "io"
+import (
+	"context"
+	"io"
+)
 
 // KeyValueStore is local interface matching ethereum's ethdb.KeyValueStore.
 // Currently this interface only has minimum set of method to cover the most basic
@@ -13,7 +16,176 @@ import "io"
 type KeyValueStore interface {
 	Has(key []byte) (bool, error)
 	Get(key []byte) ([]byte, error)
+	KeyValueWriter
+	Batcher
+	Iteratee
+	Stater
+	Flusher
+	ValueReader
+	Maintainer
+	io.Closer
+}
+
+// KeyValueWriter is local interface matching ethereum's ethdb.KeyValueWriter.
+type KeyValueWriter interface {
 	Put(key []byte, value []byte) error
 	Delete(key []byte) error
+}
+
+// Batch is local interface matching ethereum's ethdb.Batch. Batch is a
+// write-only store that buffers changes in memory until Write is called.
+type Batch interface {
+	KeyValueWriter
+
+	// ValueSize retrieves the amount of data queued up for writing.
+	ValueSize() int
+
+	// Write flushes any accumulated data to the store.
+	Write() error
+
+	// Reset resets the batch for reuse.
+	Reset()
+
+	// Replay replays the batch contents.
+	Replay(w KeyValueWriter) error
+}
+
+// Batcher is local interface matching ethereum's ethdb.Batcher.
+type Batcher interface {
+	// NewBatch creates a write-only store that buffers changes until a final
+	// write is called.
+	NewBatch() Batch
+
+	// NewBatchWithSize creates a write-only batch with pre-allocated buffer.
+	NewBatchWithSize(size int) Batch
+}
+
+// Iterator is local interface matching ethereum's ethdb.Iterator. Iterator
+// iterates over key/value pairs in ascending key order.
+type Iterator interface {
+	// Next moves the iterator to the next key/value pair. It returns whether the
+	// iterator is exhausted.
+	Next() bool
+
+	// Error returns any accumulated error. Exhausting all the key/value pairs
+	// is not considered to be an error.
+	Error() error
+
+	// Key returns the key of the current key/value pair, or nil if done.
+	Key() []byte
+
+	// Value returns the value of the current key/value pair, or nil if done.
+	Value() []byte
+
+	// Release releases associated resources.
+	Release()
+}
+
+// Iteratee is local interface matching ethereum's ethdb.Iteratee.
+type Iteratee interface {
+	// NewIterator creates a binary-alphabetical iterator over a subset
+	// of database content with a particular key prefix, starting at a particular
+	// initial key (or after, if it does not exist).
+	NewIterator(prefix []byte, start []byte) Iterator
+}
+
+// Stater is local interface matching ethereum's ethdb.Stater.
+type Stater interface {
+	// Stat returns a particular internal stat of the database.
+	Stat(property string) (string, error)
+}
+
+// Flusher wraps Flush method of the database. This interface is not part of
+// ethereum's ethdb, it is needed because bzzdb can buffer writes.
+type Flusher interface {
+	// Flush waits until all buffered writes are persisted.
+	Flush(ctx context.Context) error
+}
+
+// ValueReader wraps GetReader method of the database. This interface is not
+// part of ethereum's ethdb, it is needed to read large values without holding
+// them in memory.
+type ValueReader interface {
+	// GetReader returns reader of the value of the key, which must be closed.
+	GetReader(key []byte) (io.ReadCloser, error)
+}
+
+// Maintainer wraps Maintain method of the database. This interface is not
+// part of ethereum's ethdb, it is needed because data stored on Swarm can be
+// lost by the network.
+type Maintainer interface {
+	// Maintain checks that stored data is retrievable and re-uploads data
+	// which is missing.
+	Maintain(ctx context.Context) (MaintenanceReport, error)
+}
+
+// AncientReaderOp is local interface matching ethereum's ethdb.AncientReaderOp.
+type AncientReaderOp interface {
+	// HasAncient returns an indicator whether the specified data exists in the
+	// ancient store.
+	HasAncient(kind string, number uint64) (bool, error)
+
+	// Ancient retrieves an ancient binary blob from the append-only immutable files.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// AncientRange retrieves multiple items in sequence, starting from the index 'start'.
+	// It will return
+	//  - at most 'count' items,
+	//  - at least 1 item (even if exceeding the maxBytes), but will otherwise
+	//   return as many items as fit into maxBytes.
+	AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error)
+
+	// Ancients returns the ancient item numbers in the ancient store.
+	Ancients() (uint64, error)
+
+	// Tail returns the number of first stored item in the freezer.
+	Tail() (uint64, error)
+
+	// AncientSize returns the ancient size of the specified category.
+	AncientSize(kind string) (uint64, error)
+}
+
+// AncientReader is local interface matching ethereum's ethdb.AncientReader.
+type AncientReader interface {
+	AncientReaderOp
+
+	// ReadAncients runs the given read operation while ensuring that no writes
+	// take place on the underlying freezer.
+	ReadAncients(fn func(AncientReaderOp) error) (err error)
+}
+
+// AncientWriter is local interface matching ethereum's ethdb.AncientWriter.
+type AncientWriter interface {
+	// ModifyAncients runs a write operation on the ancient store.
+	// If the function returns an error, any changes to the underlying store are reverted.
+	// The integer return value is the total size of the written data.
+	ModifyAncients(func(AncientWriteOp) error) (int64, error)
+
+	// TruncateHead discards all but the first n ancient data from the ancient store.
+	TruncateHead(n uint64) error
+
+	// TruncateTail discards the first n ancient data from the ancient store.
+	TruncateTail(n uint64) error
+
+	// Sync flushes all in-memory ancient store data to disk.
+	Sync() error
+
+	// MigrateTable processes and migrates entries of a given table to a new format.
+	MigrateTable(string, func([]byte) ([]byte, error)) error
+}
+
+// AncientWriteOp is local interface matching ethereum's ethdb.AncientWriteOp.
+type AncientWriteOp interface {
+	// Append adds an RLP-encoded item.
+	Append(kind string, number uint64, item interface{}) error
+
+	// AppendRaw adds an item without RLP-encoding it.
+	AppendRaw(kind string, number uint64, item []byte) error
+}
+
+// AncientStore is local interface matching ethereum's ethdb.AncientStore.
+type AncientStore interface {
+	AncientReader
+	AncientWriter
 	io.Closer
 }
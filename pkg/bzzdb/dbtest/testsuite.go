@@ -14,11 +14,15 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
-//nolint:cyclop // relax
+//nolint:cyclop,gocognit,maintidx // relax
 package dbtest
 
 import (
 	"bytes"
+	"crypto/rand"
+	"io"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb"
@@ -30,6 +34,178 @@ func TestDatabaseSuite(
 	t *testing.T,
 	New func() bzzdb.KeyValueStore, //nolint:gocritic // relax
 ) {
+	t.Run("Iterator", func(t *testing.T) {
+		tests := []struct {
+			content map[string]string
+			prefix  string
+			start   string
+			order   []string
+		}{
+			// Empty databases should be iterable
+			{map[string]string{}, "", "", nil},
+			{map[string]string{}, "non-existent-prefix", "", nil},
+
+			// Single-item databases should be iterable
+			{map[string]string{"key": "val"}, "", "", []string{"key"}},
+			{map[string]string{"key": "val"}, "k", "", []string{"key"}},
+			{map[string]string{"key": "val"}, "l", "", nil},
+
+			// Multi-item databases should be fully iterable
+			{
+				map[string]string{"k1": "v1", "k5": "v5", "k2": "v2", "k4": "v4", "k3": "v3"},
+				"", "",
+				[]string{"k1", "k2", "k3", "k4", "k5"},
+			},
+			{
+				map[string]string{"k1": "v1", "k5": "v5", "k2": "v2", "k4": "v4", "k3": "v3"},
+				"k", "",
+				[]string{"k1", "k2", "k3", "k4", "k5"},
+			},
+			{
+				map[string]string{"k1": "v1", "k5": "v5", "k2": "v2", "k4": "v4", "k3": "v3"},
+				"l", "",
+				nil,
+			},
+			// Multi-item databases should be prefix-iterable
+			{
+				map[string]string{
+					"ka1": "va1", "ka5": "va5", "ka2": "va2", "ka4": "va4", "ka3": "va3",
+					"kb1": "vb1", "kb5": "vb5", "kb2": "vb2", "kb4": "vb4", "kb3": "vb3",
+				},
+				"ka", "",
+				[]string{"ka1", "ka2", "ka3", "ka4", "ka5"},
+			},
+			{
+				map[string]string{
+					"ka1": "va1", "ka5": "va5", "ka2": "va2", "ka4": "va4", "ka3": "va3",
+					"kb1": "vb1", "kb5": "vb5", "kb2": "vb2", "kb4": "vb4", "kb3": "vb3",
+				},
+				"kc", "",
+				nil,
+			},
+			// Multi-item databases should be prefix-iterable with start position
+			{
+				map[string]string{
+					"ka1": "va1", "ka5": "va5", "ka2": "va2", "ka4": "va4", "ka3": "va3",
+					"kb1": "vb1", "kb5": "vb5", "kb2": "vb2", "kb4": "vb4", "kb3": "vb3",
+				},
+				"ka", "3",
+				[]string{"ka3", "ka4", "ka5"},
+			},
+			{
+				map[string]string{
+					"ka1": "va1", "ka5": "va5", "ka2": "va2", "ka4": "va4", "ka3": "va3",
+					"kb1": "vb1", "kb5": "vb5", "kb2": "vb2", "kb4": "vb4", "kb3": "vb3",
+				},
+				"ka", "8",
+				nil,
+			},
+		}
+		for i, tt := range tests {
+			// Create the key-value data store
+			db := New()
+			for key, val := range tt.content {
+				if err := db.Put([]byte(key), []byte(val)); err != nil {
+					t.Fatalf("test %d: failed to insert item %s:%s into database: %v", i, key, val, err)
+				}
+			}
+			// Iterate over the database with the given configs and verify the results
+			it, idx := db.NewIterator([]byte(tt.prefix), []byte(tt.start)), 0
+			for it.Next() {
+				if len(tt.order) <= idx {
+					t.Errorf("test %d: prefix=%q more items than expected: checking idx=%d (key %q), expecting len=%d",
+						i, tt.prefix, idx, it.Key(), len(tt.order))
+
+					break
+				}
+				if !bytes.Equal(it.Key(), []byte(tt.order[idx])) {
+					t.Errorf("test %d: item %d: key mismatch: have %s, want %s", i, idx, string(it.Key()), tt.order[idx])
+				}
+				if !bytes.Equal(it.Value(), []byte(tt.content[tt.order[idx]])) {
+					t.Errorf("test %d: item %d: value mismatch: have %s, want %s",
+						i, idx, string(it.Value()), tt.content[tt.order[idx]])
+				}
+				idx++
+			}
+			if err := it.Error(); err != nil {
+				t.Errorf("test %d: iteration failed: %v", i, err)
+			}
+			if idx != len(tt.order) {
+				t.Errorf("test %d: iteration terminated prematurely: have %d, want %d", i, idx, len(tt.order))
+			}
+			db.Close()
+		}
+	})
+
+	t.Run("IteratorWith", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		keys := []string{"1", "2", "3", "4", "6", "10", "11", "12", "20", "21", "22"}
+		sort.Strings(keys) // 1, 10, 11, etc
+
+		for _, k := range keys {
+			if err := db.Put([]byte(k), nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		{
+			it := db.NewIterator(nil, nil)
+			got, want := iterateKeys(it), keys
+			if err := it.Error(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Iterator: got: %s; want: %s", got, want)
+			}
+		}
+
+		{
+			it := db.NewIterator([]byte("1"), nil)
+			got, want := iterateKeys(it), []string{"1", "10", "11", "12"}
+			if err := it.Error(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("IteratorWith(1,nil): got: %s; want: %s", got, want)
+			}
+		}
+
+		{
+			it := db.NewIterator([]byte("5"), nil)
+			got, want := iterateKeys(it), []string{}
+			if err := it.Error(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("IteratorWith(5,nil): got: %s; want: %s", got, want)
+			}
+		}
+
+		{
+			it := db.NewIterator(nil, []byte("2"))
+			got, want := iterateKeys(it), []string{"2", "20", "21", "22", "3", "4", "6"}
+			if err := it.Error(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("IteratorWith(nil,2): got: %s; want: %s", got, want)
+			}
+		}
+
+		{
+			it := db.NewIterator(nil, []byte("5"))
+			got, want := iterateKeys(it), []string{"6"}
+			if err := it.Error(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("IteratorWith(nil,5): got: %s; want: %s", got, want)
+			}
+		}
+	})
+
 	t.Run("KeyValueOperations", func(t *testing.T) {
 		db := New()
 		defer db.Close()
@@ -69,4 +245,274 @@ func TestDatabaseSuite(
 			t.Errorf("wrong value: %t", got)
 		}
 	})
+
+	t.Run("Batch", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		b := db.NewBatch()
+		for _, k := range []string{"1", "2", "3", "4"} {
+			if err := b.Put([]byte(k), []byte("value-"+k)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if has, err := db.Has([]byte("1")); err != nil {
+			t.Fatal(err)
+		} else if has {
+			t.Error("db contains element before batch write")
+		}
+
+		if err := b.Write(); err != nil {
+			t.Fatal(err)
+		}
+
+		{
+			it := db.NewIterator(nil, nil)
+			if got, want := iterateKeys(it), []string{"1", "2", "3", "4"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got: %s; want: %s", got, want)
+			}
+		}
+
+		for _, k := range []string{"1", "2", "3", "4"} {
+			if got, err := db.Get([]byte(k)); err != nil {
+				t.Error(err)
+			} else if !bytes.Equal(got, []byte("value-"+k)) {
+				t.Errorf("wrong value: %q", got)
+			}
+		}
+
+		b.Reset()
+
+		if size := b.ValueSize(); size != 0 {
+			t.Errorf("wrong value size after reset: %d", size)
+		}
+
+		// Mix writes and deletes in batch
+		_ = b.Put([]byte("5"), []byte("value-5"))
+		_ = b.Delete([]byte("1"))
+		_ = b.Put([]byte("6"), []byte("value-6"))
+		_ = b.Delete([]byte("3"))
+		_ = b.Put([]byte("3"), []byte("value-3-updated"))
+
+		if err := b.Write(); err != nil {
+			t.Fatal(err)
+		}
+
+		{
+			it := db.NewIterator(nil, nil)
+			if got, want := iterateKeys(it), []string{"2", "3", "4", "5", "6"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got: %s; want: %s", got, want)
+			}
+		}
+
+		want := map[string][]byte{
+			"1": nil,
+			"2": []byte("value-2"),
+			"3": []byte("value-3-updated"),
+			"4": []byte("value-4"),
+			"5": []byte("value-5"),
+			"6": []byte("value-6"),
+		}
+		for k, v := range want {
+			if v == nil {
+				if has, err := db.Has([]byte(k)); err != nil {
+					t.Error(err)
+				} else if has {
+					t.Errorf("deleted key %q still present", k)
+				}
+
+				continue
+			}
+
+			if got, err := db.Get([]byte(k)); err != nil {
+				t.Error(err)
+			} else if !bytes.Equal(got, v) {
+				t.Errorf("wrong value for %q: %q", k, got)
+			}
+		}
+	})
+
+	t.Run("BatchReplay", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		want := []string{"1", "2", "3", "4"}
+		b := db.NewBatch()
+		for _, k := range want {
+			if err := b.Put([]byte(k), nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		b2 := db.NewBatch()
+		if err := b.Replay(b2); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := b2.Replay(db); err != nil {
+			t.Fatal(err)
+		}
+
+		it := db.NewIterator(nil, nil)
+		if got := iterateKeys(it); !reflect.DeepEqual(got, want) {
+			t.Errorf("got: %s; want: %s", got, want)
+		}
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		key := []byte("foo")
+
+		for _, value := range []string{"first", "second", "third"} {
+			if err := db.Put(key, []byte(value)); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := db.Get(key); err != nil {
+				t.Error(err)
+			} else if !bytes.Equal(got, []byte(value)) {
+				t.Errorf("wrong value: %q", got)
+			}
+		}
+
+		if err := db.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.Put(key, []byte("fourth")); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := db.Get(key); err != nil {
+			t.Error(err)
+		} else if !bytes.Equal(got, []byte("fourth")) {
+			t.Errorf("wrong value: %q", got)
+		}
+
+		it := db.NewIterator(nil, nil)
+		if got, want := iterateKeys(it), []string{"foo"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got: %s; want: %s", got, want)
+		}
+
+		// Deleting missing key is not an error
+		if err := db.Delete([]byte("missing")); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("EmptyValue", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		for _, value := range [][]byte{nil, {}} {
+			key := []byte("empty")
+
+			if err := db.Put(key, value); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := db.Has(key); err != nil {
+				t.Error(err)
+			} else if !got {
+				t.Errorf("wrong value: %t", got)
+			}
+
+			if got, err := db.Get(key); err != nil {
+				t.Error(err)
+			} else if len(got) != 0 {
+				t.Errorf("wrong value: %q", got)
+			}
+		}
+	})
+
+	t.Run("LargeValue", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		// Value spans multiple chunks
+		value := make([]byte, 16*4096+1)
+		if _, err := rand.Read(value); err != nil {
+			t.Fatal(err)
+		}
+
+		key := []byte("large")
+		if err := db.Put(key, value); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := db.Get(key); err != nil {
+			t.Error(err)
+		} else if !bytes.Equal(got, value) {
+			t.Errorf("wrong value: got %d bytes", len(got))
+		}
+
+		it := db.NewIterator(key, nil)
+		if !it.Next() {
+			t.Fatalf("iterator is empty: %v", it.Error())
+		}
+
+		if !bytes.Equal(it.Value(), value) {
+			t.Errorf("wrong iterator value: got %d bytes", len(it.Value()))
+		}
+
+		it.Release()
+	})
+
+	t.Run("GetReader", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		value := make([]byte, 3*4096+1)
+		if _, err := rand.Read(value); err != nil {
+			t.Fatal(err)
+		}
+
+		key := []byte("streamed")
+		if err := db.Put(key, value); err != nil {
+			t.Fatal(err)
+		}
+
+		// Read value twice, so that both uncached and cached value is read
+		for i := 0; i < 2; i++ {
+			r, err := db.GetReader(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Error(err)
+			} else if !bytes.Equal(got, value) {
+				t.Errorf("wrong value: got %d bytes", len(got))
+			}
+
+			r.Close()
+
+			if _, err := db.Get(key); err != nil {
+				t.Error(err)
+			}
+		}
+
+		if err := db.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := db.GetReader(key); err == nil {
+			t.Error("expected error for deleted key")
+		}
+	})
+}
+
+func iterateKeys(it bzzdb.Iterator) []string {
+	keys := []string{}
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	sort.Strings(keys)
+	it.Release()
+
+	return keys
 }
@@ -0,0 +1,110 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethersphere/bee/pkg/swarm"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+// chunkDedup remembers chunks recently uploaded with postage batch, so that
+// chunks shared by values are uploaded only once. Chunks are remembered per
+// batch, because chunk stamped with another batch may expire earlier.
+type chunkDedup struct {
+	maxChunks int
+
+	lock    sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+
+	uploaded atomic.Uint64
+	skipped  atomic.Uint64
+}
+
+func newChunkDedup(maxChunks int) *chunkDedup {
+	return &chunkDedup{
+		maxChunks: maxChunks,
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+	}
+}
+
+// filter returns chunks which were not uploaded with the batch yet. Chunk
+// repeated within chunks is returned once.
+func (d *chunkDedup) filter(batchID client.BatchID, chunks []swarm.Chunk) []swarm.Chunk {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	pending := make([]swarm.Chunk, 0, len(chunks))
+	seen := make(map[string]struct{}, len(chunks))
+
+	for _, ch := range chunks {
+		key := dedupKey(batchID, ch.Address())
+
+		if elem, ok := d.entries[key]; ok {
+			d.lru.MoveToFront(elem)
+
+			continue
+		}
+
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		seen[key] = struct{}{}
+		pending = append(pending, ch)
+	}
+
+	d.skipped.Add(uint64(len(chunks) - len(pending)))
+
+	return pending
+}
+
+// add remembers chunks uploaded with the batch.
+func (d *chunkDedup) add(batchID client.BatchID, chunks []swarm.Chunk) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, ch := range chunks {
+		key := dedupKey(batchID, ch.Address())
+
+		if elem, ok := d.entries[key]; ok {
+			d.lru.MoveToFront(elem)
+
+			continue
+		}
+
+		d.entries[key] = d.lru.PushFront(key)
+	}
+
+	for d.lru.Len() > d.maxChunks {
+		key, _ := d.lru.Remove(d.lru.Back()).(string)
+		delete(d.entries, key)
+	}
+
+	d.uploaded.Add(uint64(len(chunks)))
+}
+
+// stat returns value of deduplication statistics property.
+func (d *chunkDedup) stat(property string) (string, bool) {
+	switch property {
+	case "bzzdb.dedup.uploaded":
+		return fmt.Sprint(d.uploaded.Load()), true
+	case "bzzdb.dedup.skipped":
+		return fmt.Sprint(d.skipped.Load()), true
+	}
+
+	return "", false
+}
+
+func dedupKey(batchID client.BatchID, addr swarm.Address) string {
+	return string(batchID) + addr.ByteString()
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb_test
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/ethersphere/bee/pkg/swarm"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
+)
+
+func Test_ChunkDedupSuite(t *testing.T) {
+	t.Parallel()
+
+	runSuite(t, mock.NewClient(), func() []bzzdb.Option {
+		return []bzzdb.Option{bzzdb.WithChunkDedup(1024)}
+	})
+}
+
+func Test_ChunkDedup(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := mock.NewClient()
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithChunkDedup(1024))
+	assert.NoError(t, err)
+
+	// Value of three distinct leaves and root chunk
+	value := make([]byte, 3*swarm.ChunkSize)
+	_, err = rand.Read(value)
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.Put([]byte("a"), value))
+	assertStat(t, db, "bzzdb.dedup.skipped", "0")
+
+	// The same value under another key does not upload any chunk of the value
+	assert.NoError(t, db.Put([]byte("b"), value))
+	assertStat(t, db, "bzzdb.dedup.skipped", "4")
+
+	assertGet(t, db, []byte("a"), value)
+	assertGet(t, db, []byte("b"), value)
+
+	// Leaves shared with the previous value are not uploaded again
+	value = append(value, 4)
+
+	assert.NoError(t, db.Put([]byte("c"), value))
+	assertStat(t, db, "bzzdb.dedup.skipped", "7")
+	assertGet(t, db, []byte("c"), value)
+
+	assert.NoError(t, db.Close())
+}
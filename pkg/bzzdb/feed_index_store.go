@@ -0,0 +1,181 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+// Encoded state is flags byte followed by current, next and latest index.
+const feedIndexStateSize = 25
+
+const (
+	feedIndexHasCurrent = 1 << iota
+	feedIndexHasLatest
+)
+
+//nolint:gochecknoglobals
+var errCorruptFeedIndexState = errors.New("corrupt feed index state")
+
+// FeedIndexState is snapshot of feed indexes known by FeedIndexer.
+type FeedIndexState struct {
+	// Current is index of the latest update, nil when feed has no updates.
+	Current *Index
+	// Next is index of the next update of sequence feed.
+	Next Index
+	// Latest is index of the latest reserved update of epoch feed.
+	Latest *Index
+}
+
+// FeedIndexStore persists feed indexes, so that FeedIndexer does not need to
+// look them up after restart. State is stored per owner and topic, so single
+// store can be shared by feeds of multiple owners.
+type FeedIndexStore interface {
+	// Load returns stored state of the feed. It returns false when state of
+	// the feed is not stored.
+	Load(owner common.Address, topic client.Topic) (FeedIndexState, bool, error)
+
+	// Save stores state of the feed.
+	Save(owner common.Address, topic client.Topic, state FeedIndexState) error
+}
+
+// NewMemoryFeedIndexStore creates FeedIndexStore which keeps state in memory,
+// so state does not outlive the process.
+func NewMemoryFeedIndexStore() FeedIndexStore {
+	return &memoryFeedIndexStore{
+		states: make(map[string]FeedIndexState),
+	}
+}
+
+type memoryFeedIndexStore struct {
+	states map[string]FeedIndexState
+	lock   sync.Mutex
+}
+
+func (s *memoryFeedIndexStore) Load(owner common.Address, topic client.Topic) (FeedIndexState, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	state, ok := s.states[string(feedIndexKey(owner, topic))]
+
+	return state, ok, nil
+}
+
+func (s *memoryFeedIndexStore) Save(owner common.Address, topic client.Topic, state FeedIndexState) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.states[string(feedIndexKey(owner, topic))] = state
+
+	return nil
+}
+
+// LevelDBFeedIndexStore is FeedIndexStore which keeps state in LevelDB
+// database on local disk.
+type LevelDBFeedIndexStore struct {
+	db *leveldb.DB
+}
+
+// NewLevelDBFeedIndexStore opens LevelDB database at path.
+func NewLevelDBFeedIndexStore(path string) (*LevelDBFeedIndexStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening feed index store: %w", err)
+	}
+
+	return &LevelDBFeedIndexStore{db: db}, nil
+}
+
+func (s *LevelDBFeedIndexStore) Load(owner common.Address, topic client.Topic) (FeedIndexState, bool, error) {
+	data, err := s.db.Get(feedIndexKey(owner, topic), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return FeedIndexState{}, false, nil
+	}
+
+	if err != nil {
+		return FeedIndexState{}, false, fmt.Errorf("failed loading feed index state: %w", err)
+	}
+
+	state, err := decodeFeedIndexState(data)
+	if err != nil {
+		return FeedIndexState{}, false, err
+	}
+
+	return state, true, nil
+}
+
+// Save stores state of the feed. State is synced to disk, so that it survives
+// power loss.
+func (s *LevelDBFeedIndexStore) Save(owner common.Address, topic client.Topic, state FeedIndexState) error {
+	err := s.db.Put(feedIndexKey(owner, topic), encodeFeedIndexState(state), &opt.WriteOptions{Sync: true})
+	if err != nil {
+		return fmt.Errorf("failed saving feed index state: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the database.
+func (s *LevelDBFeedIndexStore) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("failed closing feed index store: %w", err)
+	}
+
+	return nil
+}
+
+// feedIndexKey returns key of the feed state, which is owner followed by topic.
+func feedIndexKey(owner common.Address, topic client.Topic) []byte {
+	return append(owner.Bytes(), topic...)
+}
+
+func encodeFeedIndexState(state FeedIndexState) []byte {
+	data := make([]byte, feedIndexStateSize)
+
+	if state.Current != nil {
+		data[0] |= feedIndexHasCurrent
+		binary.BigEndian.PutUint64(data[1:], *state.Current)
+	}
+
+	binary.BigEndian.PutUint64(data[9:], state.Next)
+
+	if state.Latest != nil {
+		data[0] |= feedIndexHasLatest
+		binary.BigEndian.PutUint64(data[17:], *state.Latest)
+	}
+
+	return data
+}
+
+func decodeFeedIndexState(data []byte) (FeedIndexState, error) {
+	if len(data) != feedIndexStateSize {
+		return FeedIndexState{}, errCorruptFeedIndexState
+	}
+
+	state := FeedIndexState{
+		Next: binary.BigEndian.Uint64(data[9:]),
+	}
+
+	if data[0]&feedIndexHasCurrent != 0 {
+		current := binary.BigEndian.Uint64(data[1:])
+		state.Current = &current
+	}
+
+	if data[0]&feedIndexHasLatest != 0 {
+		latest := binary.BigEndian.Uint64(data[17:])
+		state.Latest = &latest
+	}
+
+	return state, nil
+}
@@ -7,14 +7,21 @@ package bzzdb
 import (
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethersphere/bee/pkg/swarm"
 
 	"github.com/ethersphere/eth-on-bzz/pkg/client"
 )
 
+//nolint:gochecknoglobals
+var errCorruptFeedUpdate = errors.New("corrupt feed update")
+
 type FeedIndexFetcher interface {
 	FeedIndexLatest(
 		ctx context.Context,
@@ -23,103 +30,687 @@ type FeedIndexFetcher interface {
 	) (client.FeedIndexResponse, error)
 }
 
+// ChunkFetcher downloads chunks. It is used to look up epoch feed updates.
+type ChunkFetcher interface {
+	DownloadChunk(ctx context.Context, addr swarm.Address) (io.ReadCloser, error)
+}
+
+// Index identifies feed update. For sequence feeds it is sequence number of
+// the update. For epoch feeds it packs time of the update (upper 56 bits) and
+// level of the epoch (lower 8 bits), see EpochIndex.
 type Index = uint64
 
+// EpochIndex packs time of epoch feed update and level of its epoch into
+// Index. Start of the epoch is derived from time, because every update is
+// stored in epoch which contains time of the update. Indexes of updates of the
+// same feed are ordered the same way as updates.
+func EpochIndex(at uint64, level uint8) Index {
+	return at<<8 | Index(level)
+}
+
+// EpochFromIndex unpacks time of epoch feed update and its epoch from Index.
+func EpochFromIndex(index Index) (uint64, client.Epoch) {
+	at, level := index>>8, uint8(index)
+
+	return at, client.Epoch{Start: at >> level << level, Level: level}
+}
+
+type feedType int
+
+const (
+	sequenceFeed feedType = iota
+	epochFeed
+)
+
+// reconcileConcurrency limits number of feeds which are reconciled with
+// Swarm at the same time.
+const reconcileConcurrency = 8
+
 type FeedIndexer struct {
+	feedType     feedType
 	indexFetcher FeedIndexFetcher
+	chunkFetcher ChunkFetcher
 	owner        common.Address
+	now          func() time.Time
+	store        FeedIndexStore
 	indexMap     map[string]*feedIndexData
 	lock         sync.Mutex
+
+	//nolint:containedctx // context of background reconciliation, which is
+	// canceled by Close.
+	ctx        context.Context
+	ctxCancel  context.CancelFunc
+	reconcileC chan struct{}
+	wg         sync.WaitGroup
+}
+
+// FeedIndexerOption configures optional features of FeedIndexer.
+type FeedIndexerOption func(*FeedIndexer)
+
+// FeedIndexerStore makes FeedIndexer persist indexes in the store. Indexes
+// loaded from the store are used by Current right away and they are
+// reconciled with Swarm in background, while Reserve waits until they are
+// reconciled. By default indexes are kept in memory only.
+func FeedIndexerStore(store FeedIndexStore) FeedIndexerOption {
+	return func(i *FeedIndexer) {
+		i.store = store
+	}
 }
 
 type feedIndexData struct {
 	current *Index
 	next    Index
+
+	// latest is index of the latest reserved update of epoch feed.
+	latest *Index
+
+	// reservedC is not nil while the feed is reserved, and it is closed when
+	// reservation is committed or aborted.
+	reservedC chan struct{}
+
+	// stale tells that indexes were loaded from the store and they are not
+	// reconciled with Swarm yet.
+	stale bool
+
+	// reconciledC is not nil while indexes are reconciled in background, and
+	// it is closed when reconciliation finishes.
+	reconciledC chan struct{}
 }
 
-func NewFeedIndexer(indexFetcher FeedIndexFetcher, owner common.Address) *FeedIndexer {
-	return &FeedIndexer{
+func NewFeedIndexer(
+	indexFetcher FeedIndexFetcher,
+	owner common.Address,
+	opts ...FeedIndexerOption,
+) *FeedIndexer {
+	return newFeedIndexer(&FeedIndexer{
+		feedType:     sequenceFeed,
 		indexFetcher: indexFetcher,
 		owner:        owner,
-		indexMap:     make(map[string]*feedIndexData),
+	}, opts)
+}
+
+// NewEpochFeedIndexer creates FeedIndexer for epoch feeds. Epoch feeds do not
+// depend on latest index lookup of Bee node. Instead, the latest update is
+// found by downloading at most one update per epoch level, so lookup takes
+// logarithmic time regardless of number of updates.
+func NewEpochFeedIndexer(
+	chunkFetcher ChunkFetcher,
+	owner common.Address,
+	opts ...FeedIndexerOption,
+) *FeedIndexer {
+	return newFeedIndexer(&FeedIndexer{
+		feedType:     epochFeed,
+		chunkFetcher: chunkFetcher,
+		owner:        owner,
+	}, opts)
+}
+
+func newFeedIndexer(i *FeedIndexer, opts []FeedIndexerOption) *FeedIndexer {
+	i.now = time.Now
+	i.store = NewMemoryFeedIndexStore()
+	i.indexMap = make(map[string]*feedIndexData)
+	i.ctx, i.ctxCancel = context.WithCancel(context.Background())
+	i.reconcileC = make(chan struct{}, reconcileConcurrency)
+
+	for _, opt := range opts {
+		opt(i)
 	}
+
+	return i
+}
+
+// Reservation is exclusive right to write the next update of the feed.
+// Reservation must be either committed, after the update is uploaded, or
+// aborted, when upload fails. Only one reservation of the same feed exists at
+// a time, so index of aborted reservation is reused by the next reservation
+// and no gaps are left in the feed.
+//
+// Indexes are saved to the store only when reservation is committed. Update
+// of reservation which was neither committed nor aborted before restart might
+// have been uploaded, so the first reservation after restart waits until
+// indexes loaded from the store are reconciled with Swarm.
+type Reservation struct {
+	indexer *FeedIndexer
+	topic   client.Topic
+	key     string
+	index   Index
+	prev    *Index // latest index of epoch feed before reservation
+	done    bool
 }
 
-func (i *FeedIndexer) AcquireNext(ctx context.Context, topic client.Topic) (Index, error) {
+// Reserve reserves the next update of the feed. It blocks while another
+// reservation of the same feed is neither committed nor aborted, and while
+// indexes loaded from the store are not reconciled with Swarm.
+func (i *FeedIndexer) Reserve(ctx context.Context, topic client.Topic) (*Reservation, error) {
 	key := hex.EncodeToString(topic)
 
 	i.lock.Lock()
 	defer i.lock.Unlock()
 
-	indexData, ok := i.indexMap[key]
+	var indexData *feedIndexData
 
-	if !ok {
-		i.lock.Unlock()
+	for {
+		var err error
 
-		feedIndexResp, err := i.indexFetcher.FeedIndexLatest(ctx, i.owner, topic)
+		indexData, err = i.indexData(ctx, topic, key)
 		if err != nil {
-			return 0, fmt.Errorf("failed getting latest feed index: %w", err)
+			return nil, err
 		}
 
-		i.lock.Lock()
+		if indexData.stale {
+			if err := i.waitReconciled(ctx, topic, indexData); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if indexData.reservedC == nil {
+			break
+		}
+
+		reservedC := indexData.reservedC
+
+		i.lock.Unlock()
+
+		select {
+		case <-reservedC:
+			i.lock.Lock()
+		case <-ctx.Done():
+			i.lock.Lock()
 
-		if _, ok := i.indexMap[key]; !ok {
-			indexData = newFeedIndexData(feedIndexResp)
-			i.indexMap[key] = indexData
+			return nil, fmt.Errorf("failed reserving feed index: %w", ctx.Err())
 		}
 	}
 
-	index := indexData.next
-	indexData.next++
+	r := &Reservation{
+		indexer: i,
+		topic:   topic,
+		key:     key,
+		prev:    indexData.latest,
+	}
+
+	if i.feedType == epochFeed {
+		r.index = i.nextEpochIndex(indexData)
+		indexData.latest = &r.index
+	} else {
+		r.index = indexData.next
+		indexData.next++
+	}
+
+	indexData.reservedC = make(chan struct{})
 
-	return index, nil
+	return r, nil
 }
 
-func (i *FeedIndexer) Release(topic client.Topic, index Index) {
-	key := hex.EncodeToString(topic)
+// Index returns reserved index.
+func (r *Reservation) Index() Index {
+	return r.index
+}
+
+// Previous returns index of the latest update before the reserved one. It
+// returns false when reserved update is the first update of the feed.
+func (r *Reservation) Previous() (Index, bool) {
+	if r.indexer.feedType == epochFeed {
+		if r.prev == nil {
+			return 0, false
+		}
+
+		return *r.prev, true
+	}
+
+	if r.index == 0 {
+		return 0, false
+	}
+
+	return r.index - 1, true
+}
+
+// Commit marks reserved update as uploaded, which makes it current update of
+// the feed. Calling Commit or Abort after Commit has no effect.
+func (r *Reservation) Commit() {
+	r.finish(func(indexData *feedIndexData) {
+		if indexData.current == nil || r.index > *indexData.current {
+			indexData.current = &r.index
+		}
+
+		// Failed save only makes stored indexes older, which is corrected by
+		// reconciliation after restart.
+		_ = r.indexer.store.Save(r.indexer.owner, r.topic, indexData.state())
+	})
+}
+
+// Abort releases the reservation, so that reserved index is reserved again
+// by the next reservation. It must be called only when the update was
+// definitely not uploaded, such as when upload was rejected. Calling Commit,
+// Abort or Abandon after Abort has no effect.
+func (r *Reservation) Abort() {
+	r.finish(r.rollback)
+}
+
+// Abandon releases the reservation whose update might have been uploaded,
+// such as when upload timed out or failed with server error. Indexes are
+// reconciled with Swarm before the next reservation, so reserved index is
+// reused only when the update was not stored, and the next update is not
+// signed at index of stored update. Calling Commit, Abort or Abandon after
+// Abandon has no effect.
+func (r *Reservation) Abandon() {
+	r.finish(func(indexData *feedIndexData) {
+		r.rollback(indexData)
+		indexData.stale = true
+	})
+}
+
+func (r *Reservation) finish(update func(*feedIndexData)) {
+	i := r.indexer
 
 	i.lock.Lock()
 	defer i.lock.Unlock()
 
-	indexData, ok := i.indexMap[key]
-	if !ok {
+	if r.done {
+		return
+	}
+
+	r.done = true
+
+	indexData := i.indexMap[r.key]
+	update(indexData)
+
+	close(indexData.reservedC)
+	indexData.reservedC = nil
+}
+
+// rollback restores indexes advanced by the reservation, unless they were
+// advanced further by reconciliation in the meantime.
+func (r *Reservation) rollback(indexData *feedIndexData) {
+	if r.indexer.feedType == epochFeed {
+		if indexData.latest == &r.index {
+			indexData.latest = r.prev
+		}
+
 		return
 	}
 
-	if indexData.current == nil || index > *indexData.current {
-		indexData.current = &index
+	if indexData.next == r.index+1 {
+		indexData.next = r.index
 	}
 }
 
+// nextEpochIndex returns index of the next update of epoch feed. Update time
+// is current time, unless the previous update has the same or later time, in
+// which case update time is one second after previous update. This way
+// multiple updates per second are possible.
+func (i *FeedIndexer) nextEpochIndex(indexData *feedIndexData) Index {
+	at := uint64(i.now().Unix())
+
+	if indexData.latest == nil {
+		return EpochIndex(at, client.RootEpoch().Level)
+	}
+
+	last, epoch := EpochFromIndex(*indexData.latest)
+	if at <= last {
+		at = last + 1
+	}
+
+	return EpochIndex(at, epoch.Next(last, at).Level)
+}
+
 func (i *FeedIndexer) Current(ctx context.Context, topic client.Topic) (Index, bool, error) {
 	key := hex.EncodeToString(topic)
 
 	i.lock.Lock()
 	defer i.lock.Unlock()
 
-	indexData, ok := i.indexMap[key]
+	indexData, err := i.indexData(ctx, topic, key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if indexData.current == nil {
+		return 0, false, nil
+	}
+
+	return *indexData.current, true, nil
+}
+
+// Close stops reconciliation of indexes with Swarm. Store is not closed.
+func (i *FeedIndexer) Close() {
+	i.ctxCancel()
+	i.wg.Wait()
+}
+
+// indexData returns index data of the topic. Data is loaded from the store,
+// or looked up on Swarm when the store does not have it. Data loaded from
+// the store is reconciled with Swarm in background, because the store might
+// miss updates which were written right before the process stopped. Data
+// looked up on Swarm is not saved, so that reads do not write to the store,
+// and it is saved once update of the feed is committed.
+//
+// Must be called with lock held, which is released while data is loaded.
+func (i *FeedIndexer) indexData(ctx context.Context, topic client.Topic, key string) (*feedIndexData, error) {
+	if indexData, ok := i.indexMap[key]; ok {
+		return indexData, nil
+	}
+
+	i.lock.Unlock()
+
+	state, stored, err := i.store.Load(i.owner, topic)
+	if err != nil {
+		i.lock.Lock()
+
+		return nil, fmt.Errorf("failed loading feed index: %w", err)
+	}
+
+	loaded := newFeedIndexDataFromState(state)
+
+	if !stored {
+		loaded, err = i.fetch(ctx, topic)
+		if err != nil {
+			i.lock.Lock()
+
+			return nil, fmt.Errorf("failed getting latest feed index: %w", err)
+		}
+	}
+
+	i.lock.Lock()
+
+	if indexData, ok := i.indexMap[key]; ok {
+		return indexData, nil
+	}
+
+	i.indexMap[key] = loaded
+
+	if stored {
+		loaded.stale = true
+		loaded.reconciledC = make(chan struct{})
+
+		i.wg.Add(1)
+
+		go i.reconcile(topic, loaded)
+	}
+
+	return loaded, nil
+}
+
+// reconcile looks up indexes of the topic on Swarm and advances indexes
+// loaded from the store if they are behind. Indexes stay stale when lookup
+// fails, so that Reserve looks them up again.
+func (i *FeedIndexer) reconcile(topic client.Topic, indexData *feedIndexData) {
+	defer i.wg.Done()
+
+	var fetched *feedIndexData
+
+	select {
+	case i.reconcileC <- struct{}{}:
+		fetched, _ = i.fetch(i.ctx, topic)
+		<-i.reconcileC
+	case <-i.ctx.Done():
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if fetched != nil {
+		i.merge(topic, indexData, fetched)
+	}
 
-	if !ok {
+	close(indexData.reconciledC)
+	indexData.reconciledC = nil
+}
+
+// waitReconciled waits until background reconciliation of the indexes
+// finishes, or reconciles them when it failed.
+//
+// Must be called with lock held, which is released while waiting.
+func (i *FeedIndexer) waitReconciled(ctx context.Context, topic client.Topic, indexData *feedIndexData) error {
+	if reconciledC := indexData.reconciledC; reconciledC != nil {
 		i.lock.Unlock()
 
-		feedIndexResp, err := i.indexFetcher.FeedIndexLatest(ctx, i.owner, topic)
+		select {
+		case <-reconciledC:
+			i.lock.Lock()
+
+			return nil
+		case <-ctx.Done():
+			i.lock.Lock()
+
+			return fmt.Errorf("failed reconciling feed index: %w", ctx.Err())
+		}
+	}
+
+	i.lock.Unlock()
+	fetched, err := i.fetch(ctx, topic)
+	i.lock.Lock()
+
+	if err != nil {
+		return fmt.Errorf("failed reconciling feed index: %w", err)
+	}
+
+	i.merge(topic, indexData, fetched)
+
+	return nil
+}
+
+// merge advances stale indexes to indexes fetched from Swarm and marks them
+// as reconciled. Must be called with lock held.
+func (i *FeedIndexer) merge(topic client.Topic, indexData, fetched *feedIndexData) {
+	if !indexData.stale {
+		return
+	}
+
+	indexData.stale = false
+
+	if indexData.merge(fetched) {
+		_ = i.store.Save(i.owner, topic, indexData.state())
+	}
+}
+
+// FeedID returns id of Single Owner Chunk of the feed update.
+//
+//nolint:wrapcheck //relax
+func (i *FeedIndexer) FeedID(topic client.Topic, index Index) (client.SocID, error) {
+	if i.feedType == epochFeed {
+		_, epoch := EpochFromIndex(index)
+
+		return client.EpochFeedID(topic, epoch)
+	}
+
+	return client.FeedID(topic, index)
+}
+
+// UpdateReference returns address of the feed update.
+//
+//nolint:wrapcheck //relax
+func (i *FeedIndexer) UpdateReference(topic client.Topic, index Index) ([]byte, error) {
+	id, err := i.FeedID(topic, index)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.SocAddress(i.owner, id)
+}
+
+// UpdateTime returns time which is stored in payload of the feed update.
+// Epoch feed updates are looked up by this time, while sequence feed updates
+// do not use it.
+func (i *FeedIndexer) UpdateTime(index Index) time.Time {
+	if i.feedType == epochFeed {
+		at, _ := EpochFromIndex(index)
+
+		return time.Unix(int64(at), 0)
+	}
+
+	return time.Unix(0, 0)
+}
+
+//nolint:wrapcheck //relax
+func (i *FeedIndexer) fetch(ctx context.Context, topic client.Topic) (*feedIndexData, error) {
+	if i.feedType == epochFeed {
+		return i.lookupEpoch(ctx, topic)
+	}
+
+	feedIndexResp, err := i.indexFetcher.FeedIndexLatest(ctx, i.owner, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFeedIndexData(feedIndexResp), nil
+}
+
+// lookupEpoch finds the latest update of epoch feed.
+//
+// Updates written in quick succession have time ahead of current time, one
+// second after the previous update. These updates are found by looking up
+// updates at times exponentially further in the future, until there is no
+// update at the time of lookup.
+func (i *FeedIndexer) lookupEpoch(ctx context.Context, topic client.Topic) (*feedIndexData, error) {
+	at := uint64(i.now().Unix())
+
+	// Lookups share upper levels of the tree, so updates are downloaded once.
+	updates := make(map[client.Epoch]epochUpdate)
+
+	for step := uint64(1); ; step *= 2 {
+		latest, err := i.lookupEpochAt(ctx, topic, at, updates)
 		if err != nil {
-			return 0, false, fmt.Errorf("failed getting latest feed index: %w", err)
+			return nil, err
 		}
 
-		i.lock.Lock()
+		if latest == nil {
+			return &feedIndexData{}, nil
+		}
 
-		if _, ok := i.indexMap[key]; !ok {
-			indexData = newFeedIndexData(feedIndexResp)
-			i.indexMap[key] = indexData
+		if latestAt, _ := EpochFromIndex(*latest); latestAt < at {
+			return &feedIndexData{current: latest, latest: latest}, nil
 		}
+
+		at += step
 	}
+}
 
-	if indexData.current == nil {
-		return 0, false, nil
+// lookupEpochAt finds the latest update of epoch feed which is not newer than
+// time at. Tree of epochs is descended towards time at, as long as updates
+// exist and they are not newer than time at.
+func (i *FeedIndexer) lookupEpochAt(
+	ctx context.Context,
+	topic client.Topic,
+	at uint64,
+	updates map[client.Epoch]epochUpdate,
+) (*Index, error) {
+	epoch := client.RootEpoch()
+
+	var latest *Index
+
+	for {
+		update, ok := updates[epoch]
+		if !ok {
+			updateAt, exists, err := i.epochUpdateTime(ctx, topic, epoch)
+			if err != nil {
+				return nil, err
+			}
+
+			update = epochUpdate{at: updateAt, exists: exists}
+			updates[epoch] = update
+		}
+
+		// When the epoch has no update valid at time at, the latest update
+		// can still be in earlier epochs of the left sibling.
+		if !update.exists || update.at > at {
+			if epoch.IsLeft() {
+				return latest, nil
+			}
+
+			at = epoch.Start - 1
+			epoch = epoch.Left()
+
+			continue
+		}
+
+		index := EpochIndex(update.at, epoch.Level)
+		latest = &index
+
+		if epoch.Level == 0 {
+			return latest, nil
+		}
+
+		epoch = epoch.ChildAt(at)
 	}
+}
 
-	return *indexData.current, true, nil
+type epochUpdate struct {
+	at     uint64
+	exists bool
+}
+
+// epochUpdateTime returns time of epoch feed update stored in the epoch.
+func (i *FeedIndexer) epochUpdateTime(
+	ctx context.Context,
+	topic client.Topic,
+	epoch client.Epoch,
+) (uint64, bool, error) {
+	ref, err := client.EpochFeedUpdateReference(i.owner, topic, epoch)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed making epoch feed reference: %w", err)
+	}
+
+	resp, err := i.chunkFetcher.DownloadChunk(ctx, swarm.NewAddress(ref))
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			return 0, false, nil
+		}
+
+		return 0, false, fmt.Errorf("failed downloading epoch feed update: %w", err)
+	}
+	defer resp.Close()
+
+	data, err := io.ReadAll(resp)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed reading epoch feed update: %w", err)
+	}
+
+	if len(data) < swarm.SpanSize+swarm.HashSize+swarm.SocSignatureSize+8 {
+		return 0, false, errCorruptFeedUpdate
+	}
+
+	return uint64(client.PayloadTime(client.RawDataFromSocResp(data)).Unix()), true, nil
+}
+
+func newFeedIndexDataFromState(state FeedIndexState) *feedIndexData {
+	return &feedIndexData{
+		current: state.Current,
+		next:    state.Next,
+		latest:  state.Latest,
+	}
+}
+
+func (d *feedIndexData) state() FeedIndexState {
+	return FeedIndexState{
+		Current: d.current,
+		Next:    d.next,
+		Latest:  d.latest,
+	}
+}
+
+// merge advances indexes to indexes of other data, if they are behind. It
+// returns whether any index changed.
+func (d *feedIndexData) merge(other *feedIndexData) bool {
+	changed := false
+
+	if other.current != nil && (d.current == nil || *other.current > *d.current) {
+		d.current = other.current
+		changed = true
+	}
+
+	if other.next > d.next {
+		d.next = other.next
+		changed = true
+	}
+
+	if other.latest != nil && (d.latest == nil || *other.latest > *d.latest) {
+		d.latest = other.latest
+		changed = true
+	}
+
+	return changed
 }
 
 func newFeedIndexData(resp client.FeedIndexResponse) *feedIndexData {
@@ -7,13 +7,19 @@ package bzzdb_test
 import (
 	"context"
 	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethersphere/bee/pkg/crypto"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb"
 	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
 )
 
 func Test_FeedIndexer(t *testing.T) {
@@ -24,24 +30,102 @@ func Test_FeedIndexer(t *testing.T) {
 
 	topic := makeTopic(t)
 	for i := 0; i < 10; i++ {
-		index, err := indexer.AcquireNext(ctx, topic)
+		reservation, err := indexer.Reserve(ctx, topic)
 		assert.NoError(t, err)
-		assert.Equal(t, bzzdb.Index(i), index)
+		assert.Equal(t, bzzdb.Index(i), reservation.Index())
 
 		current, exists, err := indexer.Current(ctx, topic)
 		assert.NoError(t, err)
-		assert.False(t, exists)
-		assert.Equal(t, bzzdb.Index(0), current)
-	}
+		assert.Equal(t, i > 0, exists)
 
-	for i := 0; i < 10; i++ {
-		indexer.Release(topic, bzzdb.Index(i))
+		if i > 0 {
+			assert.Equal(t, bzzdb.Index(i-1), current)
+		}
 
-		current, exists, err := indexer.Current(ctx, topic)
+		reservation.Commit()
+
+		current, exists, err = indexer.Current(ctx, topic)
 		assert.NoError(t, err)
 		assert.True(t, exists)
 		assert.Equal(t, bzzdb.Index(i), current)
 	}
+
+	// Index of aborted reservation is reused
+	reservation, err := indexer.Reserve(ctx, topic)
+	assert.NoError(t, err)
+	assert.Equal(t, bzzdb.Index(10), reservation.Index())
+
+	reservation.Abort()
+	reservation.Commit()
+
+	current, _, err := indexer.Current(ctx, topic)
+	assert.NoError(t, err)
+	assert.Equal(t, bzzdb.Index(9), current)
+
+	// Feed can not be reserved while it is reserved
+	reservation, err = indexer.Reserve(ctx, topic)
+	assert.NoError(t, err)
+	assert.Equal(t, bzzdb.Index(10), reservation.Index())
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	_, err = indexer.Reserve(timeoutCtx, topic)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		reservation.Commit()
+	}()
+
+	reservation, err = indexer.Reserve(ctx, topic)
+	assert.NoError(t, err)
+	assert.Equal(t, bzzdb.Index(11), reservation.Index())
+	reservation.Commit()
+}
+
+func Test_FeedIndexerAbandon(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	topic := makeTopic(t)
+
+	fetcher := &failingFeedIndexFetcher{}
+	indexer := bzzdb.NewFeedIndexer(fetcher, common.Address{})
+
+	reservation, err := indexer.Reserve(ctx, topic)
+	assert.NoError(t, err)
+	reservation.Commit()
+
+	reservation, err = indexer.Reserve(ctx, topic)
+	assert.NoError(t, err)
+	assert.Equal(t, bzzdb.Index(1), reservation.Index())
+	reservation.Abandon()
+
+	// Abandoned update was stored, so its index is not reused. Reservation
+	// fails while indexes can not be reconciled.
+	fetcher.resp = client.FeedIndexResponse{Current: 1, Next: 2}
+	fetcher.failures.Store(1)
+
+	_, err = indexer.Reserve(ctx, topic)
+	assert.ErrorIs(t, err, errLookupFailed)
+
+	reservation, err = indexer.Reserve(ctx, topic)
+	assert.NoError(t, err)
+	assert.Equal(t, bzzdb.Index(2), reservation.Index())
+	reservation.Abandon()
+
+	current, _, err := indexer.Current(ctx, topic)
+	assert.NoError(t, err)
+	assert.Equal(t, bzzdb.Index(1), current)
+
+	// Abandoned update was not stored, so its index is reused
+	reservation, err = indexer.Reserve(ctx, topic)
+	assert.NoError(t, err)
+	assert.Equal(t, bzzdb.Index(2), reservation.Index())
+	reservation.Commit()
+
+	indexer.Close()
 }
 
 type feedIndexFetcher struct{}
@@ -69,3 +153,241 @@ func makeTopic(t *testing.T) client.Topic {
 
 	return buf
 }
+
+func Test_EpochFeedIndexer(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := &countingClient{Client: mock.NewClient()}
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithEpochFeeds())
+	assert.NoError(t, err)
+
+	// Updates written in quick succession have time ahead of current time
+	const count = 1000
+
+	key := []byte("head")
+	for i := 0; i < count; i++ {
+		assert.NoError(t, db.Put(key, []byte(fmt.Sprint(i))))
+	}
+
+	assertGet(t, db, key, []byte(fmt.Sprint(count-1)))
+
+	// The latest update is found by new instance of database without
+	// downloading every update
+	reopened, err := bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithEpochFeeds())
+	assert.NoError(t, err)
+
+	downloads := beeCli.downloads.Load()
+
+	assertGet(t, reopened, key, []byte(fmt.Sprint(count-1)))
+	assert.Less(t, beeCli.downloads.Load()-downloads, int64(count/4))
+
+	_, err = reopened.Get([]byte("missing"))
+	assert.Error(t, err)
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, reopened.Close())
+}
+
+func Test_FeedIndexerStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	topic := makeTopic(t)
+
+	store, err := bzzdb.NewLevelDBFeedIndexStore(t.TempDir())
+	assert.NoError(t, err)
+
+	indexer := bzzdb.NewFeedIndexer(&feedIndexFetcher{}, common.Address{}, bzzdb.FeedIndexerStore(store))
+
+	for i := 0; i < 5; i++ {
+		reservation, err := indexer.Reserve(ctx, topic)
+		assert.NoError(t, err)
+
+		reservation.Commit()
+	}
+
+	// Index reserved, but not committed before restart
+	_, err = indexer.Reserve(ctx, topic)
+	assert.NoError(t, err)
+	indexer.Close()
+
+	// Stored indexes are used without waiting for Swarm
+	fetcher := &blockingFeedIndexFetcher{
+		unblockC: make(chan struct{}),
+		resp:     client.FeedIndexResponse{Current: 7, Next: 8},
+	}
+	indexer = bzzdb.NewFeedIndexer(fetcher, common.Address{}, bzzdb.FeedIndexerStore(store))
+
+	current, exists, err := indexer.Current(ctx, topic)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, bzzdb.Index(4), current)
+
+	// Reservation waits until indexes are reconciled, because updates
+	// reserved before restart might have been uploaded
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = indexer.Reserve(timeoutCtx, topic)
+	cancel()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	reservationC := make(chan *bzzdb.Reservation, 1)
+
+	go func() {
+		reservation, err := indexer.Reserve(ctx, topic)
+		assert.NoError(t, err)
+
+		reservationC <- reservation
+	}()
+
+	// Indexes are advanced when Swarm has newer updates
+	close(fetcher.unblockC)
+
+	reservation := <-reservationC
+	assert.Equal(t, bzzdb.Index(8), reservation.Index())
+
+	current, _, err = indexer.Current(ctx, topic)
+	assert.NoError(t, err)
+	assert.Equal(t, bzzdb.Index(7), current)
+
+	reservation.Commit()
+
+	indexer.Close()
+	assert.NoError(t, store.Close())
+}
+
+func Test_FeedIndexerStoreReconcileFailure(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	topic := makeTopic(t)
+
+	store := bzzdb.NewMemoryFeedIndexStore()
+	current := bzzdb.Index(2)
+	assert.NoError(t, store.Save(common.Address{}, topic, bzzdb.FeedIndexState{Current: &current, Next: 3}))
+
+	// Background reconciliation fails, so reservation looks up indexes again
+	fetcher := &failingFeedIndexFetcher{resp: client.FeedIndexResponse{Current: 4, Next: 5}}
+	fetcher.failures.Store(1)
+
+	indexer := bzzdb.NewFeedIndexer(fetcher, common.Address{}, bzzdb.FeedIndexerStore(store))
+
+	reservation, err := indexer.Reserve(ctx, topic)
+	assert.NoError(t, err)
+	assert.Equal(t, bzzdb.Index(5), reservation.Index())
+	reservation.Abort()
+
+	indexer.Close()
+}
+
+func Test_FeedIndexerStoreOwners(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	topic := makeTopic(t)
+
+	store, err := bzzdb.NewLevelDBFeedIndexStore(t.TempDir())
+	assert.NoError(t, err)
+
+	indexer := bzzdb.NewFeedIndexer(&feedIndexFetcher{}, common.HexToAddress("0x01"), bzzdb.FeedIndexerStore(store))
+
+	reservation, err := indexer.Reserve(ctx, topic)
+	assert.NoError(t, err)
+	reservation.Commit()
+	indexer.Close()
+
+	// Feed of another owner with the same topic does not share indexes
+	indexer = bzzdb.NewFeedIndexer(&feedIndexFetcher{}, common.HexToAddress("0x02"), bzzdb.FeedIndexerStore(store))
+
+	_, exists, err := indexer.Current(ctx, topic)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	reservation, err = indexer.Reserve(ctx, topic)
+	assert.NoError(t, err)
+	assert.Equal(t, bzzdb.Index(0), reservation.Index())
+	reservation.Abort()
+
+	indexer.Close()
+	assert.NoError(t, store.Close())
+}
+
+func Test_FeedIndexerStoreLookup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	topic := makeTopic(t)
+
+	store := &countingFeedIndexStore{FeedIndexStore: bzzdb.NewMemoryFeedIndexStore()}
+	fetcher := &failingFeedIndexFetcher{resp: client.FeedIndexResponse{Current: 2, Next: 3}}
+
+	indexer := bzzdb.NewFeedIndexer(fetcher, common.Address{}, bzzdb.FeedIndexerStore(store))
+
+	// Indexes looked up on Swarm by reads are not saved
+	current, exists, err := indexer.Current(ctx, topic)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, bzzdb.Index(2), current)
+	assert.Equal(t, int64(0), store.saves.Load())
+
+	reservation, err := indexer.Reserve(ctx, topic)
+	assert.NoError(t, err)
+	reservation.Commit()
+	assert.Equal(t, int64(1), store.saves.Load())
+
+	indexer.Close()
+}
+
+// countingFeedIndexStore counts saved states.
+type countingFeedIndexStore struct {
+	bzzdb.FeedIndexStore
+	saves atomic.Int64
+}
+
+//nolint:wrapcheck //relax
+func (s *countingFeedIndexStore) Save(owner common.Address, topic client.Topic, state bzzdb.FeedIndexState) error {
+	s.saves.Add(1)
+
+	return s.FeedIndexStore.Save(owner, topic, state)
+}
+
+var errLookupFailed = errors.New("lookup failed")
+
+// failingFeedIndexFetcher fails given number of lookups before it returns
+// latest feed index.
+type failingFeedIndexFetcher struct {
+	failures atomic.Int64
+	resp     client.FeedIndexResponse
+}
+
+func (f *failingFeedIndexFetcher) FeedIndexLatest(
+	ctx context.Context,
+	owner common.Address,
+	topic client.Topic,
+) (client.FeedIndexResponse, error) {
+	if f.failures.Add(-1) >= 0 {
+		return client.FeedIndexResponse{}, errLookupFailed
+	}
+
+	return f.resp, nil
+}
+
+// blockingFeedIndexFetcher returns latest feed index after unblockC is closed.
+type blockingFeedIndexFetcher struct {
+	unblockC chan struct{}
+	resp     client.FeedIndexResponse
+}
+
+func (f *blockingFeedIndexFetcher) FeedIndexLatest(
+	ctx context.Context,
+	owner common.Address,
+	topic client.Topic,
+) (client.FeedIndexResponse, error) {
+	<-f.unblockC
+
+	return f.resp, nil
+}
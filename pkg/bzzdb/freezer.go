@@ -0,0 +1,672 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/bee/pkg/swarm"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage"
+)
+
+const (
+	// freezerPageItems is number of offset index entries stored in single
+	// index page.
+	freezerPageItems = 128
+
+	// maxCachedFreezerPages limits number of index pages cached per table.
+	maxCachedFreezerPages = 1024
+
+	freezerEntrySize = swarm.HashSize + 8
+	freezerMetaSize  = 16
+)
+
+//nolint:gochecknoglobals
+var (
+	errUnknownTable       = errors.New("unknown table")
+	errOutOfBounds        = errors.New("out of bounds")
+	errOutOrderInsertion  = errors.New("the append operation is out-order")
+	errInconsistentAppend = errors.New("tables have different number of appended items")
+	errTruncateAboveHead  = errors.New("truncation above head")
+	errTruncateBelowTail  = errors.New("truncation below tail")
+	errNotSupported       = errors.New("this operation is not supported")
+	errCorruptFreezer     = errors.New("corrupt freezer index data")
+
+	freezerPrefix   = []byte("bzzdb.ancient-")
+	freezerMetaName = []byte("meta")
+)
+
+// NewFreezer creates append-only ancient store with given tables. Items are
+// uploaded as content addressed data, while every table keeps offset index of
+// its items in feeds owned by the private key.
+//
+//nolint:wrapcheck //relax
+func NewFreezer(
+	privateKey *ecdsa.PrivateKey,
+	beeCli client.Client,
+	postage postage.Postage,
+	tables []string,
+) (AncientStore, error) {
+	db, err := newBzzDB(privateKey, beeCli, postage)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &freezer{
+		db:     db,
+		tables: make(map[string]*freezerTable, len(tables)),
+	}
+
+	for _, name := range tables {
+		f.tables[name] = &freezerTable{
+			name:  name,
+			pages: make(map[uint64][]freezerEntry),
+		}
+	}
+
+	if err := f.loadMeta(); err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// freezer implements ethereum AncientStore interface.
+type freezer struct {
+	db     *bzzdb
+	tables map[string]*freezerTable
+
+	frozen uint64 // number of items stored, including items truncated from tail
+	tail   uint64 // number of the first item which is still stored
+	lock   sync.RWMutex
+
+	// writeLock serializes all modifications of the freezer.
+	writeLock sync.Mutex
+}
+
+type freezerTable struct {
+	name  string
+	pages map[uint64][]freezerEntry
+	lock  sync.Mutex
+}
+
+type freezerPageKey struct {
+	table *freezerTable
+	page  uint64
+}
+
+// freezerEntry is offset index entry of single item.
+type freezerEntry struct {
+	ref swarm.Address
+	end uint64 // offset of the item end within all table data
+}
+
+func (f *freezer) HasAncient(kind string, number uint64) (bool, error) {
+	if _, ok := f.tables[kind]; !ok {
+		return false, nil
+	}
+
+	tail, frozen := f.bounds()
+
+	return number >= tail && number < frozen, nil
+}
+
+func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	items, err := f.AncientRange(kind, number, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return items[0], nil
+}
+
+func (f *freezer) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	t, err := f.table(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	tail, frozen := f.bounds()
+	if start < tail || start >= frozen {
+		return nil, errOutOfBounds
+	}
+
+	if count > frozen-start {
+		count = frozen - start
+	}
+
+	prevEnd, err := f.itemStart(t, start)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		refs  []swarm.Address
+		sizes []uint64
+		size  uint64
+	)
+
+	for n := start; n < start+count; n++ {
+		e, err := f.entry(t, n)
+		if err != nil {
+			return nil, err
+		}
+
+		itemSize := e.end - prevEnd
+		if len(refs) > 0 && maxBytes != 0 && size+itemSize > maxBytes {
+			break
+		}
+
+		refs = append(refs, e.ref)
+		sizes = append(sizes, itemSize)
+		size += itemSize
+		prevEnd = e.end
+	}
+
+	return f.downloadAll(refs, sizes)
+}
+
+func (f *freezer) Ancients() (uint64, error) {
+	_, frozen := f.bounds()
+
+	return frozen, nil
+}
+
+func (f *freezer) Tail() (uint64, error) {
+	tail, _ := f.bounds()
+
+	return tail, nil
+}
+
+func (f *freezer) AncientSize(kind string) (uint64, error) {
+	t, err := f.table(kind)
+	if err != nil {
+		return 0, err
+	}
+
+	tail, frozen := f.bounds()
+	if tail == frozen {
+		return 0, nil
+	}
+
+	start, err := f.itemStart(t, tail)
+	if err != nil {
+		return 0, err
+	}
+
+	last, err := f.entry(t, frozen-1)
+	if err != nil {
+		return 0, err
+	}
+
+	return last.end - start, nil
+}
+
+func (f *freezer) ReadAncients(fn func(AncientReaderOp) error) error {
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	return fn(f)
+}
+
+func (f *freezer) ModifyAncients(fn func(AncientWriteOp) error) (int64, error) {
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	tail, frozen := f.bounds()
+
+	op := &freezerBatch{
+		tables: f.tables,
+		frozen: frozen,
+		items:  make(map[string][][]byte, len(f.tables)),
+	}
+
+	if err := fn(op); err != nil {
+		return 0, err
+	}
+
+	count, err := op.count()
+	if err != nil || count == 0 {
+		return 0, err
+	}
+
+	err = f.db.trackSync(func() error {
+		if err := f.commit(op.items, frozen); err != nil {
+			return err
+		}
+
+		return f.storeMeta(frozen+count, tail)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return op.size, nil
+}
+
+func (f *freezer) TruncateHead(n uint64) error {
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	tail, frozen := f.bounds()
+	if n >= frozen {
+		return nil
+	}
+
+	if n < tail {
+		return errTruncateBelowTail
+	}
+
+	return f.db.trackSync(func() error {
+		return f.storeMeta(n, tail)
+	})
+}
+
+func (f *freezer) TruncateTail(n uint64) error {
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	tail, frozen := f.bounds()
+	if n <= tail {
+		return nil
+	}
+
+	if n > frozen {
+		return errTruncateAboveHead
+	}
+
+	return f.db.trackSync(func() error {
+		return f.storeMeta(frozen, n)
+	})
+}
+
+// Sync is no-op because all changes are uploaded before ModifyAncients and
+// truncate methods return.
+func (f *freezer) Sync() error {
+	return nil
+}
+
+func (f *freezer) MigrateTable(string, func([]byte) ([]byte, error)) error {
+	return errNotSupported
+}
+
+func (f *freezer) Close() error {
+	return f.db.Close()
+}
+
+func (f *freezer) table(kind string) (*freezerTable, error) {
+	t, ok := f.tables[kind]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownTable, kind)
+	}
+
+	return t, nil
+}
+
+func (f *freezer) bounds() (uint64, uint64) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.tail, f.frozen
+}
+
+// commit uploads items appended to all tables, starting at item number
+// frozen, and updates offset indexes of the tables.
+func (f *freezer) commit(items map[string][][]byte, frozen uint64) error {
+	var (
+		names  []string
+		values [][]byte
+	)
+
+	for name, tableItems := range items {
+		names = append(names, name)
+		values = append(values, tableItems...)
+	}
+
+	refs, err := f.uploadAll(values)
+	if err != nil {
+		return err
+	}
+
+	pages := make(map[freezerPageKey][]freezerEntry)
+
+	for _, name := range names {
+		t := f.tables[name]
+
+		end, err := f.itemStart(t, frozen)
+		if err != nil {
+			return err
+		}
+
+		for i, item := range items[name] {
+			n := frozen + uint64(i)
+			key := freezerPageKey{table: t, page: n / freezerPageItems}
+			end += uint64(len(item))
+
+			entries, ok := pages[key]
+			if !ok {
+				if entries, err = f.page(t, key.page); err != nil {
+					return err
+				}
+			}
+
+			pos := int(n % freezerPageItems)
+			if pos > len(entries) {
+				return errCorruptFreezer
+			}
+
+			pages[key] = append(entries[:pos:pos], freezerEntry{ref: refs[0], end: end})
+			refs = refs[1:]
+		}
+	}
+
+	errC := make(chan error, len(pages))
+	semC := make(chan struct{}, batchWriteConcurrency)
+
+	for key, entries := range pages {
+		semC <- struct{}{}
+
+		go func(key freezerPageKey, entries []freezerEntry) {
+			defer func() { <-semC }()
+
+			topic := mustFreezerTopic(pageTopicName(key.table.name, key.page))
+			if err := f.db.putTopic(topic, encodeFreezerPage(entries)); err != nil {
+				errC <- err
+
+				return
+			}
+
+			key.table.storePage(key.page, entries)
+			errC <- nil
+		}(key, entries)
+	}
+
+	var firstErr error
+
+	for range pages {
+		if err := <-errC; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// uploadAll uploads values in parallel and returns their references.
+//
+//nolint:wrapcheck //relax
+func (f *freezer) uploadAll(values [][]byte) ([]swarm.Address, error) {
+	refs := make([]swarm.Address, len(values))
+	errC := make(chan error, len(values))
+	semC := make(chan struct{}, batchWriteConcurrency)
+
+	for i, value := range values {
+		semC <- struct{}{}
+
+		go func(i int, value []byte) {
+			defer func() { <-semC }()
+
+			ref, err := f.db.uploadStream(bytes.NewReader(value), int64(len(value)))
+			refs[i] = ref
+			errC <- err
+		}(i, value)
+	}
+
+	var firstErr error
+
+	for range values {
+		if err := <-errC; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return refs, firstErr
+}
+
+// downloadAll downloads data of all references in parallel. Sizes of items
+// are known from the index, so that every item is read into buffer of its
+// size.
+func (f *freezer) downloadAll(refs []swarm.Address, sizes []uint64) ([][]byte, error) {
+	items := make([][]byte, len(refs))
+	errC := make(chan error, len(refs))
+	semC := make(chan struct{}, batchWriteConcurrency)
+
+	for i, ref := range refs {
+		semC <- struct{}{}
+
+		go func(i int, ref swarm.Address) {
+			defer func() { <-semC }()
+
+			data, err := f.db.downloadRange(ref, 0, int64(sizes[i]))
+			items[i] = data
+			errC <- err
+		}(i, ref)
+	}
+
+	var firstErr error
+
+	for range refs {
+		if err := <-errC; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return items, firstErr
+}
+
+// itemStart returns offset of the item start within all table data.
+func (f *freezer) itemStart(t *freezerTable, n uint64) (uint64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+
+	e, err := f.entry(t, n-1)
+	if err != nil {
+		return 0, err
+	}
+
+	return e.end, nil
+}
+
+func (f *freezer) entry(t *freezerTable, n uint64) (freezerEntry, error) {
+	entries, err := f.page(t, n/freezerPageItems)
+	if err != nil {
+		return freezerEntry{}, err
+	}
+
+	pos := int(n % freezerPageItems)
+	if pos >= len(entries) {
+		return freezerEntry{}, errCorruptFreezer
+	}
+
+	return entries[pos], nil
+}
+
+// page returns entries of the index page, downloading the page unless it is
+// cached.
+func (f *freezer) page(t *freezerTable, page uint64) ([]freezerEntry, error) {
+	t.lock.Lock()
+	entries, ok := t.pages[page]
+	t.lock.Unlock()
+
+	if ok {
+		return entries, nil
+	}
+
+	data, err := f.db.getTopic(mustFreezerTopic(pageTopicName(t.name, page)))
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+
+	entries, err = decodeFreezerPage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	t.storePage(page, entries)
+
+	return entries, nil
+}
+
+func (t *freezerTable) storePage(page uint64, entries []freezerEntry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.pages[page]; !ok && len(t.pages) >= maxCachedFreezerPages {
+		for p := range t.pages {
+			delete(t.pages, p)
+
+			break
+		}
+	}
+
+	t.pages[page] = entries
+}
+
+func (f *freezer) loadMeta() error {
+	data, err := f.db.getTopic(mustFreezerTopic(freezerMetaName))
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if len(data) != freezerMetaSize {
+		return errCorruptFreezer
+	}
+
+	f.frozen = binary.BigEndian.Uint64(data[:8])
+	f.tail = binary.BigEndian.Uint64(data[8:])
+
+	return nil
+}
+
+func (f *freezer) storeMeta(frozen, tail uint64) error {
+	data := make([]byte, freezerMetaSize)
+	binary.BigEndian.PutUint64(data[:8], frozen)
+	binary.BigEndian.PutUint64(data[8:], tail)
+
+	if err := f.db.putTopic(mustFreezerTopic(freezerMetaName), data); err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	f.frozen, f.tail = frozen, tail
+	f.lock.Unlock()
+
+	return nil
+}
+
+// freezerBatch collects items appended within single ModifyAncients call.
+type freezerBatch struct {
+	tables map[string]*freezerTable
+	frozen uint64
+	items  map[string][][]byte
+	size   int64
+}
+
+//nolint:wrapcheck //relax
+func (b *freezerBatch) Append(kind string, number uint64, item interface{}) error {
+	data, err := rlp.EncodeToBytes(item)
+	if err != nil {
+		return err
+	}
+
+	return b.AppendRaw(kind, number, data)
+}
+
+func (b *freezerBatch) AppendRaw(kind string, number uint64, item []byte) error {
+	if _, ok := b.tables[kind]; !ok {
+		return fmt.Errorf("%w: %s", errUnknownTable, kind)
+	}
+
+	if want := b.frozen + uint64(len(b.items[kind])); number != want {
+		return fmt.Errorf("%w: have %d want %d", errOutOrderInsertion, number, want)
+	}
+
+	b.items[kind] = append(b.items[kind], common.CopyBytes(item))
+	b.size += int64(len(item))
+
+	return nil
+}
+
+// count returns number of items appended to every table.
+func (b *freezerBatch) count() (uint64, error) {
+	count := -1
+
+	for name := range b.tables {
+		if count == -1 {
+			count = len(b.items[name])
+		}
+
+		if len(b.items[name]) != count {
+			return 0, errInconsistentAppend
+		}
+	}
+
+	if count < 0 {
+		return 0, nil
+	}
+
+	return uint64(count), nil
+}
+
+func encodeFreezerPage(entries []freezerEntry) []byte {
+	data := make([]byte, 0, len(entries)*freezerEntrySize)
+
+	for _, e := range entries {
+		data = append(data, e.ref.Bytes()...)
+		data = binary.BigEndian.AppendUint64(data, e.end)
+	}
+
+	return data
+}
+
+func decodeFreezerPage(data []byte) ([]freezerEntry, error) {
+	if len(data)%freezerEntrySize != 0 {
+		return nil, errCorruptFreezer
+	}
+
+	entries := make([]freezerEntry, 0, len(data)/freezerEntrySize)
+
+	for ; len(data) > 0; data = data[freezerEntrySize:] {
+		entries = append(entries, freezerEntry{
+			ref: swarm.NewAddress(append([]byte{}, data[:swarm.HashSize]...)),
+			end: binary.BigEndian.Uint64(data[swarm.HashSize:freezerEntrySize]),
+		})
+	}
+
+	return entries, nil
+}
+
+func pageTopicName(table string, page uint64) []byte {
+	name := make([]byte, 0, len(table)+9)
+	name = append(name, table...)
+	name = append(name, '-')
+
+	return binary.BigEndian.AppendUint64(name, page)
+}
+
+func mustFreezerTopic(name []byte) client.Topic {
+	topic, err := makePrefixedTopic(freezerPrefix, name)
+	if err != nil {
+		panic(err)
+	}
+
+	return topic
+}
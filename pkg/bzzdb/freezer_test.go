@@ -0,0 +1,162 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
+)
+
+func Test_Freezer(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := mock.NewClient()
+	stamp := buyStamp(t, beeCli)
+	tables := []string{"headers", "bodies"}
+
+	f, err := bzzdb.NewFreezer(privateKey, beeCli, stamp, tables)
+	assert.NoError(t, err)
+
+	// Enough items to span multiple index pages
+	const count = 300
+
+	size, err := f.ModifyAncients(func(op bzzdb.AncientWriteOp) error {
+		for i := uint64(0); i < count; i++ {
+			if err := op.AppendRaw("headers", i, freezerItem("header", i)); err != nil {
+				return err
+			}
+
+			if err := op.AppendRaw("bodies", i, freezerItem("body", i)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(count*(len("header-000")+len("body-000"))), size)
+
+	assertAncients(t, f, 0, count)
+
+	item, err := f.Ancient("bodies", 123)
+	assert.NoError(t, err)
+	assert.Equal(t, freezerItem("body", 123), item)
+
+	items, err := f.AncientRange("headers", 126, 5, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{
+		freezerItem("header", 126),
+		freezerItem("header", 127),
+		freezerItem("header", 128),
+		freezerItem("header", 129),
+		freezerItem("header", 130),
+	}, items)
+
+	// Range is limited by maxBytes, but returns at least one item
+	items, err = f.AncientRange("headers", 10, 5, 25)
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	items, err = f.AncientRange("headers", 10, 5, 1)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	items, err = f.AncientRange("headers", count-2, 5, 0)
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	_, err = f.Ancient("headers", count)
+	assert.Error(t, err)
+
+	_, err = f.Ancient("receipts", 0)
+	assert.Error(t, err)
+
+	// Appends must be in order and equal for all tables
+	_, err = f.ModifyAncients(func(op bzzdb.AncientWriteOp) error {
+		return op.AppendRaw("headers", count+1, freezerItem("header", count+1))
+	})
+	assert.Error(t, err)
+
+	_, err = f.ModifyAncients(func(op bzzdb.AncientWriteOp) error {
+		return op.AppendRaw("headers", count, freezerItem("header", count))
+	})
+	assert.Error(t, err)
+	assertAncients(t, f, 0, count)
+
+	assert.NoError(t, f.TruncateTail(100))
+	assert.NoError(t, f.TruncateHead(250))
+	assert.Error(t, f.TruncateTail(251))
+	assert.Error(t, f.TruncateHead(99))
+	assertAncients(t, f, 100, 250)
+
+	_, err = f.Ancient("headers", 99)
+	assert.Error(t, err)
+
+	_, err = f.Ancient("headers", 250)
+	assert.Error(t, err)
+
+	bodiesSize, err := f.AncientSize("bodies")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(150*len("body-000")), bodiesSize)
+
+	// Appending after truncated head overwrites truncated items
+	_, err = f.ModifyAncients(func(op bzzdb.AncientWriteOp) error {
+		if err := op.AppendRaw("headers", 250, []byte("new header")); err != nil {
+			return err
+		}
+
+		return op.Append("bodies", 250, []byte("new body"))
+	})
+	assert.NoError(t, err)
+
+	// State is loaded from Swarm by new instance of freezer
+	reopened, err := bzzdb.NewFreezer(privateKey, beeCli, stamp, tables)
+	assert.NoError(t, err)
+	assertAncients(t, reopened, 100, 251)
+
+	item, err = reopened.Ancient("headers", 250)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("new header"), item)
+
+	item, err = reopened.Ancient("headers", 200)
+	assert.NoError(t, err)
+	assert.Equal(t, freezerItem("header", 200), item)
+
+	assert.NoError(t, f.Close())
+	assert.NoError(t, reopened.Close())
+}
+
+func assertAncients(t *testing.T, f bzzdb.AncientStore, tail, frozen uint64) {
+	t.Helper()
+
+	gotTail, err := f.Tail()
+	assert.NoError(t, err)
+	assert.Equal(t, tail, gotTail)
+
+	gotFrozen, err := f.Ancients()
+	assert.NoError(t, err)
+	assert.Equal(t, frozen, gotFrozen)
+
+	has, err := f.HasAncient("headers", frozen-1)
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	has, err = f.HasAncient("headers", frozen)
+	assert.NoError(t, err)
+	assert.False(t, has)
+}
+
+func freezerItem(kind string, i uint64) []byte {
+	return []byte(fmt.Sprintf("%s-%03d", kind, i))
+}
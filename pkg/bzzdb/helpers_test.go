@@ -0,0 +1,203 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/ethersphere/bee/pkg/swarm"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb"
+	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb/dbtest"
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage"
+)
+
+// runSuite runs database suite against databases created with options
+// returned by newOpts. Every database is owned by a new key, so that
+// databases do not share any data. newOpts is called for every database, so
+// that options can use their own directories, and it may be nil.
+func runSuite(t *testing.T, beeCli client.Client, newOpts func() []bzzdb.Option) {
+	t.Helper()
+
+	newBzzDB := func() bzzdb.KeyValueStore {
+		privateKey, err := crypto.GenerateSecp256k1Key()
+		assert.NoError(t, err)
+
+		var opts []bzzdb.Option
+		if newOpts != nil {
+			opts = newOpts()
+		}
+
+		db, err := bzzdb.New(privateKey, beeCli, postage.New(beeCli), opts...)
+		assert.NoError(t, err)
+
+		return db
+	}
+
+	dbtest.TestDatabaseSuite(t, newBzzDB)
+}
+
+// staticPostage always uses the same postage batch.
+type staticPostage client.BatchID
+
+func (p staticPostage) CurrentBatchID(context.Context) (client.BatchID, error) {
+	return client.BatchID(p), nil
+}
+
+func (p staticPostage) Invalidate(client.BatchID) {}
+
+// buyStamp buys a batch big enough for tests which write many keys.
+func buyStamp(t *testing.T, beeCli client.Client) staticPostage {
+	t.Helper()
+
+	resp, err := beeCli.BuyStamp(context.Background(), big.NewInt(10000000), 30, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	return staticPostage(resp.BatchID)
+}
+
+func iterateKeys(t *testing.T, it bzzdb.Iterator) []string {
+	t.Helper()
+
+	defer it.Release()
+
+	var keys []string
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+
+	assert.NoError(t, it.Error())
+
+	return keys
+}
+
+func assertGet(t *testing.T, db bzzdb.KeyValueStore, key, want []byte) {
+	t.Helper()
+
+	value, err := db.Get(key)
+	assert.NoError(t, err)
+	assert.Equal(t, want, value)
+}
+
+func assertStat(t *testing.T, db bzzdb.KeyValueStore, property, want string) {
+	t.Helper()
+
+	value, err := db.Stat(property)
+	assert.NoError(t, err)
+	assert.Equal(t, want, value)
+}
+
+// countingClient counts chunks downloaded from Swarm and feed index lookups.
+type countingClient struct {
+	client.Client
+	downloads atomic.Int64
+	lookups   atomic.Int64
+}
+
+//nolint:wrapcheck //relax
+func (c *countingClient) DownloadChunk(ctx context.Context, addr swarm.Address) (io.ReadCloser, error) {
+	c.downloads.Add(1)
+
+	return c.Client.DownloadChunk(ctx, addr)
+}
+
+//nolint:wrapcheck //relax
+func (c *countingClient) FeedIndexLatest(
+	ctx context.Context,
+	owner common.Address,
+	topic client.Topic,
+) (client.FeedIndexResponse, error) {
+	c.lookups.Add(1)
+
+	return c.Client.FeedIndexLatest(ctx, owner, topic)
+}
+
+func hashKey(t *testing.T, value []byte) []byte {
+	t.Helper()
+
+	key, err := crypto.LegacyKeccak256(value)
+	assert.NoError(t, err)
+
+	return key
+}
+
+var errUploadFailed = errors.New("upload failed")
+
+// failingClient fails uploads of single owner chunks when fail is set. When
+// failStored is set, chunks are stored before upload fails, as if response
+// of the node was lost. Uploads of chunk with failID always fail. When stall
+// is set, uploads block until they are canceled.
+type failingClient struct {
+	client.Client
+	fail       atomic.Bool
+	failStored atomic.Bool
+	stall      atomic.Bool
+	failID     client.SocID
+}
+
+//nolint:wrapcheck //relax
+func (c *failingClient) UploadSoc(
+	ctx context.Context,
+	owner common.Address,
+	id client.SocID,
+	data []byte,
+	signature client.SocSignature,
+	batchID client.BatchID,
+) (client.UploadSocResponse, error) {
+	if c.stall.Load() {
+		<-ctx.Done()
+
+		return client.UploadSocResponse{}, ctx.Err()
+	}
+
+	if c.fail.Load() || (c.failID != nil && bytes.Equal(id, c.failID)) {
+		return client.UploadSocResponse{}, errUploadFailed
+	}
+
+	resp, err := c.Client.UploadSoc(ctx, owner, id, data, signature, batchID)
+	if err == nil && c.failStored.Load() {
+		return client.UploadSocResponse{}, errUploadFailed
+	}
+
+	return resp, err
+}
+
+// reference returns reference of the value, which is the same as reference
+// of the value uploaded by database.
+func reference(t *testing.T, beeCli client.Client, stamp staticPostage, value []byte) swarm.Address {
+	t.Helper()
+
+	resp, err := beeCli.UploadBytes(context.Background(), value, client.BatchID(stamp))
+	assert.NoError(t, err)
+
+	return resp.Reference
+}
+
+func assertPinned(t *testing.T, beeCli client.Client, addr swarm.Address, want bool) {
+	t.Helper()
+
+	pins, err := beeCli.ListPins(context.Background())
+	assert.NoError(t, err)
+
+	pinned := false
+
+	for _, ref := range pins.References {
+		if ref.Equal(addr) {
+			pinned = true
+		}
+	}
+
+	assert.Equal(t, want, pinned, "pinned %s", addr)
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb
+
+import "bytes"
+
+// iterator iterates over keys from the key index. Keys are listed when
+// iterator is created, while values are downloaded as iterator advances.
+// Keys deleted in the meantime are skipped.
+type iterator struct {
+	db    *bzzdb
+	keys  [][]byte
+	key   []byte
+	value []byte
+	err   error
+}
+
+func newIterator(db *bzzdb, prefix []byte, start []byte) *iterator {
+	// Pending writes are listed before the index, so that writes uploaded
+	// in the meantime are found in the index.
+	var pending [][]byte
+	if db.writeBack != nil {
+		pending = db.writeBack.keys(prefix, start)
+	}
+
+	keys, err := db.keys.keys(prefix, start)
+	keys = mergeKeys(keys, pending)
+
+	return &iterator{
+		db:   db,
+		keys: keys,
+		err:  err,
+	}
+}
+
+// mergeKeys merges two sorted lists of keys, omitting duplicates.
+func mergeKeys(a, b [][]byte) [][]byte {
+	if len(b) == 0 {
+		return a
+	}
+
+	keys := make([][]byte, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		switch c := bytes.Compare(a[0], b[0]); {
+		case c < 0:
+			keys, a = append(keys, a[0]), a[1:]
+		case c > 0:
+			keys, b = append(keys, b[0]), b[1:]
+		default:
+			keys, a, b = append(keys, a[0]), a[1:], b[1:]
+		}
+	}
+
+	keys = append(keys, a...)
+
+	return append(keys, b...)
+}
+
+func (it *iterator) Next() bool {
+	it.key, it.value = nil, nil
+
+	if it.err != nil {
+		return false
+	}
+
+	for len(it.keys) > 0 {
+		key := it.keys[0]
+		it.keys = it.keys[1:]
+
+		value, err := it.db.Get(key)
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+
+			it.err = err
+
+			return false
+		}
+
+		it.key, it.value = key, value
+
+		return true
+	}
+
+	return false
+}
+
+func (it *iterator) Error() error {
+	return it.err
+}
+
+func (it *iterator) Key() []byte {
+	return it.key
+}
+
+func (it *iterator) Value() []byte {
+	return it.value
+}
+
+func (it *iterator) Release() {
+	it.keys = nil
+	it.key, it.value = nil, nil
+}
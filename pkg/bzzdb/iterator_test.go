@@ -0,0 +1,104 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb"
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
+)
+
+func Test_Iterator(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := mock.NewClient()
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp)
+	assert.NoError(t, err)
+
+	// Enough keys to split index into multiple pages
+	const count = 1200
+
+	b := db.NewBatch()
+	for i := 0; i < count; i++ {
+		assert.NoError(t, b.Put(iteratorKey(i), iteratorKey(i)))
+	}
+	assert.NoError(t, b.Write())
+
+	assert.NoError(t, db.Put([]byte("other-key"), []byte("other")))
+	assert.NoError(t, db.Delete(iteratorKey(700)))
+	assert.NoError(t, db.Put(iteratorKey(count), iteratorKey(count)))
+
+	want := make([]string, 0, count)
+	for i := 500; i <= count; i++ {
+		if i != 700 {
+			want = append(want, string(iteratorKey(i)))
+		}
+	}
+
+	assert.Equal(t, want, iterateKeys(t, db.NewIterator([]byte("key-"), []byte("0500"))))
+	assert.Equal(t, []string{"other-key"}, iterateKeys(t, db.NewIterator([]byte("other"), nil)))
+	assert.Empty(t, iterateKeys(t, db.NewIterator([]byte("missing"), nil)))
+
+	// Index is loaded from Swarm by new instance of database
+	reopened, err := bzzdb.New(privateKey, beeCli, stamp)
+	assert.NoError(t, err)
+
+	assert.Equal(t, want, iterateKeys(t, reopened.NewIterator([]byte("key-"), []byte("0500"))))
+	assert.Len(t, iterateKeys(t, reopened.NewIterator(nil, nil)), count+1)
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, reopened.Close())
+}
+
+func Test_IteratorFailedBatch(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	topic, err := crypto.LegacyKeccak256(append([]byte("bzzdb-"), iteratorKey(1)...))
+	assert.NoError(t, err)
+
+	failID, err := client.FeedID(topic, 0)
+	assert.NoError(t, err)
+
+	beeCli := &failingClient{Client: mock.NewClient(), failID: failID}
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp)
+	assert.NoError(t, err)
+
+	b := db.NewBatch()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, b.Put(iteratorKey(i), iteratorKey(i)))
+	}
+
+	assert.ErrorIs(t, b.Write(), errUploadFailed)
+
+	// Keys which were written are listed, although other key failed
+	assertGet(t, db, iteratorKey(0), iteratorKey(0))
+	assertGet(t, db, iteratorKey(2), iteratorKey(2))
+	assert.Equal(t,
+		[]string{string(iteratorKey(0)), string(iteratorKey(2))},
+		iterateKeys(t, db.NewIterator(nil, nil)),
+	)
+
+	assert.NoError(t, db.Close())
+}
+
+func iteratorKey(i int) []byte {
+	return []byte(fmt.Sprintf("key-%04d", i))
+}
@@ -0,0 +1,579 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+// maxIndexPageKeys is maximum number of keys in single index page. Pages
+// exceeding this limit are split in half.
+const maxIndexPageKeys = 512
+
+//nolint:gochecknoglobals
+var (
+	errCorruptIndex = errors.New("corrupt key index data")
+
+	indexPrefix    = []byte("bzzdb.index-")
+	feedKeysPrefix = []byte("bzzdb.feedkeys-")
+	indexRootName  = []byte("root")
+	indexPageName  = []byte("page-")
+)
+
+// keyIndex keeps sorted set of all keys stored in bzzdb. Keys are hashed into
+// feed topics, so without this index it would not be possible to list them.
+//
+// The index is two level B+tree stored on Swarm. Keys are divided into pages of
+// sorted keys and every page is stored under its own feed topic. Root of the
+// tree holds the lowest key (separator) of every page and it is stored under
+// dedicated feed topic. Root changes only when pages are split, so adding or
+// removing a key usually costs upload of a single page.
+type keyIndex struct {
+	db   *bzzdb
+	root *indexRoot
+	lock sync.Mutex // guards in-memory state of the root and all pages
+}
+
+// indexNode holds state needed to load and persist any node of the index.
+type indexNode struct {
+	topic     client.Topic
+	ioLock    sync.Mutex // serializes loading and persisting of the node
+	loaded    bool
+	version   uint64 // incremented on every change of the node
+	persisted uint64 // version of the node stored on Swarm
+}
+
+type indexRoot struct {
+	indexNode
+	prefix     []byte       // prefix of topics of the root and pages
+	pages      []*indexPage // sorted by separator
+	nextPageID uint64
+}
+
+type indexPage struct {
+	indexNode
+	id        uint64
+	separator []byte
+	keys      [][]byte
+}
+
+type keyChange struct {
+	key    []byte
+	remove bool
+}
+
+// newKeyIndex creates index whose nodes are stored under topics with the
+// prefix, so that several independent indexes can be kept.
+func newKeyIndex(db *bzzdb, prefix []byte) *keyIndex {
+	return &keyIndex{
+		db: db,
+		root: &indexRoot{
+			indexNode: indexNode{topic: mustIndexTopic(prefix, indexRootName)},
+			prefix:    prefix,
+		},
+	}
+}
+
+func (idx *keyIndex) add(key []byte) error {
+	return idx.apply([]keyChange{{key: key}})
+}
+
+func (idx *keyIndex) remove(key []byte) error {
+	return idx.apply([]keyChange{{key: key, remove: true}})
+}
+
+// apply applies all changes to the index and uploads modified nodes.
+func (idx *keyIndex) apply(changes []keyChange) error {
+	root, err := idx.loadRoot()
+	if err != nil {
+		return err
+	}
+
+	// Make sure that all pages which are going to be changed are loaded.
+	// Pages created in the meantime by splitting are always loaded, so this
+	// loop is repeated only in rare cases.
+	idx.lock.Lock()
+
+	for missing := idx.missingPages(changes); len(missing) > 0; missing = idx.missingPages(changes) {
+		idx.lock.Unlock()
+
+		for _, p := range missing {
+			if err := idx.load(&p.indexNode, p.decode); err != nil {
+				return err
+			}
+		}
+
+		idx.lock.Lock()
+	}
+
+	touched := make(map[*indexPage]struct{})
+
+	for _, c := range changes {
+		p := root.pageFor(c.key)
+		if p.update(c) {
+			p.version++
+			touched[p] = struct{}{}
+		}
+	}
+
+	pages := make([]*indexPage, 0, len(touched))
+	for p := range touched {
+		pages = append(pages, p)
+
+		if len(p.keys) > maxIndexPageKeys {
+			pages = append(pages, root.split(p, idx.newPage)...)
+		}
+	}
+
+	pageVersions := make([]uint64, len(pages))
+	for i, p := range pages {
+		pageVersions[i] = p.version
+	}
+
+	rootChanged := root.version > root.persisted
+	rootVersion := root.version
+
+	idx.lock.Unlock()
+
+	if err := idx.persistPages(pages, pageVersions); err != nil {
+		return err
+	}
+
+	if !rootChanged {
+		return nil
+	}
+
+	return idx.persist(&root.indexNode, rootVersion, root.encode)
+}
+
+// contains reports whether the key is in the index. Only the page which
+// would hold the key is loaded.
+func (idx *keyIndex) contains(key []byte) (bool, error) {
+	root, err := idx.loadRoot()
+	if err != nil {
+		return false, err
+	}
+
+	idx.lock.Lock()
+	p := root.pageFor(key)
+	idx.lock.Unlock()
+
+	if err := idx.load(&p.indexNode, p.decode); err != nil {
+		return false, err
+	}
+
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	pos := sort.Search(len(p.keys), func(i int) bool {
+		return bytes.Compare(p.keys[i], key) >= 0
+	})
+
+	return pos < len(p.keys) && bytes.Equal(p.keys[pos], key), nil
+}
+
+// keys returns all keys with given prefix, starting at prefix+start.
+func (idx *keyIndex) keys(prefix, start []byte) ([][]byte, error) {
+	root, err := idx.loadRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	begin := make([]byte, 0, len(prefix)+len(start))
+	begin = append(begin, prefix...)
+	begin = append(begin, start...)
+
+	idx.lock.Lock()
+	pages := append([]*indexPage(nil), root.pages[root.pagePos(begin):]...)
+	idx.lock.Unlock()
+
+	var keys [][]byte
+
+	for i, p := range pages {
+		// Pages after the first one which can not have prefixed keys end
+		// the iteration.
+		if i > 0 &&
+			!bytes.HasPrefix(p.separator, prefix) &&
+			bytes.Compare(p.separator, prefix) > 0 {
+			break
+		}
+
+		if err := idx.load(&p.indexNode, p.decode); err != nil {
+			return nil, err
+		}
+
+		var upper []byte
+		if i+1 < len(pages) {
+			upper = pages[i+1].separator
+		}
+
+		idx.lock.Lock()
+		for _, k := range p.keys {
+			if upper != nil && bytes.Compare(k, upper) >= 0 {
+				break
+			}
+
+			if bytes.Compare(k, begin) >= 0 && bytes.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		idx.lock.Unlock()
+	}
+
+	return keys, nil
+}
+
+func (idx *keyIndex) loadRoot() (*indexRoot, error) {
+	if err := idx.load(&idx.root.indexNode, idx.root.decode); err != nil {
+		return nil, err
+	}
+
+	return idx.root, nil
+}
+
+// missingPages returns pages which are not loaded yet and which would be
+// changed by the changes. Must be called with lock held.
+func (idx *keyIndex) missingPages(changes []keyChange) []*indexPage {
+	var missing []*indexPage
+
+	seen := make(map[*indexPage]struct{})
+
+	for _, c := range changes {
+		p := idx.root.pageFor(c.key)
+		if _, ok := seen[p]; ok || p.loaded {
+			continue
+		}
+
+		seen[p] = struct{}{}
+		missing = append(missing, p)
+	}
+
+	return missing
+}
+
+// newPage creates new empty page. Must be called with lock held.
+func (idx *keyIndex) newPage(separator []byte) *indexPage {
+	id := idx.root.nextPageID
+	idx.root.nextPageID++
+
+	return &indexPage{
+		indexNode: indexNode{
+			topic:  mustIndexTopic(idx.root.prefix, pageName(id)),
+			loaded: true,
+		},
+		id:        id,
+		separator: separator,
+	}
+}
+
+// load downloads node data unless the node is already loaded. Node which was
+// never uploaded is decoded from nil data.
+func (idx *keyIndex) load(node *indexNode, decode func([]byte) error) error {
+	node.ioLock.Lock()
+	defer node.ioLock.Unlock()
+
+	idx.lock.Lock()
+	loaded := node.loaded
+	idx.lock.Unlock()
+
+	if loaded {
+		return nil
+	}
+
+	data, err := idx.db.getTopic(node.topic)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	if err := decode(data); err != nil {
+		return err
+	}
+
+	node.loaded = true
+
+	return nil
+}
+
+// persist uploads the node, unless the given version of node has already been
+// uploaded. Concurrent changes of the same node are coalesced into single
+// upload.
+func (idx *keyIndex) persist(node *indexNode, version uint64, encode func() []byte) error {
+	node.ioLock.Lock()
+	defer node.ioLock.Unlock()
+
+	idx.lock.Lock()
+	if node.persisted >= version {
+		idx.lock.Unlock()
+
+		return nil
+	}
+
+	data := encode()
+	current := node.version
+	idx.lock.Unlock()
+
+	if err := idx.db.putTopic(node.topic, data); err != nil {
+		return err
+	}
+
+	idx.lock.Lock()
+	node.persisted = current
+	idx.lock.Unlock()
+
+	return nil
+}
+
+func (idx *keyIndex) persistPages(pages []*indexPage, versions []uint64) error {
+	errC := make(chan error, len(pages))
+	semC := make(chan struct{}, batchWriteConcurrency)
+
+	for i, p := range pages {
+		semC <- struct{}{}
+
+		go func(p *indexPage, version uint64) {
+			defer func() { <-semC }()
+
+			errC <- idx.persist(&p.indexNode, version, p.encode)
+		}(p, versions[i])
+	}
+
+	var firstErr error
+
+	for range pages {
+		if err := <-errC; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// pagePos returns position of the page which should contain the key.
+func (r *indexRoot) pagePos(key []byte) int {
+	return sort.Search(len(r.pages), func(i int) bool {
+		return bytes.Compare(r.pages[i].separator, key) > 0
+	}) - 1
+}
+
+func (r *indexRoot) pageFor(key []byte) *indexPage {
+	return r.pages[r.pagePos(key)]
+}
+
+// split divides overfull page into pages of half the maximum size and returns
+// newly created pages.
+func (r *indexRoot) split(p *indexPage, newPage func([]byte) *indexPage) []*indexPage {
+	const size = maxIndexPageKeys / 2
+
+	var created []*indexPage
+
+	for i := size; i < len(p.keys); i += size {
+		end := i + size
+		if end > len(p.keys) {
+			end = len(p.keys)
+		}
+
+		np := newPage(p.keys[i])
+		np.keys = append([][]byte(nil), p.keys[i:end]...)
+		np.version++
+		created = append(created, np)
+	}
+
+	p.keys = append([][]byte(nil), p.keys[:size]...)
+
+	pos := r.pagePos(p.separator)
+	pages := make([]*indexPage, 0, len(r.pages)+len(created))
+	pages = append(pages, r.pages[:pos+1]...)
+	pages = append(pages, created...)
+	pages = append(pages, r.pages[pos+1:]...)
+	r.pages = pages
+	r.version++
+
+	return created
+}
+
+// decode parses root data. Root which was never uploaded has single page
+// holding all keys.
+func (r *indexRoot) decode(data []byte) error {
+	if data == nil {
+		r.pages = []*indexPage{newIndexPage(r.prefix, 0, nil)}
+		r.nextPageID = 1
+
+		return nil
+	}
+
+	nextPageID, data, err := readUvarint(data)
+	if err != nil {
+		return err
+	}
+
+	count, data, err := readUvarint(data)
+	if err != nil {
+		return err
+	}
+
+	pages := make([]*indexPage, 0, count)
+
+	for i := uint64(0); i < count; i++ {
+		var (
+			id        uint64
+			separator []byte
+		)
+
+		if id, data, err = readUvarint(data); err != nil {
+			return err
+		}
+
+		if separator, data, err = readBytes(data); err != nil {
+			return err
+		}
+
+		pages = append(pages, newIndexPage(r.prefix, id, separator))
+	}
+
+	if len(pages) == 0 {
+		return errCorruptIndex
+	}
+
+	r.pages = pages
+	r.nextPageID = nextPageID
+
+	return nil
+}
+
+func (r *indexRoot) encode() []byte {
+	data := binary.AppendUvarint(nil, r.nextPageID)
+	data = binary.AppendUvarint(data, uint64(len(r.pages)))
+
+	for _, p := range r.pages {
+		data = binary.AppendUvarint(data, p.id)
+		data = appendBytes(data, p.separator)
+	}
+
+	return data
+}
+
+func newIndexPage(prefix []byte, id uint64, separator []byte) *indexPage {
+	return &indexPage{
+		indexNode: indexNode{topic: mustIndexTopic(prefix, pageName(id))},
+		id:        id,
+		separator: separator,
+	}
+}
+
+// update applies change to the page and reports whether page was changed.
+func (p *indexPage) update(c keyChange) bool {
+	pos := sort.Search(len(p.keys), func(i int) bool {
+		return bytes.Compare(p.keys[i], c.key) >= 0
+	})
+	found := pos < len(p.keys) && bytes.Equal(p.keys[pos], c.key)
+
+	switch {
+	case c.remove && found:
+		keys := make([][]byte, 0, len(p.keys)-1)
+		keys = append(keys, p.keys[:pos]...)
+		p.keys = append(keys, p.keys[pos+1:]...)
+
+		return true
+
+	case !c.remove && !found:
+		keys := make([][]byte, 0, len(p.keys)+1)
+		keys = append(keys, p.keys[:pos]...)
+		keys = append(keys, append([]byte{}, c.key...))
+		p.keys = append(keys, p.keys[pos:]...)
+
+		return true
+	}
+
+	return false
+}
+
+func (p *indexPage) decode(data []byte) error {
+	if data == nil {
+		p.keys = nil
+
+		return nil
+	}
+
+	count, data, err := readUvarint(data)
+	if err != nil {
+		return err
+	}
+
+	keys := make([][]byte, 0, count)
+
+	for i := uint64(0); i < count; i++ {
+		var key []byte
+
+		if key, data, err = readBytes(data); err != nil {
+			return err
+		}
+
+		keys = append(keys, key)
+	}
+
+	p.keys = keys
+
+	return nil
+}
+
+func (p *indexPage) encode() []byte {
+	data := binary.AppendUvarint(nil, uint64(len(p.keys)))
+
+	for _, k := range p.keys {
+		data = appendBytes(data, k)
+	}
+
+	return data
+}
+
+func pageName(id uint64) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte{}, indexPageName...), id)
+}
+
+func mustIndexTopic(prefix, name []byte) client.Topic {
+	topic, err := makePrefixedTopic(prefix, name)
+	if err != nil {
+		panic(err)
+	}
+
+	return topic
+}
+
+func appendBytes(data, b []byte) []byte {
+	data = binary.AppendUvarint(data, uint64(len(b)))
+
+	return append(data, b...)
+}
+
+func readUvarint(data []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, errCorruptIndex
+	}
+
+	return v, data[n:], nil
+}
+
+func readBytes(data []byte) ([]byte, []byte, error) {
+	size, data, err := readUvarint(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if uint64(len(data)) < size {
+		return nil, nil, errCorruptIndex
+	}
+
+	return append([]byte{}, data[:size]...), data[size:], nil
+}
@@ -0,0 +1,285 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethersphere/bee/pkg/swarm"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+//nolint:gochecknoglobals
+var (
+	errNotCached     = errors.New("value is not cached")
+	errRestoredValue = errors.New("restored value has different reference")
+	errNotPersisted  = errors.New("key is not persisted")
+)
+
+// MaintenanceReport summarizes single run of Maintain.
+type MaintenanceReport struct {
+	// Keys is number of keys which were walked.
+	Keys int
+	// Checked is number of feed updates, content chunks and values whose
+	// retrievability was checked.
+	Checked int
+	// Missing is number of those which were not retrievable.
+	Missing int
+	// Reuploaded is number of missing data re-uploaded by the node from its
+	// local store.
+	Reuploaded int
+	// Restored is number of missing data uploaded again from the cache.
+	Restored int
+	// Failures lists keys whose data could not be checked or restored.
+	Failures []MaintenanceFailure
+}
+
+// Healthy tells that data of all keys is retrievable.
+func (r MaintenanceReport) Healthy() bool {
+	return len(r.Failures) == 0
+}
+
+// MaintenanceFailure tells why data of the key could not be maintained.
+type MaintenanceFailure struct {
+	Key []byte
+	Err error
+}
+
+// restoreFn uploads missing data again from the cache.
+type restoreFn func() error
+
+// Maintain walks keys of the database and checks that feed update of every
+// key and value it points to are retrievable from the network. Missing data
+// is re-uploaded by the node, when the node still stores it, such as when it
+// is pinned. Otherwise it is uploaded again from the cache. Keys which are
+// listed in the key index, but whose feed update or content chunk does not
+// exist, are not persisted yet and they are skipped.
+//
+// Error is returned when keys can not be listed or ctx is done, while
+// failures of single keys are listed in the report.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) Maintain(ctx context.Context) (MaintenanceReport, error) {
+	var report MaintenanceReport
+
+	keys, err := db.keys.keys(nil, nil)
+	if err != nil {
+		return report, err
+	}
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		// Checks of skipped key are not reported.
+		checked := report
+
+		err := db.maintainKey(ctx, key, &report)
+		if errors.Is(err, errNotPersisted) {
+			report = checked
+
+			continue
+		}
+
+		report.Keys++
+
+		if err != nil {
+			report.Failures = append(report.Failures, MaintenanceFailure{Key: key, Err: err})
+		}
+	}
+
+	return report, nil
+}
+
+//nolint:wrapcheck //relax
+func (db *bzzdb) maintainKey(ctx context.Context, key []byte, report *MaintenanceReport) error {
+	topic, err := makeTopic(key)
+	if err != nil {
+		return err
+	}
+
+	index, exists, err := db.indexer.Current(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		if db.contentAddressed && len(key) == swarm.HashSize {
+			return db.maintainContent(ctx, key, report)
+		}
+
+		return errNotPersisted
+	}
+
+	socAddr, err := db.indexer.UpdateReference(topic, index)
+	if err != nil {
+		return err
+	}
+
+	err = db.ensureRetrievable(ctx, swarm.NewAddress(socAddr), report, func() error {
+		return db.restoreUpdate(topic, index)
+	})
+	if err != nil {
+		return err
+	}
+
+	ref, err := db.updateValueRef(topic, index)
+	if err != nil || ref.IsZero() {
+		return err
+	}
+
+	return db.ensureRetrievable(ctx, ref, report, func() error {
+		return db.restoreValue(topic, index, ref)
+	})
+}
+
+// maintainContent checks chunk of content-addressed value and value it
+// references. Content chunk which is neither stored by the node nor cached
+// is not persisted.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) maintainContent(ctx context.Context, key []byte, report *MaintenanceReport) error {
+	id, err := makePrefixedTopic(contentPrefix, key)
+	if err != nil {
+		return err
+	}
+
+	socAddr, err := client.SocAddress(db.owner, client.SocID(id))
+	if err != nil {
+		return err
+	}
+
+	err = db.ensureRetrievable(ctx, swarm.NewAddress(socAddr), report, func() error {
+		value, _, ok := db.cache.get(id, 0)
+		if !ok {
+			return errNotPersisted
+		}
+
+		return db.putContent(key, value)
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := db.downloadAndRead(db.beeCli.DownloadChunk, swarm.NewAddress(socAddr))
+	if err != nil {
+		return err
+	}
+
+	payload := client.RawDataFromSocResp(data)
+	if len(payload) == 0 {
+		return errCorruptContent
+	}
+
+	if payload[0] != contentReference {
+		return nil
+	}
+
+	ref := swarm.NewAddress(payload[1:])
+
+	return db.ensureRetrievable(ctx, ref, report, func() error {
+		return db.restoreValue(id, 0, ref)
+	})
+}
+
+// ensureRetrievable checks that data with the address is retrievable. Missing
+// data is re-uploaded by the node, or restored when the node does not have it.
+func (db *bzzdb) ensureRetrievable(
+	ctx context.Context,
+	addr swarm.Address,
+	report *MaintenanceReport,
+	restore restoreFn,
+) error {
+	report.Checked++
+
+	resp, err := db.beeCli.IsRetrievable(ctx, addr)
+	if err != nil {
+		return fmt.Errorf("failed checking %s: %w", addr, err)
+	}
+
+	if resp.IsRetrievable {
+		return nil
+	}
+
+	report.Missing++
+
+	batchID, err := db.postage.CurrentBatchID(ctx)
+	if err == nil {
+		if err = db.beeCli.Reupload(ctx, addr, batchID); err == nil {
+			report.Reuploaded++
+
+			return nil
+		}
+	}
+
+	if err := restore(); err != nil {
+		return fmt.Errorf("failed restoring %s: %w", addr, err)
+	}
+
+	report.Restored++
+
+	return nil
+}
+
+// restoreUpdate uploads feed update of the topic at the index again, pointing
+// to the cached value.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) restoreUpdate(topic client.Topic, index Index) error {
+	value, deleted, ok := db.cache.get(topic, index)
+	if !ok {
+		return errNotCached
+	}
+
+	ref := swarm.NewAddress(zeroSocData)
+
+	if !deleted {
+		var err error
+		if ref, err = db.restoreBytes(value); err != nil {
+			return err
+		}
+	}
+
+	return db.uploadUpdate(topic, index, ref)
+}
+
+// restoreValue uploads cached value of the topic at the index again. Cached
+// value must have the same reference as the value which is restored.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) restoreValue(topic client.Topic, index Index, ref swarm.Address) error {
+	value, deleted, ok := db.cache.get(topic, index)
+	if !ok || deleted {
+		return errNotCached
+	}
+
+	restored, err := db.restoreBytes(value)
+	if err != nil {
+		return err
+	}
+
+	if !restored.Equal(ref) {
+		return errRestoredValue
+	}
+
+	return nil
+}
+
+// restoreBytes uploads value whole, bypassing chunk deduplication, because
+// deduplication would skip chunks which are lost by the network.
+//
+//nolint:wrapcheck //relax
+func (db *bzzdb) restoreBytes(value []byte) (swarm.Address, error) {
+	ref, err := db.uploadWhole(value)
+	if err != nil {
+		return swarm.ZeroAddress, err
+	}
+
+	return ref, db.pin(ref)
+}
@@ -0,0 +1,263 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/ethersphere/bee/pkg/swarm"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb"
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
+)
+
+func Test_Maintain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		opts      []bzzdb.Option
+		loseValue bool
+		loseSoc   bool
+		want      bzzdb.MaintenanceReport
+	}{
+		{
+			name: "healthy",
+			want: bzzdb.MaintenanceReport{Keys: 2, Checked: 4},
+		},
+		{
+			name:      "reupload pinned",
+			opts:      []bzzdb.Option{bzzdb.WithPinning(false)},
+			loseValue: true,
+			loseSoc:   true,
+			want:      bzzdb.MaintenanceReport{Keys: 2, Checked: 4, Missing: 2, Reuploaded: 2},
+		},
+		{
+			name:      "restore value from cache",
+			opts:      []bzzdb.Option{bzzdb.WithCache(1 << 20)},
+			loseValue: true,
+			want:      bzzdb.MaintenanceReport{Keys: 2, Checked: 4, Missing: 1, Restored: 1},
+		},
+		{
+			// Restored feed update points to restored value, so value is
+			// not missing anymore when it is checked.
+			name:      "restore feed update from cache",
+			opts:      []bzzdb.Option{bzzdb.WithCache(1 << 20)},
+			loseValue: true,
+			loseSoc:   true,
+			want:      bzzdb.MaintenanceReport{Keys: 2, Checked: 4, Missing: 1, Restored: 1},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			privateKey, err := crypto.GenerateSecp256k1Key()
+			assert.NoError(t, err)
+
+			beeCli := mock.NewClient()
+			stamp := buyStamp(t, beeCli)
+
+			db, err := bzzdb.New(privateKey, beeCli, stamp, tc.opts...)
+			assert.NoError(t, err)
+
+			key := []byte("key")
+			value := []byte("value")
+
+			assert.NoError(t, db.Put(key, value))
+			assert.NoError(t, db.Put([]byte("other"), []byte("other value")))
+
+			// Reading the value caches it
+			assertGet(t, db, key, value)
+
+			ref := reference(t, beeCli, stamp, value)
+			socAddr := updateReference(t, privateKey, key)
+
+			if tc.loseValue {
+				mock.Forget(beeCli, ref)
+			}
+
+			if tc.loseSoc {
+				mock.Forget(beeCli, socAddr)
+			}
+
+			report, err := db.Maintain(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, report)
+			assert.True(t, report.Healthy())
+
+			assertRetrievable(t, beeCli, ref, true)
+			assertRetrievable(t, beeCli, socAddr, true)
+
+			assert.NoError(t, db.Close())
+		})
+	}
+}
+
+func Test_MaintainFailure(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := mock.NewClient()
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp)
+	assert.NoError(t, err)
+
+	key := []byte("key")
+	value := []byte("value")
+
+	assert.NoError(t, db.Put(key, value))
+
+	// Value which is neither pinned nor cached can not be restored
+	ref := reference(t, beeCli, stamp, value)
+	mock.Forget(beeCli, ref)
+
+	report, err := db.Maintain(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, report.Healthy())
+	assert.Equal(t, 1, report.Missing)
+	assert.Equal(t, 0, report.Restored)
+
+	if assert.Len(t, report.Failures, 1) {
+		assert.Equal(t, key, report.Failures[0].Key)
+		assert.Error(t, report.Failures[0].Err)
+	}
+
+	assertRetrievable(t, beeCli, ref, false)
+
+	// Maintenance stops when context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = db.Maintain(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.NoError(t, db.Close())
+}
+
+func Test_MaintainContent(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := mock.NewClient()
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp,
+		bzzdb.WithContentAddressedKeys(),
+		bzzdb.WithCache(1<<20),
+	)
+	assert.NoError(t, err)
+
+	// Large value is referenced by content chunk
+	value := make([]byte, 2*swarm.ChunkSize)
+	key := hashKey(t, value)
+
+	assert.NoError(t, db.Put(key, value))
+
+	owner, err := client.OwnerFromKey(privateKey)
+	assert.NoError(t, err)
+
+	id, err := crypto.LegacyKeccak256(append([]byte("bzzdb.content-"), key...))
+	assert.NoError(t, err)
+
+	socAddr, err := client.SocAddress(owner, client.SocID(id))
+	assert.NoError(t, err)
+
+	ref := reference(t, beeCli, stamp, value)
+
+	mock.Forget(beeCli, swarm.NewAddress(socAddr))
+	mock.Forget(beeCli, ref)
+
+	// Restored content chunk points to restored value, so value is not
+	// missing anymore when it is checked.
+	report, err := db.Maintain(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, bzzdb.MaintenanceReport{Keys: 1, Checked: 2, Missing: 1, Restored: 1}, report)
+
+	assertRetrievable(t, beeCli, swarm.NewAddress(socAddr), true)
+	assertRetrievable(t, beeCli, ref, true)
+
+	assert.NoError(t, db.Close())
+}
+
+func Test_MaintainNotPersisted(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := mock.NewClient()
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithContentAddressedKeys())
+	assert.NoError(t, err)
+
+	value := make([]byte, 2*swarm.ChunkSize)
+	contentKey := hashKey(t, value)
+
+	assert.NoError(t, db.Put(contentKey, value))
+	assert.NoError(t, db.Put([]byte("other"), []byte("other value")))
+	assert.NoError(t, db.Close())
+
+	owner, err := client.OwnerFromKey(privateKey)
+	assert.NoError(t, err)
+
+	id, err := crypto.LegacyKeccak256(append([]byte("bzzdb.content-"), contentKey...))
+	assert.NoError(t, err)
+
+	contentAddr, err := client.SocAddress(owner, client.SocID(id))
+	assert.NoError(t, err)
+
+	// Key is listed in the key index, but it has neither feed update nor
+	// content chunk.
+	mock.Forget(beeCli, swarm.NewAddress(contentAddr))
+
+	db, err = bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithContentAddressedKeys())
+	assert.NoError(t, err)
+
+	report, err := db.Maintain(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, bzzdb.MaintenanceReport{Keys: 1, Checked: 2}, report)
+	assert.True(t, report.Healthy())
+
+	assert.NoError(t, db.Close())
+}
+
+// updateReference returns address of the first feed update of the key.
+func updateReference(t *testing.T, privateKey *ecdsa.PrivateKey, key []byte) swarm.Address {
+	t.Helper()
+
+	owner, err := client.OwnerFromKey(privateKey)
+	assert.NoError(t, err)
+
+	topic, err := crypto.LegacyKeccak256(append([]byte("bzzdb-"), key...))
+	assert.NoError(t, err)
+
+	addr, err := client.FeedUpdateReference(owner, topic, 0)
+	assert.NoError(t, err)
+
+	return swarm.NewAddress(addr)
+}
+
+func assertRetrievable(t *testing.T, beeCli client.Client, addr swarm.Address, want bool) {
+	t.Helper()
+
+	resp, err := beeCli.IsRetrievable(context.Background(), addr)
+	assert.NoError(t, err)
+	assert.Equal(t, want, resp.IsRetrievable, "retrievable %s", addr)
+}
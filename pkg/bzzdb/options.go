@@ -0,0 +1,198 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb
+
+import (
+	"time"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/postage"
+)
+
+// Option configures optional features of the database.
+type Option func(*options)
+
+type options struct {
+	cacheSize int
+	cachePath string
+
+	writeBackPath          string
+	writeBackWorkers       int
+	writeBackCloseTimeout  time.Duration
+	writeBackRetryInterval time.Duration
+
+	contentAddressed bool
+	epochFeeds       bool
+	pinning          bool
+	unpinOverwritten bool
+
+	feedIndexStore FeedIndexStore
+
+	usageMeter *postage.UsageMeter
+
+	dedupChunks int
+
+	syncWait         bool
+	syncReport       SyncReportFunc
+	syncPollInterval time.Duration
+}
+
+// WithCache enables in-memory cache of values read from Swarm. Cache holds
+// at most size bytes of keys and values, least recently used values are
+// evicted first.
+func WithCache(size int) Option {
+	return func(o *options) {
+		o.cacheSize = size
+	}
+}
+
+// WithDiskCache enables on-disk cache of values read from Swarm, which is
+// stored in LevelDB database at path. Disk cache outlives the database
+// instance, so values can be reused after restart.
+func WithDiskCache(path string) Option {
+	return func(o *options) {
+		o.cachePath = path
+	}
+}
+
+// WithWriteBack enables write-back mode. In this mode writes are stored in
+// durable write-ahead log in LevelDB database at path and uploaded to Swarm by
+// given number of background workers, so Put, Delete and batch Write return
+// before data is uploaded. Reads observe buffered writes immediately. Writes
+// which were not uploaded before database was closed or crashed are uploaded
+// when database is opened again with the same path.
+//
+// Flush waits for buffered writes to be uploaded and Close waits for them
+// as well. Zero workers means default number of workers.
+func WithWriteBack(path string, workers int) Option {
+	return func(o *options) {
+		o.writeBackPath = path
+		o.writeBackWorkers = workers
+	}
+}
+
+// WithWriteBackCloseTimeout sets how long Close waits for buffered writes to
+// be uploaded in write-back mode. Writes which are not uploaded by then are
+// kept in the write-ahead log and uploaded when database is opened again.
+func WithWriteBackCloseTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.writeBackCloseTimeout = timeout
+	}
+}
+
+// WithWriteBackRetryInterval sets how often writes which failed to be
+// uploaded in write-back mode are retried.
+func WithWriteBackRetryInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.writeBackRetryInterval = interval
+	}
+}
+
+// WithContentAddressedKeys enables content-addressed mode for values whose
+// key is keccak256 hash of the value, such as trie nodes and contract code.
+// These values are stored in Single Owner Chunk whose address is derived from
+// the key, so reading them takes single chunk request, without feed index
+// lookup. Other 32 byte keys are first looked up as content-addressed value,
+// and then as regular value.
+//
+// Deleting content-addressed key, or writing value it is not hash of, marks
+// the key in separate key index and writes feed update of the key. Feed of
+// marked key takes precedence over content-addressed value, until the value
+// is written again.
+func WithContentAddressedKeys() Option {
+	return func(o *options) {
+		o.contentAddressed = true
+	}
+}
+
+// WithEpochFeeds makes database store values in epoch based feeds instead of
+// sequence feeds. The latest update of epoch feed is found in logarithmic time
+// without relying on latest index lookup of Bee node, which makes keys that
+// are updated many times faster to open. Database must always be opened with
+// the same type of feeds.
+func WithEpochFeeds() Option {
+	return func(o *options) {
+		o.epochFeeds = true
+	}
+}
+
+// WithPinning makes database pin every value and Single Owner Chunk it writes
+// on the local node, so that node does not garbage collect them regardless of
+// replication in the network. When unpinOverwritten is set, values which are
+// overwritten or deleted are unpinned. Feed updates stay pinned, because they
+// are needed to look up the latest update. Equal values of different keys
+// share the same pin, so the value is unpinned only once none of the keys
+// points to it. Values which were pinned before the database was opened are
+// never unpinned.
+func WithPinning(unpinOverwritten bool) Option {
+	return func(o *options) {
+		o.pinning = true
+		o.unpinOverwritten = unpinOverwritten
+	}
+}
+
+// WithFeedIndexStore makes database persist feed indexes in the store, so that
+// after restart keys can be read and written without looking up their feed
+// indexes first. Store is not closed when database is closed.
+func WithFeedIndexStore(store FeedIndexStore) Option {
+	return func(o *options) {
+		o.feedIndexStore = store
+	}
+}
+
+// WithUsageMeter makes database record chunks it uploads in the meter, so that
+// capacity of postage batches can be forecast from recent rate of writes.
+func WithUsageMeter(meter *postage.UsageMeter) Option {
+	return func(o *options) {
+		o.usageMeter = meter
+	}
+}
+
+// WithChunkDedup makes database split values into chunks locally and upload
+// only chunks which were not uploaded before, so that data shared by values
+// is uploaded once. Addresses of at most maxChunks recently uploaded chunks
+// are remembered. Chunks are uploaded again when postage batch changes,
+// because stamps of previous batch may expire.
+func WithChunkDedup(maxChunks int) Option {
+	return func(o *options) {
+		o.dedupChunks = maxChunks
+	}
+}
+
+// WithSyncWait makes writes return only after written data is synced to the
+// network, so that local copies of the data can be discarded. Uploads are
+// tagged and progress of the tags is polled until all chunks are synced. In
+// write-back mode, writes are removed from write-ahead log only once they are
+// synced, so Flush waits for sync as well.
+func WithSyncWait() Option {
+	return func(o *options) {
+		o.syncWait = true
+	}
+}
+
+// WithSyncReport makes database report sync progress of every write to the
+// function, which is called every time progress is polled until data is
+// synced. Progress is polled in background unless WithSyncWait is set as
+// well.
+func WithSyncReport(fn SyncReportFunc) Option {
+	return func(o *options) {
+		o.syncReport = fn
+	}
+}
+
+// WithSyncPollInterval sets how often sync progress of writes is polled.
+func WithSyncPollInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.syncPollInterval = interval
+	}
+}
+
+func makeOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
@@ -0,0 +1,165 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethersphere/bee/pkg/swarm"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+// pin pins data with the root address on the local node, when pinning is
+// enabled.
+func (db *bzzdb) pin(addr swarm.Address) error {
+	if !db.pinning {
+		return nil
+	}
+
+	if err := db.beeCli.Pin(db.ctx, addr); err != nil {
+		return fmt.Errorf("failed pinning %s: %w", addr, err)
+	}
+
+	return nil
+}
+
+// unpinPrevious counts value of the reserved update, and releases value of
+// feed update preceding the reservation. Value is unpinned once no key points
+// to it. Unpinning is best effort, because the update is already committed and
+// value left pinned only takes space on the node, so values which failed to be
+// unpinned are retried with next unpin.
+func (db *bzzdb) unpinPrevious(topic client.Topic, reservation *Reservation, ref swarm.Address) {
+	db.valuePins.retain(ref)
+
+	index, ok := reservation.Previous()
+	if !ok {
+		return
+	}
+
+	prev, err := db.updateValueRef(topic, index)
+	if err != nil || prev.IsZero() || !db.valuePins.release(prev) {
+		return
+	}
+
+	db.unpin(prev)
+}
+
+// unpin unpins the value, together with values which failed to be unpinned
+// before.
+func (db *bzzdb) unpin(ref swarm.Address) {
+	for _, ref := range db.valuePins.unpinned(ref) {
+		if err := db.beeCli.Unpin(db.ctx, ref); err != nil {
+			db.valuePins.failed(ref)
+		}
+	}
+}
+
+// valuePins counts keys which point to pinned values, so that value shared by
+// several keys is unpinned only once none of them points to it. Values which
+// were pinned before the database was opened are never unpinned, because keys
+// which point to them are not known.
+type valuePins struct {
+	previous map[string]struct{}
+	counts   map[string]int
+	// failures are values which could not be unpinned.
+	failures map[string]swarm.Address
+	lock     sync.Mutex
+}
+
+//nolint:wrapcheck //relax
+func newValuePins(ctx context.Context, beeCli client.Client) (*valuePins, error) {
+	pins, err := beeCli.ListPins(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &valuePins{
+		previous: make(map[string]struct{}, len(pins.References)),
+		counts:   make(map[string]int),
+		failures: make(map[string]swarm.Address),
+	}
+
+	for _, ref := range pins.References {
+		p.previous[ref.ByteString()] = struct{}{}
+	}
+
+	return p, nil
+}
+
+// retain counts key which points to the value.
+func (p *valuePins) retain(ref swarm.Address) {
+	if ref.Equal(swarm.NewAddress(zeroSocData)) {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.counts[ref.ByteString()]++
+}
+
+// release uncounts key which pointed to the value, and tells whether the
+// value should be unpinned, because no key points to it anymore.
+func (p *valuePins) release(ref swarm.Address) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := ref.ByteString()
+	if _, ok := p.previous[key]; ok {
+		return false
+	}
+
+	count, ok := p.counts[key]
+	if !ok {
+		return false
+	}
+
+	if count > 1 {
+		p.counts[key] = count - 1
+
+		return false
+	}
+
+	delete(p.counts, key)
+
+	return true
+}
+
+// unpinned returns the value together with values which failed to be
+// unpinned before, so that they are unpinned again.
+func (p *valuePins) unpinned(ref swarm.Address) []swarm.Address {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	refs := []swarm.Address{ref}
+	for key, failed := range p.failures {
+		refs = append(refs, failed)
+		delete(p.failures, key)
+	}
+
+	return refs
+}
+
+// failed records value which could not be unpinned.
+func (p *valuePins) failed(ref swarm.Address) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.failures[ref.ByteString()] = ref
+}
+
+func (p *valuePins) stat(property string) (string, bool) {
+	if property != "bzzdb.pins.unpinfailed" {
+		return "", false
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return fmt.Sprint(len(p.failures)), true
+}
@@ -0,0 +1,171 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
+)
+
+func Test_PinningSuite(t *testing.T) {
+	t.Parallel()
+
+	runSuite(t, mock.NewClient(), func() []bzzdb.Option {
+		return []bzzdb.Option{bzzdb.WithPinning(true)}
+	})
+}
+
+func Test_Pinning(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		unpinOverwritten bool
+		opts             []bzzdb.Option
+	}{
+		{name: "keep overwritten", unpinOverwritten: false},
+		{name: "unpin overwritten", unpinOverwritten: true},
+		{name: "unpin overwritten epoch feeds", unpinOverwritten: true, opts: []bzzdb.Option{bzzdb.WithEpochFeeds()}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			privateKey, err := crypto.GenerateSecp256k1Key()
+			assert.NoError(t, err)
+
+			beeCli := mock.NewClient()
+			stamp := buyStamp(t, beeCli)
+
+			opts := append([]bzzdb.Option{bzzdb.WithPinning(tc.unpinOverwritten)}, tc.opts...)
+
+			db, err := bzzdb.New(privateKey, beeCli, stamp, opts...)
+			assert.NoError(t, err)
+
+			key := []byte("key")
+			value1 := []byte("value-1")
+			value2 := []byte("value-2")
+
+			ref1 := reference(t, beeCli, stamp, value1)
+			ref2 := reference(t, beeCli, stamp, value2)
+
+			assert.NoError(t, db.Put(key, value1))
+			assertPinned(t, beeCli, ref1, true)
+
+			// Feed updates are pinned as well
+			pins, err := beeCli.ListPins(context.Background())
+			assert.NoError(t, err)
+			assert.Greater(t, len(pins.References), 1)
+
+			assert.NoError(t, db.Put(key, value2))
+			assertPinned(t, beeCli, ref1, !tc.unpinOverwritten)
+			assertPinned(t, beeCli, ref2, true)
+
+			assert.NoError(t, db.Delete(key))
+			assertPinned(t, beeCli, ref2, !tc.unpinOverwritten)
+
+			assert.NoError(t, db.Close())
+		})
+	}
+}
+
+func Test_PinningSharedValue(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := mock.NewClient()
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithPinning(true))
+	assert.NoError(t, err)
+
+	value := []byte("shared value")
+	ref := reference(t, beeCli, stamp, value)
+
+	assert.NoError(t, db.Put([]byte("key-1"), value))
+	assert.NoError(t, db.Put([]byte("key-2"), value))
+
+	// Value stays pinned while other key points to it
+	assert.NoError(t, db.Put([]byte("key-1"), []byte("other value")))
+	assertPinned(t, beeCli, ref, true)
+	assertGet(t, db, []byte("key-2"), value)
+
+	assert.NoError(t, db.Delete([]byte("key-2")))
+	assertPinned(t, beeCli, ref, false)
+
+	assert.NoError(t, db.Close())
+}
+
+func Test_PinningPreviousValue(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := mock.NewClient()
+	stamp := buyStamp(t, beeCli)
+
+	value := []byte("value")
+	ref := reference(t, beeCli, stamp, value)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithPinning(true))
+	assert.NoError(t, err)
+	assert.NoError(t, db.Put([]byte("key-1"), value))
+	assert.NoError(t, db.Close())
+
+	// Value pinned before the database was opened may be pointed to by keys
+	// which were not written since, so it is not unpinned.
+	db, err = bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithPinning(true))
+	assert.NoError(t, err)
+	assert.NoError(t, db.Put([]byte("key-2"), value))
+	assert.NoError(t, db.Put([]byte("key-2"), []byte("other value")))
+	assertPinned(t, beeCli, ref, true)
+	assertGet(t, db, []byte("key-1"), value)
+
+	assert.NoError(t, db.Close())
+}
+
+func Test_PinningUnpinFailure(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := mock.NewClientWithOptions(mock.WithFaults(mock.Fault{Method: mock.MethodUnpin, Nth: 1}))
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithPinning(true))
+	assert.NoError(t, err)
+
+	stat := func() string {
+		t.Helper()
+
+		value, err := db.Stat("bzzdb.pins.unpinfailed")
+		assert.NoError(t, err)
+
+		return value
+	}
+
+	assert.NoError(t, db.Put([]byte("key"), []byte("value-1")))
+	assert.Equal(t, "0", stat())
+
+	// Overwrite succeeds, while failed unpin is reported
+	assert.NoError(t, db.Put([]byte("key"), []byte("value-2")))
+	assert.Equal(t, "1", stat())
+	assertPinned(t, beeCli, reference(t, beeCli, stamp, []byte("value-1")), true)
+
+	assert.NoError(t, db.Close())
+}
@@ -0,0 +1,354 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+const (
+	// defaultSyncPollInterval is interval of polling sync progress, when it
+	// is not set by WithSyncPollInterval.
+	defaultSyncPollInterval = time.Second
+
+	// maxTagWrites is number of writes which share single tag at most. Next
+	// write starts new tag, so that write overlapping with steady stream of
+	// other writes waits for bounded number of chunks.
+	maxTagWrites = 64
+)
+
+// SyncStatus reports progress of syncing data of single write to the network.
+type SyncStatus struct {
+	// Split is number of chunks uploaded by the write and by writes which
+	// overlapped with it.
+	Split int64
+	// Synced is number of those chunks which are synced to the network, or
+	// which already existed in the network.
+	Synced int64
+	// Done tells that all chunks are synced.
+	Done bool
+	// Err is set when sync progress can not be tracked anymore, such as when
+	// database is closed.
+	Err error
+}
+
+// SyncReportFunc is called with sync progress of every write.
+type SyncReportFunc func(SyncStatus)
+
+// syncTracker tags uploads of writes, so that sync of written data to the
+// network can be followed. Writes in progress share the same tag, because
+// uploads of key index coalesce changes of concurrent writes. Progress of
+// every tag is polled by single poller, which reports it to all writes
+// waiting for the tag.
+type syncTracker struct {
+	beeCli   client.Client
+	wait     bool
+	report   SyncReportFunc
+	interval time.Duration
+
+	lock   sync.Mutex
+	tag    client.TagID
+	joined int // number of writes which shared current tag
+	writes map[*syncWrite]struct{}
+	tags   map[client.TagID]*tagState
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// syncWrite holds tags of all uploads made while the write was in progress.
+type syncWrite struct {
+	tags   []client.TagID
+	status SyncStatus
+	doneC  chan struct{} // closed when data is synced or sync failed
+}
+
+// tagState holds last polled progress of the tag and writes waiting for it.
+type tagState struct {
+	used    bool // some upload was tagged with the tag
+	polled  bool
+	polling bool
+	resp    client.TagResponse
+	waiting map[*syncWrite]struct{}
+}
+
+func newSyncTracker(beeCli client.Client, opts options) *syncTracker {
+	interval := opts.syncPollInterval
+	if interval <= 0 {
+		interval = defaultSyncPollInterval
+	}
+
+	return &syncTracker{
+		beeCli:   beeCli,
+		wait:     opts.syncWait,
+		report:   opts.syncReport,
+		interval: interval,
+		writes:   make(map[*syncWrite]struct{}),
+		tags:     make(map[client.TagID]*tagState),
+	}
+}
+
+// begin starts tracking of a write. New tag is created when there is no
+// write in progress or current tag is shared by too many writes.
+//
+//nolint:wrapcheck //relax
+func (t *syncTracker) begin(ctx context.Context) (*syncWrite, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if len(t.writes) == 0 || t.joined >= maxTagWrites {
+		tag, err := t.beeCli.CreateTag(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		prev := t.tag
+
+		t.tag = tag.UID
+		t.joined = 0
+		t.tags[tag.UID] = &tagState{waiting: make(map[*syncWrite]struct{})}
+		t.prune(prev)
+
+		// Writes in progress upload the rest of their data with new tag.
+		for w := range t.writes {
+			w.tags = append(w.tags, tag.UID)
+		}
+	}
+
+	t.joined++
+
+	w := &syncWrite{
+		tags:  []client.TagID{t.tag},
+		doneC: make(chan struct{}),
+	}
+	t.writes[w] = struct{}{}
+
+	return w, nil
+}
+
+// end stops tracking of the write. Sync of written data is followed unless
+// the write failed.
+func (t *syncTracker) end(ctx context.Context, w *syncWrite, writeErr error) error {
+	t.lock.Lock()
+	delete(t.writes, w)
+
+	if writeErr != nil {
+		for _, uid := range w.tags {
+			t.prune(uid)
+		}
+
+		t.lock.Unlock()
+
+		return writeErr
+	}
+
+	status, finished := t.follow(ctx, w)
+	t.lock.Unlock()
+
+	if finished && t.report != nil {
+		t.report(status)
+	}
+
+	if !t.wait {
+		return nil
+	}
+
+	<-w.doneC
+
+	return w.status.Err
+}
+
+// context returns context which tags uploads with current tag.
+func (t *syncTracker) context(ctx context.Context) context.Context {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if len(t.writes) == 0 {
+		return ctx
+	}
+
+	t.tags[t.tag].used = true
+
+	return client.WithTag(ctx, t.tag)
+}
+
+// close waits for pollers, which stop once ctx passed to end is done.
+func (t *syncTracker) close() {
+	t.lock.Lock()
+	t.closed = true
+	t.lock.Unlock()
+
+	t.wg.Wait()
+}
+
+// follow makes the write wait for its tags, and starts pollers of tags which
+// are not polled yet. Write which did not upload anything with any of its
+// tags is finished right away. It must be called with lock held.
+func (t *syncTracker) follow(ctx context.Context, w *syncWrite) (SyncStatus, bool) {
+	if t.closed {
+		status := SyncStatus{Err: fmt.Errorf("sync is not confirmed: %w", ctx.Err())}
+		t.finish(w, status)
+
+		return status, true
+	}
+
+	used := false
+
+	for _, uid := range w.tags {
+		st := t.tags[uid]
+		if st == nil || !st.used {
+			t.prune(uid)
+
+			continue
+		}
+
+		used = true
+		st.waiting[w] = struct{}{}
+
+		if !st.polling {
+			st.polling = true
+
+			t.wg.Add(1)
+
+			go t.poll(ctx, uid)
+		}
+	}
+
+	if !used {
+		status := SyncStatus{Done: true}
+		t.finish(w, status)
+
+		return status, true
+	}
+
+	return SyncStatus{}, false
+}
+
+// poll fetches progress of the tag until no write waits for it.
+func (t *syncTracker) poll(ctx context.Context, uid client.TagID) {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		tag, err := t.beeCli.Tag(ctx, uid)
+		if err != nil {
+			err = fmt.Errorf("failed fetching tag %d: %w", uid, err)
+		}
+
+		if !t.update(uid, tag, err) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			t.update(uid, client.TagResponse{}, fmt.Errorf("sync is not confirmed: %w", ctx.Err()))
+
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// update records progress of the tag and reports it to writes waiting for
+// the tag. Writes whose data is synced, and all writes when tracking of the
+// tag failed, are finished. It tells whether some write still waits.
+func (t *syncTracker) update(uid client.TagID, tag client.TagResponse, tagErr error) bool {
+	t.lock.Lock()
+
+	st := t.tags[uid]
+	if tagErr == nil {
+		st.resp = tag
+		st.polled = true
+	}
+
+	reports := make([]SyncStatus, 0, len(st.waiting))
+
+	for w := range st.waiting {
+		status := t.status(w)
+		if tagErr != nil {
+			status = SyncStatus{Err: tagErr}
+		}
+
+		if status.Done || status.Err != nil {
+			t.finish(w, status)
+		}
+
+		reports = append(reports, status)
+	}
+
+	waiting := len(st.waiting) > 0
+	if !waiting {
+		st.polling = false
+		t.prune(uid)
+	}
+
+	t.lock.Unlock()
+
+	if t.report != nil {
+		for _, status := range reports {
+			t.report(status)
+		}
+	}
+
+	return waiting
+}
+
+// status sums last polled progress of tags of the write. It must be called
+// with lock held.
+func (t *syncTracker) status(w *syncWrite) SyncStatus {
+	status := SyncStatus{Done: true}
+
+	for _, uid := range w.tags {
+		st := t.tags[uid]
+		if st == nil || !st.used {
+			continue
+		}
+
+		status.Split += st.resp.Split
+		status.Synced += st.resp.Synced + st.resp.Seen
+		status.Done = status.Done && st.polled && st.resp.Done()
+	}
+
+	return status
+}
+
+// finish stops waiting of the write with final status. It must be called
+// with lock held.
+func (t *syncTracker) finish(w *syncWrite, status SyncStatus) {
+	w.status = status
+	close(w.doneC)
+
+	for _, uid := range w.tags {
+		if st := t.tags[uid]; st != nil {
+			delete(st.waiting, w)
+			t.prune(uid)
+		}
+	}
+}
+
+// prune forgets the tag once it is not current tag, it is not polled and no
+// write uses it anymore. It must be called with lock held.
+func (t *syncTracker) prune(uid client.TagID) {
+	st := t.tags[uid]
+	if st == nil || uid == t.tag || st.polling || len(st.waiting) > 0 {
+		return
+	}
+
+	for w := range t.writes {
+		for _, used := range w.tags {
+			if used == uid {
+				return
+			}
+		}
+	}
+
+	delete(t.tags, uid)
+}
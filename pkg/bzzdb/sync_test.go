@@ -0,0 +1,218 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb"
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
+)
+
+func Test_SyncWaitSuite(t *testing.T) {
+	t.Parallel()
+
+	runSuite(t, mock.NewClient(), func() []bzzdb.Option {
+		return []bzzdb.Option{
+			bzzdb.WithSyncWait(),
+			bzzdb.WithSyncPollInterval(time.Millisecond),
+		}
+	})
+}
+
+func Test_SyncWait(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	const syncDelay = 100 * time.Millisecond
+
+	beeCli := mock.NewClientWithOptions(mock.WithSyncDelay(syncDelay))
+	stamp := buyStamp(t, beeCli)
+	reports := &syncReports{}
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp,
+		bzzdb.WithSyncWait(),
+		bzzdb.WithSyncReport(reports.add),
+		bzzdb.WithSyncPollInterval(10*time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	start := time.Now()
+	assert.NoError(t, db.Put([]byte("key"), []byte("value")))
+	assert.GreaterOrEqual(t, time.Since(start), syncDelay)
+
+	// Progress is reported until all chunks are synced
+	all := reports.all()
+	assert.Greater(t, len(all), 1)
+	assert.False(t, all[0].Done)
+	assert.Zero(t, all[0].Synced)
+
+	last := all[len(all)-1]
+	assert.True(t, last.Done)
+	assert.NoError(t, last.Err)
+	assert.Positive(t, last.Split)
+	assert.Equal(t, last.Split, last.Synced)
+
+	batch := db.NewBatch()
+	assert.NoError(t, batch.Put([]byte("a"), []byte("value-a")))
+	assert.NoError(t, batch.Put([]byte("b"), []byte("value-b")))
+
+	start = time.Now()
+	assert.NoError(t, batch.Write())
+	assert.GreaterOrEqual(t, time.Since(start), syncDelay)
+
+	assertGet(t, db, []byte("a"), []byte("value-a"))
+	assert.NoError(t, db.Close())
+}
+
+func Test_SyncWaitWriteBack(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	const syncDelay = 100 * time.Millisecond
+
+	beeCli := mock.NewClientWithOptions(mock.WithSyncDelay(syncDelay))
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp,
+		bzzdb.WithWriteBack(t.TempDir(), 1),
+		bzzdb.WithSyncWait(),
+		bzzdb.WithSyncPollInterval(10*time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	// Buffered write is flushed only once it is synced
+	start := time.Now()
+	assert.NoError(t, db.Put([]byte("key"), []byte("value")))
+	assert.NoError(t, db.Flush(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), syncDelay)
+
+	assert.NoError(t, db.Close())
+}
+
+func Test_SyncReport(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := mock.NewClientWithOptions(mock.WithSyncDelay(time.Hour))
+	stamp := buyStamp(t, beeCli)
+	reports := &syncReports{}
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp,
+		bzzdb.WithSyncReport(reports.add),
+		bzzdb.WithSyncPollInterval(time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	// Write does not wait for sync, which is reported in background
+	assert.NoError(t, db.Put([]byte("key"), []byte("value")))
+	assert.Eventually(t, func() bool { return len(reports.all()) > 0 }, time.Second, time.Millisecond)
+
+	status := reports.all()[0]
+	assert.False(t, status.Done)
+	assert.Positive(t, status.Split)
+
+	// Tracking stops with error when database is closed, which waits for
+	// polling to stop
+	assert.NoError(t, db.Close())
+
+	all := reports.all()
+	assert.Error(t, all[len(all)-1].Err)
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, reports.all(), len(all))
+}
+
+func Test_SyncReportSharedTag(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	// Latency makes writes overlap, so that they share tags
+	beeCli := &tagCountingClient{Client: mock.NewClientWithOptions(
+		mock.WithSyncDelay(50*time.Millisecond),
+		mock.WithLatency(mock.FixedLatency(5*time.Millisecond)),
+	)}
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp,
+		bzzdb.WithSyncWait(),
+		bzzdb.WithSyncPollInterval(10*time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	const writes = 8
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < writes; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			assert.NoError(t, db.Put([]byte{byte(i)}, []byte("value")))
+		}(i)
+	}
+
+	wg.Wait()
+
+	// Writes which share a tag share its poller, so tag is polled about
+	// once per interval regardless of number of writes
+	assert.Less(t, beeCli.count(), int64(writes*5))
+
+	assert.NoError(t, db.Close())
+}
+
+// tagCountingClient counts calls of Tag.
+type tagCountingClient struct {
+	client.Client
+	calls atomic.Int64
+}
+
+func (c *tagCountingClient) Tag(ctx context.Context, uid client.TagID) (client.TagResponse, error) {
+	c.calls.Add(1)
+
+	return c.Client.Tag(ctx, uid) //nolint:wrapcheck //relax
+}
+
+func (c *tagCountingClient) count() int64 {
+	return c.calls.Load()
+}
+
+// syncReports collects reported sync progress.
+type syncReports struct {
+	statuses []bzzdb.SyncStatus
+	lock     sync.Mutex
+}
+
+func (r *syncReports) add(status bzzdb.SyncStatus) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.statuses = append(r.statuses, status)
+}
+
+func (r *syncReports) all() []bzzdb.SyncStatus {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]bzzdb.SyncStatus{}, r.statuses...)
+}
@@ -0,0 +1,499 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+const (
+	// defaultWriteBackWorkers is number of workers used when WithWriteBack
+	// option does not specify it.
+	defaultWriteBackWorkers = 16
+
+	// writeBackRetries is number of times failed write is retried before
+	// worker gives up on it. Write which failed is kept in the log and it is
+	// enqueued again after retry interval, when it is written again or when
+	// database is reopened.
+	writeBackRetries = 3
+
+	// defaultWriteBackRetryInterval is how often failed writes are enqueued
+	// again, unless WithWriteBackRetryInterval option sets it.
+	defaultWriteBackRetryInterval = 30 * time.Second
+
+	// defaultWriteBackCloseTimeout is how long Close waits for pending writes,
+	// unless WithWriteBackCloseTimeout option sets it.
+	defaultWriteBackCloseTimeout = time.Minute
+
+	// writeBackRetryDelay is delay before first retry, which is doubled for
+	// every following retry.
+	writeBackRetryDelay = 50 * time.Millisecond
+
+	walRecordDeleted = 1
+)
+
+//nolint:gochecknoglobals
+var (
+	errCorruptWAL      = errors.New("corrupt write-ahead log record")
+	errWriteBackClosed = errors.New("write-back is closed")
+)
+
+// writeBack stores writes in durable write-ahead log and uploads them to Swarm
+// in background. Log holds only the latest write of every key, so when key is
+// written multiple times before it is uploaded, only the latest value is
+// uploaded. Writes of the same key are never uploaded concurrently.
+type writeBack struct {
+	db    *bzzdb
+	wal   *leveldb.DB
+	wg    sync.WaitGroup
+	stopC chan struct{}
+
+	closeTimeout time.Duration
+
+	lock     sync.Mutex
+	cond     *sync.Cond // signals workers that queue changed
+	changedC chan struct{}
+	pending  map[string]*pendingWrite
+	queue    []string // keys waiting for upload
+	nextSeq  uint64
+	failed   int
+	stopped  bool
+}
+
+// pendingWrite is the latest write of the key which is not uploaded yet.
+type pendingWrite struct {
+	keyValue
+	seq      uint64 // sequence number of the log record
+	queued   bool
+	inFlight bool
+	err      error // set when write failed after all retries
+}
+
+func newWriteBack(db *bzzdb, opts options) (*writeBack, error) {
+	wal, err := leveldb.OpenFile(opts.writeBackPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening write-ahead log: %w", err)
+	}
+
+	closeTimeout := opts.writeBackCloseTimeout
+	if closeTimeout <= 0 {
+		closeTimeout = defaultWriteBackCloseTimeout
+	}
+
+	retryInterval := opts.writeBackRetryInterval
+	if retryInterval <= 0 {
+		retryInterval = defaultWriteBackRetryInterval
+	}
+
+	wb := &writeBack{
+		db:           db,
+		wal:          wal,
+		stopC:        make(chan struct{}),
+		closeTimeout: closeTimeout,
+		changedC:     make(chan struct{}),
+		pending:      make(map[string]*pendingWrite),
+	}
+	wb.cond = sync.NewCond(&wb.lock)
+
+	if err := wb.replay(); err != nil {
+		wal.Close()
+
+		return nil, err
+	}
+
+	workers := opts.writeBackWorkers
+	if workers <= 0 {
+		workers = defaultWriteBackWorkers
+	}
+
+	wb.wg.Add(workers + 1)
+
+	for i := 0; i < workers; i++ {
+		go wb.worker()
+	}
+
+	go wb.retrier(retryInterval)
+
+	return wb, nil
+}
+
+// replay enqueues all writes found in the log. Writes which were not uploaded
+// before database was closed (or crashed) are uploaded again.
+func (wb *writeBack) replay() error {
+	it := wb.wal.NewIterator(nil, nil)
+	defer it.Release()
+
+	stale := new(leveldb.Batch)
+
+	for it.Next() {
+		seq := binary.BigEndian.Uint64(it.Key())
+
+		kv, err := decodeWALRecord(it.Value())
+		if err != nil {
+			return err
+		}
+
+		key := string(kv.key)
+		if pw, ok := wb.pending[key]; ok {
+			stale.Delete(seqKey(pw.seq))
+		} else {
+			wb.queue = append(wb.queue, key)
+		}
+
+		wb.pending[key] = &pendingWrite{keyValue: kv, seq: seq, queued: true}
+		wb.nextSeq = seq + 1
+	}
+
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("failed reading write-ahead log: %w", err)
+	}
+
+	if err := wb.wal.Write(stale, nil); err != nil {
+		return fmt.Errorf("failed writing write-ahead log: %w", err)
+	}
+
+	return nil
+}
+
+// write stores writes in the log and enqueues them for upload.
+func (wb *writeBack) write(writes []keyValue) error {
+	wb.lock.Lock()
+	defer wb.lock.Unlock()
+
+	if wb.stopped {
+		return errWriteBackClosed
+	}
+
+	walBatch := new(leveldb.Batch)
+	seqs := make([]uint64, len(writes))
+
+	for i, kv := range writes {
+		seqs[i] = wb.nextSeq + uint64(i)
+		walBatch.Put(seqKey(seqs[i]), encodeWALRecord(kv))
+
+		if pw, ok := wb.pending[string(kv.key)]; ok {
+			walBatch.Delete(seqKey(pw.seq))
+		}
+	}
+
+	if err := wb.wal.Write(walBatch, &opt.WriteOptions{Sync: true}); err != nil {
+		return fmt.Errorf("failed writing write-ahead log: %w", err)
+	}
+
+	wb.nextSeq += uint64(len(writes))
+
+	for i, kv := range writes {
+		key := string(kv.key)
+
+		pw, ok := wb.pending[key]
+		if !ok {
+			pw = &pendingWrite{}
+			wb.pending[key] = pw
+		}
+
+		if pw.err != nil {
+			pw.err = nil
+			wb.failed--
+		}
+
+		pw.keyValue = kv
+		pw.seq = seqs[i]
+
+		if !pw.queued && !pw.inFlight {
+			pw.queued = true
+			wb.queue = append(wb.queue, key)
+		}
+	}
+
+	wb.cond.Broadcast()
+
+	return nil
+}
+
+// get returns pending value of the key. Second return value reports
+// whether key is deleted.
+func (wb *writeBack) get(key []byte) ([]byte, bool, bool) {
+	wb.lock.Lock()
+	defer wb.lock.Unlock()
+
+	pw, ok := wb.pending[string(key)]
+	if !ok {
+		return nil, false, false
+	}
+
+	return common.CopyBytes(pw.value), pw.delete, true
+}
+
+// keys returns sorted pending keys with given prefix, starting at
+// prefix+start.
+func (wb *writeBack) keys(prefix, start []byte) [][]byte {
+	begin := append(common.CopyBytes(prefix), start...)
+
+	wb.lock.Lock()
+	defer wb.lock.Unlock()
+
+	var keys [][]byte
+
+	for _, pw := range wb.pending {
+		if bytes.HasPrefix(pw.key, prefix) && bytes.Compare(pw.key, begin) >= 0 {
+			keys = append(keys, pw.key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+
+	return keys
+}
+
+func (wb *writeBack) worker() {
+	defer wb.wg.Done()
+
+	for {
+		kv, seq, ok := wb.next()
+		if !ok {
+			return
+		}
+
+		wb.done(kv, seq, wb.upload(kv))
+	}
+}
+
+// next blocks until there is a write to upload. It returns false when
+// write-back is stopped.
+func (wb *writeBack) next() (keyValue, uint64, bool) {
+	wb.lock.Lock()
+	defer wb.lock.Unlock()
+
+	for len(wb.queue) == 0 && !wb.stopped {
+		wb.cond.Wait()
+	}
+
+	if wb.stopped {
+		return keyValue{}, 0, false
+	}
+
+	key := wb.queue[0]
+	wb.queue = wb.queue[1:]
+
+	pw := wb.pending[key]
+	pw.queued = false
+	pw.inFlight = true
+
+	return pw.keyValue, pw.seq, true
+}
+
+// upload writes key to Swarm, retrying failed attempts.
+func (wb *writeBack) upload(kv keyValue) error {
+	value := kv.value
+	if kv.delete {
+		value = nil
+	}
+
+	delay := writeBackRetryDelay
+
+	for attempt := 0; ; attempt++ {
+		err := wb.db.writeKey(kv.key, value)
+		if err == nil || attempt == writeBackRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-wb.db.ctx.Done():
+			return err
+		}
+	}
+}
+
+// done removes uploaded write from the log, unless key was written again in
+// the meantime, in which case the key is enqueued again.
+func (wb *writeBack) done(kv keyValue, seq uint64, err error) {
+	wb.lock.Lock()
+	defer wb.lock.Unlock()
+
+	key := string(kv.key)
+	pw := wb.pending[key]
+	pw.inFlight = false
+
+	switch {
+	case pw.seq != seq:
+		pw.queued = true
+		wb.queue = append(wb.queue, key)
+		wb.cond.Signal()
+	case err != nil:
+		pw.err = err
+		wb.failed++
+	default:
+		// Write stays in pending map when it can not be removed from the log,
+		// so that reads stay consistent with the log.
+		if err := wb.wal.Delete(seqKey(seq), nil); err != nil {
+			pw.err = fmt.Errorf("failed writing write-ahead log: %w", err)
+			wb.failed++
+		} else {
+			delete(wb.pending, key)
+		}
+	}
+
+	close(wb.changedC)
+	wb.changedC = make(chan struct{})
+}
+
+// retrier enqueues failed writes again every interval, until write-back is
+// stopped.
+func (wb *writeBack) retrier(interval time.Duration) {
+	defer wb.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			wb.retry()
+		case <-wb.stopC:
+			return
+		}
+	}
+}
+
+// retry enqueues writes which failed after all retries again.
+func (wb *writeBack) retry() {
+	wb.lock.Lock()
+	defer wb.lock.Unlock()
+
+	for key, pw := range wb.pending {
+		if pw.err == nil {
+			continue
+		}
+
+		pw.err = nil
+		wb.failed--
+		pw.queued = true
+		wb.queue = append(wb.queue, key)
+	}
+
+	wb.cond.Broadcast()
+}
+
+// flush waits until all pending writes are uploaded. It returns error when
+// some writes failed and there is nothing else to upload.
+func (wb *writeBack) flush(ctx context.Context) error {
+	for {
+		wb.lock.Lock()
+		pending, failed := len(wb.pending), wb.failed
+		changedC := wb.changedC
+
+		var err error
+
+		if failed > 0 && failed == pending {
+			for _, pw := range wb.pending {
+				if pw.err != nil {
+					err = pw.err
+
+					break
+				}
+			}
+		}
+		wb.lock.Unlock()
+
+		if pending == 0 {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed uploading %d pending writes: %w", failed, err)
+		}
+
+		select {
+		case <-changedC:
+		case <-ctx.Done():
+			return fmt.Errorf("flush interrupted: %w", ctx.Err())
+		}
+	}
+}
+
+// close waits for pending writes to be uploaded, at most for close timeout,
+// and stops workers. Uploads which are still in flight are interrupted, so
+// that stalled upload does not block closing. Writes which failed or were
+// interrupted are kept in the log.
+func (wb *writeBack) close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), wb.closeTimeout)
+	flushErr := wb.flush(ctx)
+
+	cancel()
+
+	wb.lock.Lock()
+	wb.stopped = true
+	wb.cond.Broadcast()
+	wb.lock.Unlock()
+
+	close(wb.stopC)
+	wb.db.ctxCancel()
+	wb.wg.Wait()
+
+	if err := wb.wal.Close(); err != nil {
+		return fmt.Errorf("failed closing write-ahead log: %w", err)
+	}
+
+	return flushErr
+}
+
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+
+	return key
+}
+
+// encodeWALRecord encodes write as deleted flag, key length, key and value.
+func encodeWALRecord(kv keyValue) []byte {
+	data := make([]byte, 1, 1+binary.MaxVarintLen64+len(kv.key)+len(kv.value))
+
+	if kv.delete {
+		data[0] = walRecordDeleted
+	}
+
+	data = binary.AppendUvarint(data, uint64(len(kv.key)))
+	data = append(data, kv.key...)
+	data = append(data, kv.value...)
+
+	return data
+}
+
+func decodeWALRecord(data []byte) (keyValue, error) {
+	if len(data) == 0 {
+		return keyValue{}, errCorruptWAL
+	}
+
+	keyLen, n := binary.Uvarint(data[1:])
+	if n <= 0 || uint64(len(data)-1-n) < keyLen {
+		return keyValue{}, errCorruptWAL
+	}
+
+	deleted := data[0] == walRecordDeleted
+	data = data[1+n:]
+
+	kv := keyValue{
+		key:    common.CopyBytes(data[:keyLen]),
+		value:  common.CopyBytes(data[keyLen:]),
+		delete: deleted,
+	}
+
+	return kv, nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzzdb_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/bzzdb"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
+)
+
+func Test_WriteBackSuite(t *testing.T) {
+	t.Parallel()
+
+	runSuite(t, mock.NewClient(), func() []bzzdb.Option {
+		return []bzzdb.Option{bzzdb.WithWriteBack(t.TempDir(), 0)}
+	})
+}
+
+// Test_ChaosSuite runs database suite against client with latency, short
+// reads and failing uploads. Failed uploads are retried in write-back mode,
+// so the database behaves as with reliable client.
+func Test_ChaosSuite(t *testing.T) {
+	t.Parallel()
+
+	beeCli := mock.NewClientWithOptions(
+		mock.WithSeed(1),
+		mock.WithLatency(mock.UniformLatency(0, time.Millisecond)),
+		mock.WithPartialReads(0),
+		mock.WithFaults(
+			mock.Fault{Method: mock.MethodUploadBytes, Probability: 0.02},
+			mock.Fault{Method: mock.MethodUploadSoc, Probability: 0.02},
+		),
+	)
+
+	runSuite(t, beeCli, func() []bzzdb.Option {
+		return []bzzdb.Option{bzzdb.WithWriteBack(t.TempDir(), 0)}
+	})
+}
+
+func Test_WriteBack(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := &failingClient{Client: mock.NewClient()}
+	stamp := buyStamp(t, beeCli)
+	walPath := t.TempDir()
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithWriteBack(walPath, 4))
+	assert.NoError(t, err)
+
+	const count = 20
+
+	for i := 0; i < count; i++ {
+		assert.NoError(t, db.Put(writeBackKey(i), []byte("old")))
+		assert.NoError(t, db.Put(writeBackKey(i), writeBackKey(i)))
+	}
+
+	assert.NoError(t, db.Flush(context.Background()))
+
+	// Writes which can not be uploaded stay buffered
+	beeCli.fail.Store(true)
+
+	b := db.NewBatch()
+	for i := 0; i < count; i++ {
+		assert.NoError(t, b.Put(writeBackKey(i), []byte(fmt.Sprintf("new-%d", i))))
+	}
+	assert.NoError(t, b.Delete(writeBackKey(0)))
+	assert.NoError(t, b.Write())
+
+	assert.Error(t, db.Flush(context.Background()))
+
+	_, err = db.Get(writeBackKey(0))
+	assert.Error(t, err)
+	assertGet(t, db, writeBackKey(1), []byte("new-1"))
+	assert.Len(t, iterateKeys(t, db.NewIterator(nil, nil)), count-1)
+
+	assert.Error(t, db.Close())
+
+	// Buffered writes are uploaded by new instance of database
+	beeCli.fail.Store(false)
+
+	db, err = bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithWriteBack(walPath, 4))
+	assert.NoError(t, err)
+	assert.NoError(t, db.Flush(context.Background()))
+	assert.NoError(t, db.Close())
+
+	db, err = bzzdb.New(privateKey, beeCli, stamp)
+	assert.NoError(t, err)
+
+	_, err = db.Get(writeBackKey(0))
+	assert.Error(t, err)
+
+	for i := 1; i < count; i++ {
+		assertGet(t, db, writeBackKey(i), []byte(fmt.Sprintf("new-%d", i)))
+	}
+
+	assert.Len(t, iterateKeys(t, db.NewIterator(nil, nil)), count-1)
+	assert.NoError(t, db.Close())
+}
+
+func Test_WriteBackRetry(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := &failingClient{Client: mock.NewClient()}
+	stamp := buyStamp(t, beeCli)
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp,
+		bzzdb.WithWriteBack(t.TempDir(), 0),
+		bzzdb.WithWriteBackRetryInterval(10*time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	beeCli.fail.Store(true)
+	assert.NoError(t, db.Put([]byte("key"), []byte("value")))
+	assert.Error(t, db.Flush(context.Background()))
+
+	// Failed write is uploaded once client recovers
+	beeCli.fail.Store(false)
+	assert.Eventually(t, func() bool {
+		return db.Flush(context.Background()) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, db.Close())
+
+	db, err = bzzdb.New(privateKey, beeCli, stamp)
+	assert.NoError(t, err)
+	assertGet(t, db, []byte("key"), []byte("value"))
+	assert.NoError(t, db.Close())
+}
+
+func Test_WriteBackCloseTimeout(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	beeCli := &failingClient{Client: mock.NewClient()}
+	stamp := buyStamp(t, beeCli)
+	walPath := t.TempDir()
+
+	db, err := bzzdb.New(privateKey, beeCli, stamp,
+		bzzdb.WithWriteBack(walPath, 0),
+		bzzdb.WithWriteBackCloseTimeout(50*time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	// Close does not wait for stalled upload longer than close timeout
+	beeCli.stall.Store(true)
+	assert.NoError(t, db.Put([]byte("key"), []byte("value")))
+
+	start := time.Now()
+	assert.Error(t, db.Close())
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// Interrupted write is uploaded by new instance of database
+	beeCli.stall.Store(false)
+
+	db, err = bzzdb.New(privateKey, beeCli, stamp, bzzdb.WithWriteBack(walPath, 0))
+	assert.NoError(t, err)
+	assert.NoError(t, db.Flush(context.Background()))
+	assertGet(t, db, []byte("key"), []byte("value"))
+	assert.NoError(t, db.Close())
+}
+
+func writeBackKey(i int) []byte {
+	return []byte(fmt.Sprintf("key-%02d", i))
+}
@@ -0,0 +1,179 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package chunker splits data into tree of content addressed chunks locally,
+// the same way Bee node does when data is uploaded via /bytes endpoint, so
+// that chunks can be deduplicated and uploaded one by one.
+package chunker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ethersphere/bee/pkg/cac"
+	"github.com/ethersphere/bee/pkg/swarm"
+)
+
+//nolint:gochecknoglobals
+var errEmptyTree = errors.New("chunk tree has no root")
+
+// ChunkFunc is called with every chunk of the tree. Intermediate chunks are
+// passed after all chunks they reference.
+type ChunkFunc func(swarm.Chunk) error
+
+// Split reads data from the reader and splits it into chunks, which are
+// passed to the function. It returns address of the root chunk, which is
+// reference of the data. At most one chunk per tree level is held in memory.
+//
+//nolint:wrapcheck //relax
+func Split(reader io.Reader, fn ChunkFunc) (swarm.Address, error) {
+	s := &splitter{fn: fn}
+	buf := make([]byte, swarm.ChunkSize)
+
+	for {
+		n, err := io.ReadFull(reader, buf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return swarm.ZeroAddress, fmt.Errorf("failed reading data: %w", err)
+		}
+
+		ch, chErr := cac.New(buf[:n])
+		if chErr != nil {
+			return swarm.ZeroAddress, chErr
+		}
+
+		if err := s.add(0, ch, uint64(n)); err != nil {
+			return swarm.ZeroAddress, err
+		}
+
+		if n < swarm.ChunkSize {
+			break
+		}
+	}
+
+	return s.sum()
+}
+
+// Chunks splits data into chunks and returns root address and all chunks of
+// the tree.
+func Chunks(data []byte) (swarm.Address, []swarm.Chunk, error) {
+	var chunks []swarm.Chunk
+
+	root, err := Split(bytes.NewReader(data), func(ch swarm.Chunk) error {
+		chunks = append(chunks, ch)
+
+		return nil
+	})
+
+	return root, chunks, err
+}
+
+// splitter builds the tree level by level. Every level holds references of
+// chunks which are not yet referenced by chunk of the level above.
+type splitter struct {
+	fn     ChunkFunc
+	levels []level
+	chunks int
+}
+
+type level struct {
+	refs []byte
+	span uint64
+}
+
+// add passes chunk of the level to the function and appends its reference,
+// which covers span bytes of data, to the level.
+func (s *splitter) add(l int, ch swarm.Chunk, span uint64) error {
+	if err := s.fn(ch); err != nil {
+		return err
+	}
+
+	s.chunks++
+
+	return s.push(l, ch.Address(), span)
+}
+
+// push appends reference to the level, and wraps the level into intermediate
+// chunk once it is full.
+func (s *splitter) push(l int, addr swarm.Address, span uint64) error {
+	if l == len(s.levels) {
+		s.levels = append(s.levels, level{refs: make([]byte, 0, swarm.ChunkSize)})
+	}
+
+	s.levels[l].refs = append(s.levels[l].refs, addr.Bytes()...)
+	s.levels[l].span += span
+
+	if len(s.levels[l].refs) == swarm.ChunkSize {
+		return s.wrap(l)
+	}
+
+	return nil
+}
+
+// wrap creates intermediate chunk of references of the level and pushes it
+// to the level above.
+//
+//nolint:wrapcheck //relax
+func (s *splitter) wrap(l int) error {
+	lv := s.levels[l]
+
+	data := make([]byte, swarm.SpanSize, swarm.SpanSize+len(lv.refs))
+	binary.LittleEndian.PutUint64(data, lv.span)
+	data = append(data, lv.refs...)
+
+	s.levels[l] = level{refs: lv.refs[:0]}
+
+	ch, err := cac.NewWithDataSpan(data)
+	if err != nil {
+		return err
+	}
+
+	return s.add(l+1, ch, lv.span)
+}
+
+// sum wraps remaining references of all levels and returns root address.
+// Single reference left on the level is carried to the level above without
+// wrapping, which matches tree built by Bee node.
+//
+//nolint:wrapcheck //relax
+func (s *splitter) sum() (swarm.Address, error) {
+	if s.chunks == 0 {
+		ch, err := cac.New(nil)
+		if err != nil {
+			return swarm.ZeroAddress, err
+		}
+
+		return ch.Address(), s.fn(ch)
+	}
+
+	for l := 0; l < len(s.levels); l++ {
+		lv := s.levels[l]
+		refs := len(lv.refs) / swarm.HashSize
+
+		switch {
+		case refs == 0:
+			continue
+		case refs == 1 && l == len(s.levels)-1:
+			return swarm.NewAddress(append([]byte{}, lv.refs...)), nil
+		case refs == 1:
+			s.levels[l] = level{refs: lv.refs[:0]}
+
+			if err := s.push(l+1, swarm.NewAddress(append([]byte{}, lv.refs...)), lv.span); err != nil {
+				return swarm.ZeroAddress, err
+			}
+		default:
+			if err := s.wrap(l); err != nil {
+				return swarm.ZeroAddress, err
+			}
+		}
+	}
+
+	return swarm.ZeroAddress, errEmptyTree
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chunker_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ethersphere/bee/pkg/cac"
+	"github.com/ethersphere/bee/pkg/swarm"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/chunker"
+)
+
+func Test_Chunks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		size   int
+		chunks int
+	}{
+		{size: 0, chunks: 1},
+		{size: 1, chunks: 1},
+		{size: swarm.ChunkSize, chunks: 1},
+		{size: swarm.ChunkSize + 1, chunks: 3},
+		{size: swarm.Branches * swarm.ChunkSize, chunks: swarm.Branches + 1},
+		// Single leaf left over is referenced by root directly
+		{size: (swarm.Branches + 1) * swarm.ChunkSize, chunks: swarm.Branches + 3},
+		{size: (swarm.Branches+2)*swarm.ChunkSize + 5, chunks: swarm.Branches + 6},
+	}
+
+	for _, tc := range tests {
+		data := randomBytes(t, tc.size)
+
+		root, chunks, err := chunker.Chunks(data)
+		assert.NoError(t, err)
+		assert.Len(t, chunks, tc.chunks, "size %d", tc.size)
+
+		// Chunks are passed after all chunks they reference, so root is last
+		assert.Equal(t, root, chunks[len(chunks)-1].Address())
+
+		store := make(map[string][]byte, len(chunks))
+		for _, ch := range chunks {
+			assertChildrenStored(t, store, ch.Data())
+			store[ch.Address().ByteString()] = ch.Data()
+		}
+
+		assert.Equal(t, data, join(t, store, root), "size %d", tc.size)
+	}
+}
+
+func Test_ChunksRoot(t *testing.T) {
+	t.Parallel()
+
+	data := randomBytes(t, swarm.ChunkSize+1)
+
+	first, err := cac.New(data[:swarm.ChunkSize])
+	assert.NoError(t, err)
+
+	second, err := cac.New(data[swarm.ChunkSize:])
+	assert.NoError(t, err)
+
+	intermediate := binary.LittleEndian.AppendUint64(nil, uint64(len(data)))
+	intermediate = append(intermediate, first.Address().Bytes()...)
+	intermediate = append(intermediate, second.Address().Bytes()...)
+
+	want, err := cac.NewWithDataSpan(intermediate)
+	assert.NoError(t, err)
+
+	root, _, err := chunker.Chunks(data)
+	assert.NoError(t, err)
+	assert.Equal(t, want.Address(), root)
+
+	// Data of single chunk has address of the chunk
+	root, _, err = chunker.Chunks(data[:10])
+	assert.NoError(t, err)
+
+	leaf, err := cac.New(data[:10])
+	assert.NoError(t, err)
+	assert.Equal(t, leaf.Address(), root)
+}
+
+func Test_Split(t *testing.T) {
+	t.Parallel()
+
+	data := randomBytes(t, 3*swarm.ChunkSize+7)
+
+	want, _, err := chunker.Chunks(data)
+	assert.NoError(t, err)
+
+	// Reader returning short reads is split the same way
+	root, err := chunker.Split(iotest.OneByteReader(bytes.NewReader(data)), func(swarm.Chunk) error { return nil })
+	assert.NoError(t, err)
+	assert.Equal(t, want, root)
+
+	errTest := errors.New("test")
+
+	_, err = chunker.Split(iotest.ErrReader(errTest), func(swarm.Chunk) error { return nil })
+	assert.ErrorIs(t, err, errTest)
+
+	_, err = chunker.Split(bytes.NewReader(data), func(swarm.Chunk) error { return errTest })
+	assert.ErrorIs(t, err, errTest)
+}
+
+func assertChildrenStored(t *testing.T, store map[string][]byte, data []byte) {
+	t.Helper()
+
+	if binary.LittleEndian.Uint64(data[:swarm.SpanSize]) <= swarm.ChunkSize {
+		return
+	}
+
+	for refs := data[swarm.SpanSize:]; len(refs) > 0; refs = refs[swarm.HashSize:] {
+		_, ok := store[string(refs[:swarm.HashSize])]
+		assert.True(t, ok, "chunk is passed before its children")
+	}
+}
+
+func join(t *testing.T, store map[string][]byte, addr swarm.Address) []byte {
+	t.Helper()
+
+	data, ok := store[addr.ByteString()]
+	if !assert.True(t, ok, "missing chunk") {
+		return nil
+	}
+
+	if binary.LittleEndian.Uint64(data[:swarm.SpanSize]) <= swarm.ChunkSize {
+		return data[swarm.SpanSize:]
+	}
+
+	var joined []byte
+	for refs := data[swarm.SpanSize:]; len(refs) > 0; refs = refs[swarm.HashSize:] {
+		joined = append(joined, join(t, store, swarm.NewAddress(refs[:swarm.HashSize]))...)
+	}
+
+	return joined
+}
+
+func randomBytes(t *testing.T, size int) []byte {
+	t.Helper()
+
+	buf := make([]byte, size)
+	_, err := rand.Read(buf)
+	assert.NoError(t, err)
+
+	return buf
+}
@@ -6,9 +6,12 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
+	"net/http"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethersphere/bee/pkg/bigint"
@@ -16,7 +19,51 @@ import (
 	"github.com/ethersphere/bee/pkg/swarm"
 )
 
-var ErrNotFound = fmt.Errorf("not found")
+var (
+	ErrNotFound = fmt.Errorf("not found")
+
+	// ErrStampUnusable is returned when upload fails because postage batch
+	// does not exist, is not usable yet, expired or is fully utilized.
+	ErrStampUnusable = fmt.Errorf("stamp unusable")
+
+	// ErrRejected is returned when the node rejects request with client
+	// error status, so the request was not processed.
+	ErrRejected = fmt.Errorf("request rejected")
+)
+
+// StatusIs reports whether response status code of Bee node matches error
+// target. Bee responds with Not Found status when data is missing, with
+// Payment Required status when batch is overissued and with Unprocessable
+// Entity status when batch is not usable. Any client error status means that
+// request was rejected.
+func StatusIs(code int, target error) bool {
+	switch target {
+	case ErrNotFound:
+		return code == http.StatusNotFound
+	case ErrStampUnusable:
+		return code == http.StatusPaymentRequired || code == http.StatusUnprocessableEntity
+	case ErrRejected:
+		return code >= http.StatusBadRequest && code < http.StatusInternalServerError
+	default:
+		return false
+	}
+}
+
+// StatusCode returns response status code with which Bee node reports error.
+// It is the inverse of StatusIs, and it returns false when error does not
+// match any of the errors.
+func StatusCode(err error) (int, bool) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, ErrStampUnusable):
+		return http.StatusPaymentRequired, true
+	case errors.Is(err, ErrRejected):
+		return http.StatusBadRequest, true
+	default:
+		return 0, false
+	}
+}
 
 type (
 	BatchID string // hex encoded [32]byte
@@ -50,10 +97,24 @@ type (
 		Expired       bool           `json:"expired"`
 	}
 
+	// BuyStampOptions are optional parameters of bought batch.
+	BuyStampOptions struct {
+		// Label is label of the batch. Empty label is not sent.
+		Label string
+	}
+
 	BuyStampResponse struct {
 		BatchID BatchID `json:"batchID"`
 	}
 
+	TopUpBatchResponse struct {
+		BatchID BatchID `json:"batchID"`
+	}
+
+	DiluteBatchResponse struct {
+		BatchID BatchID `json:"batchID"`
+	}
+
 	UploadSocResponse struct {
 		Reference swarm.Address `json:"reference"`
 	}
@@ -64,6 +125,49 @@ type (
 		Next      uint64        // passed via header
 	}
 
+	// TagID identifies tag, which tracks progress of uploads tagged with it.
+	TagID uint32
+
+	TagResponse struct {
+		UID       TagID     `json:"uid"`
+		Split     int64     `json:"split"`
+		Seen      int64     `json:"seen"`
+		Stored    int64     `json:"stored"`
+		Sent      int64     `json:"sent"`
+		Synced    int64     `json:"synced"`
+		StartedAt time.Time `json:"startedAt"`
+	}
+
+	PinsResponse struct {
+		References []swarm.Address `json:"references"`
+	}
+
+	// PinIntegrityResponse reports how many chunks of pinned data are stored
+	// by the node.
+	PinIntegrityResponse struct {
+		Reference swarm.Address `json:"reference"`
+		Total     int           `json:"total"`
+		Missing   int           `json:"missing"`
+		Invalid   int           `json:"invalid"`
+	}
+
+	IsRetrievableResponse struct {
+		IsRetrievable bool `json:"isRetrievable"`
+	}
+
+	// UploadProgress is called with number of bytes uploaded so far.
+	UploadProgress func(uploaded int64)
+
+	// ChunkStream uploads chunks over single connection to Bee node.
+	ChunkStream interface {
+		// UploadChunk uploads content addressed chunk and waits until node
+		// stores it. Data is span followed by payload of the chunk.
+		UploadChunk(data []byte) error
+
+		// Close closes the connection.
+		Close() error
+	}
+
 	// Client is interface for communicating with Bee node API.
 	Client interface {
 		// Stamps fetches purchased stamp batches via /stamps endpoint.
@@ -77,8 +181,38 @@ type (
 			amount *big.Int,
 			depth uint8,
 			immutable bool,
+			opts BuyStampOptions,
 		) (BuyStampResponse, error)
 
+		// TopUpBatch increases amount per chunk of the batch, which extends
+		// its time to live.
+		TopUpBatch(
+			ctx context.Context,
+			batchID BatchID,
+			amount *big.Int,
+		) (TopUpBatchResponse, error)
+
+		// DiluteBatch increases depth of the batch, which increases its
+		// capacity while amount per chunk is proportionally decreased.
+		DiluteBatch(
+			ctx context.Context,
+			batchID BatchID,
+			depth uint8,
+		) (DiluteBatchResponse, error)
+
+		// CreateTag creates new tag via /tags endpoint. Uploads are tagged
+		// with the tag when their context is made by WithTag.
+		CreateTag(
+			ctx context.Context,
+		) (TagResponse, error)
+
+		// Tag fetches progress of uploads tagged with the tag via /tags
+		// endpoint.
+		Tag(
+			ctx context.Context,
+			uid TagID,
+		) (TagResponse, error)
+
 		// UploadBytes arbitrary bytes data via /bytes endpoint.
 		UploadBytes(
 			ctx context.Context,
@@ -92,12 +226,58 @@ type (
 			addr swarm.Address,
 		) (io.ReadCloser, error)
 
+		// UploadBytesStream uploads data read from the reader via /bytes
+		// endpoint without holding it in memory. Negative size tells that
+		// size of the data is not known. Progress is called as data is sent,
+		// unless it is nil.
+		UploadBytesStream(
+			ctx context.Context,
+			reader io.Reader,
+			size int64,
+			batchID BatchID,
+			progress UploadProgress,
+		) (UploadResponse, error)
+
+		// DownloadBytesRange downloads at most length bytes of data, starting
+		// at offset, via /bytes endpoint. Negative length reads the data
+		// until its end.
+		DownloadBytesRange(
+			ctx context.Context,
+			addr swarm.Address,
+			offset, length int64,
+		) (io.ReadCloser, error)
+
 		// DownloadChunk downloads Chunk via /chunks endpoint.
 		DownloadChunk(
 			ctx context.Context,
 			addr swarm.Address,
 		) (io.ReadCloser, error)
 
+		// UploadChunk uploads content addressed chunk via /chunks endpoint.
+		// Data is span followed by payload of the chunk.
+		UploadChunk(
+			ctx context.Context,
+			data []byte,
+			batchID BatchID,
+		) (UploadResponse, error)
+
+		// UploadChunkStream opens websocket connection of /chunks/stream
+		// endpoint, which uploads chunks stamped with the batch. Context
+		// applies only to opening of the connection.
+		UploadChunkStream(
+			ctx context.Context,
+			batchID BatchID,
+		) (ChunkStream, error)
+
+		// UploadStampedChunk uploads content addressed chunk, which is
+		// stamped on the client side, via /chunks endpoint. Data is span
+		// followed by payload of the chunk.
+		UploadStampedChunk(
+			ctx context.Context,
+			data []byte,
+			stamp []byte,
+		) (UploadResponse, error)
+
 		// UploadSoc uploads Single Owner Chunk data via /soc endpoint.
 		UploadSoc(
 			ctx context.Context,
@@ -108,6 +288,47 @@ type (
 			batchID BatchID,
 		) (UploadSocResponse, error)
 
+		// Pin pins data with the root address via /pins endpoint, so that
+		// node does not garbage collect it. Node must store the data.
+		Pin(
+			ctx context.Context,
+			addr swarm.Address,
+		) error
+
+		// Unpin removes pin of data with the root address via /pins
+		// endpoint.
+		Unpin(
+			ctx context.Context,
+			addr swarm.Address,
+		) error
+
+		// ListPins lists root addresses of all pinned data via /pins
+		// endpoint.
+		ListPins(
+			ctx context.Context,
+		) (PinsResponse, error)
+
+		// CheckPin checks integrity of pinned data via /pins/check endpoint.
+		CheckPin(
+			ctx context.Context,
+			addr swarm.Address,
+		) (PinIntegrityResponse, error)
+
+		// IsRetrievable checks via /stewardship endpoint whether all chunks
+		// of data with the root address can be retrieved from the network.
+		IsRetrievable(
+			ctx context.Context,
+			addr swarm.Address,
+		) (IsRetrievableResponse, error)
+
+		// Reupload uploads data with the root address, which is stored by
+		// the node, to the network again via /stewardship endpoint.
+		Reupload(
+			ctx context.Context,
+			addr swarm.Address,
+			batchID BatchID,
+		) error
+
 		// FeedIndexLatest returns the most recent feed's index from /feeds/owner/topic.
 		FeedIndexLatest(
 			ctx context.Context,
@@ -116,3 +337,25 @@ type (
 		) (FeedIndexResponse, error)
 	}
 )
+
+// Done tells whether all chunks of tagged uploads are synced to the network.
+// Chunks which already existed in the network are not synced again. Tag
+// without split chunks is not done, because upload with the tag may not be
+// accounted yet.
+func (t TagResponse) Done() bool {
+	return t.Split > 0 && t.Synced+t.Seen >= t.Split
+}
+
+type tagKey struct{}
+
+// WithTag returns context which makes uploads tagged with the tag.
+func WithTag(ctx context.Context, uid TagID) context.Context {
+	return context.WithValue(ctx, tagKey{}, uid)
+}
+
+// TagFromContext returns tag of uploads made with the context.
+func TagFromContext(ctx context.Context) (TagID, bool) {
+	uid, ok := ctx.Value(tagKey{}).(TagID)
+
+	return uid, ok
+}
@@ -5,20 +5,25 @@
 package clienttest
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"crypto/rand"
+	"encoding/binary"
 	"io"
 	"math/big"
 	"testing"
 	"time"
 
+	"github.com/ethersphere/bee/pkg/cac"
 	"github.com/ethersphere/bee/pkg/swarm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
+	"github.com/ethersphere/eth-on-bzz/pkg/chunker"
 	"github.com/ethersphere/eth-on-bzz/pkg/client"
 	"github.com/ethersphere/eth-on-bzz/pkg/postage"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage/stamps"
 )
 
 type TestSuite struct {
@@ -35,7 +40,7 @@ func (suite *TestSuite) TestBuyStampOk() {
 	c := suite.ClientFact()
 	ctx := context.Background()
 
-	stamp, err := c.BuyStamp(ctx, big.NewInt(10000000), 17, true)
+	stamp, err := c.BuyStamp(ctx, big.NewInt(10000000), 17, true, client.BuyStampOptions{})
 	assert.NoError(t, err)
 	assert.NotEmpty(t, stamp.BatchID)
 }
@@ -48,16 +53,35 @@ func (suite *TestSuite) TestBuyStampError() {
 	ctx := context.Background()
 
 	// Assert invalid depth
-	stamp, err := c.BuyStamp(ctx, big.NewInt(10000000), 14, true)
+	stamp, err := c.BuyStamp(ctx, big.NewInt(10000000), 14, true, client.BuyStampOptions{})
 	assert.Error(t, err)
 	assert.Empty(t, stamp)
 
 	// Assert invalid amount
-	stamp, err = c.BuyStamp(ctx, big.NewInt(0), 16, true)
+	stamp, err = c.BuyStamp(ctx, big.NewInt(0), 16, true, client.BuyStampOptions{})
 	assert.Error(t, err)
 	assert.Empty(t, stamp)
 }
 
+func (suite *TestSuite) TestTopUpDiluteError() {
+	t := suite.T()
+	t.Parallel()
+
+	c := suite.ClientFact()
+	ctx := context.Background()
+
+	stamp, err := c.BuyStamp(ctx, big.NewInt(10000000), 17, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	// Assert invalid amount
+	_, err = c.TopUpBatch(ctx, stamp.BatchID, big.NewInt(0))
+	assert.Error(t, err)
+
+	// Assert depth which is not greater than batch depth
+	_, err = c.DiluteBatch(ctx, stamp.BatchID, 17)
+	assert.Error(t, err)
+}
+
 func (suite *TestSuite) TestUploadDownloadOk() {
 	t := suite.T()
 	t.Parallel()
@@ -100,6 +124,311 @@ func (suite *TestSuite) TestUploadDownloadOk() {
 	}
 }
 
+func (suite *TestSuite) TestUploadStreamOk() {
+	t := suite.T()
+	t.Parallel()
+
+	c := suite.ClientFact()
+	p := suite.PostageFact(c)
+	ctx := context.Background()
+
+	batchID, err := p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+
+	data := randomBytes(t, 3*swarm.ChunkSize+1)
+
+	for _, size := range []int64{int64(len(data)), -1} {
+		var uploaded int64
+
+		progress := func(n int64) {
+			assert.Greater(t, n, uploaded)
+			uploaded = n
+		}
+
+		resp, err := c.UploadBytesStream(ctx, bytes.NewReader(data), size, batchID, progress)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(data)), uploaded)
+
+		reader, err := c.DownloadBytes(ctx, resp.Reference)
+		assert.NoError(t, err)
+
+		downloadedData, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.NoError(t, reader.Close())
+
+		assert.Equal(t, data, downloadedData)
+	}
+}
+
+func (suite *TestSuite) TestDownloadRangeOk() {
+	t := suite.T()
+	t.Parallel()
+
+	c := suite.ClientFact()
+	p := suite.PostageFact(c)
+	ctx := context.Background()
+
+	batchID, err := p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+
+	data := randomBytes(t, 2*swarm.ChunkSize+100)
+
+	resp, err := c.UploadBytes(ctx, data, batchID)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		offset, length int64
+		want           []byte
+	}{
+		{offset: 0, length: -1, want: data},
+		{offset: 0, length: 10, want: data[:10]},
+		{offset: 100, length: -1, want: data[100:]},
+		{offset: swarm.ChunkSize - 5, length: 10, want: data[swarm.ChunkSize-5 : swarm.ChunkSize+5]},
+		{offset: int64(len(data)) - 1, length: 10, want: data[len(data)-1:]},
+		{offset: 10, length: 0, want: []byte{}},
+	}
+
+	for _, tc := range tests {
+		reader, err := c.DownloadBytesRange(ctx, resp.Reference, tc.offset, tc.length)
+		assert.NoError(t, err)
+
+		downloadedData, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.NoError(t, reader.Close())
+
+		assert.Equal(t, tc.want, downloadedData, "offset %d, length %d", tc.offset, tc.length)
+	}
+
+	// Assert range beyond end of data
+	reader, err := c.DownloadBytesRange(ctx, resp.Reference, int64(len(data))+1, 10)
+	assert.Error(t, err)
+	assert.Nil(t, reader)
+
+	// Assert empty range of missing data
+	reader, err = c.DownloadBytesRange(ctx, swarm.NewAddress(randomBytes(t, swarm.HashSize)), 0, 0)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	assert.Nil(t, reader)
+}
+
+func (suite *TestSuite) TestUploadChunksOk() {
+	t := suite.T()
+	t.Parallel()
+
+	c := suite.ClientFact()
+	p := suite.PostageFact(c)
+	ctx := context.Background()
+
+	batchID, err := p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+
+	data := randomBytes(t, 5*swarm.ChunkSize+10)
+
+	root, chunks, err := chunker.Chunks(data)
+	assert.NoError(t, err)
+
+	// Upload first chunk directly and the rest over stream
+	resp, err := c.UploadChunk(ctx, chunks[0].Data(), batchID)
+	assert.NoError(t, err)
+	assert.Equal(t, chunks[0].Address(), resp.Reference)
+
+	stream, err := c.UploadChunkStream(ctx, batchID)
+	assert.NoError(t, err)
+
+	for _, ch := range chunks[1:] {
+		assert.NoError(t, stream.UploadChunk(ch.Data()))
+	}
+
+	assert.NoError(t, stream.Close())
+
+	reader, err := c.DownloadBytes(ctx, root)
+	assert.NoError(t, err)
+
+	downloadedData, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, data, downloadedData)
+
+	reader, err = c.DownloadChunk(ctx, chunks[0].Address())
+	assert.NoError(t, err)
+
+	downloadedData, err = io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, chunks[0].Data(), downloadedData)
+}
+
+func (suite *TestSuite) TestUploadChunksError() {
+	t := suite.T()
+	t.Parallel()
+
+	c := suite.ClientFact()
+	ctx := context.Background()
+
+	ch, err := cac.New([]byte("chunk"))
+	assert.NoError(t, err)
+
+	resp, err := c.UploadChunk(ctx, ch.Data(), client.BatchID("invalid"))
+	assert.Error(t, err)
+	assert.Empty(t, resp)
+
+	stream, err := c.UploadChunkStream(ctx, client.BatchID("invalid"))
+	assert.Error(t, err)
+	assert.Nil(t, stream)
+}
+
+func (suite *TestSuite) TestUploadChunkStreamOverissued() {
+	t := suite.T()
+	t.Parallel()
+
+	c := suite.ClientFact()
+	ctx := context.Background()
+
+	// Every bucket of the batch holds 2 chunks
+	stamp, err := c.BuyStamp(ctx, big.NewInt(10000000), 17, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	waitUsable(t, c, stamp.BatchID)
+
+	stream, err := c.UploadChunkStream(ctx, stamp.BatchID)
+	assert.NoError(t, err)
+
+	chunks := chunksOfBucket(t, 3)
+	assert.NoError(t, stream.UploadChunk(chunks[0].Data()))
+	assert.NoError(t, stream.UploadChunk(chunks[1].Data()))
+
+	err = stream.UploadChunk(chunks[2].Data())
+	assert.ErrorIs(t, err, client.ErrStampUnusable)
+
+	_ = stream.Close()
+}
+
+func (suite *TestSuite) TestTagsOk() {
+	t := suite.T()
+	t.Parallel()
+
+	c := suite.ClientFact()
+	p := suite.PostageFact(c)
+	ctx := context.Background()
+
+	batchID, err := p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+
+	tag, err := c.CreateTag(ctx)
+	assert.NoError(t, err)
+	assert.NotZero(t, tag.UID)
+	assert.Zero(t, tag.Split)
+
+	data := randomBytes(t, 3*swarm.ChunkSize)
+
+	_, err = c.UploadBytes(client.WithTag(ctx, tag.UID), data, batchID)
+	assert.NoError(t, err)
+
+	// Untagged uploads are not accounted to the tag
+	_, err = c.UploadBytes(ctx, randomBytes(t, swarm.ChunkSize), batchID)
+	assert.NoError(t, err)
+
+	tag, err = c.Tag(ctx, tag.UID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(stamps.ChunkCount(len(data))), tag.Split)
+}
+
+func (suite *TestSuite) TestTagsError() {
+	t := suite.T()
+	t.Parallel()
+
+	c := suite.ClientFact()
+	p := suite.PostageFact(c)
+	ctx := context.Background()
+
+	batchID, err := p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+
+	tag, err := c.CreateTag(ctx)
+	assert.NoError(t, err)
+
+	_, err = c.Tag(ctx, tag.UID+1000)
+	assert.Error(t, err)
+
+	// Upload tagged with missing tag fails
+	_, err = c.UploadBytes(client.WithTag(ctx, tag.UID+1000), []byte{1}, batchID)
+	assert.Error(t, err)
+}
+
+func (suite *TestSuite) TestPinsOk() {
+	t := suite.T()
+	t.Parallel()
+
+	c := suite.ClientFact()
+	p := suite.PostageFact(c)
+	ctx := context.Background()
+
+	batchID, err := p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+
+	data := randomBytes(t, 3*swarm.ChunkSize)
+
+	resp, err := c.UploadBytes(ctx, data, batchID)
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Pin(ctx, resp.Reference))
+
+	pins, err := c.ListPins(ctx)
+	assert.NoError(t, err)
+	assert.Contains(t, pins.References, resp.Reference)
+
+	check, err := c.CheckPin(ctx, resp.Reference)
+	assert.NoError(t, err)
+	assert.Equal(t, resp.Reference, check.Reference)
+	assert.Equal(t, stamps.ChunkCount(len(data)), check.Total)
+	assert.Zero(t, check.Missing)
+	assert.Zero(t, check.Invalid)
+
+	assert.NoError(t, c.Unpin(ctx, resp.Reference))
+
+	pins, err = c.ListPins(ctx)
+	assert.NoError(t, err)
+	assert.NotContains(t, pins.References, resp.Reference)
+}
+
+func (suite *TestSuite) TestPinsError() {
+	t := suite.T()
+	t.Parallel()
+
+	c := suite.ClientFact()
+	ctx := context.Background()
+
+	addr := swarm.NewAddress(randomBytes(t, swarm.HashSize))
+
+	// Data which node does not store can not be pinned
+	assert.ErrorIs(t, c.Pin(ctx, addr), client.ErrNotFound)
+	assert.ErrorIs(t, c.Unpin(ctx, addr), client.ErrNotFound)
+
+	_, err := c.CheckPin(ctx, addr)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+}
+
+func (suite *TestSuite) TestStewardshipOk() {
+	t := suite.T()
+	t.Parallel()
+
+	c := suite.ClientFact()
+	p := suite.PostageFact(c)
+	ctx := context.Background()
+
+	batchID, err := p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+
+	resp, err := c.UploadBytes(ctx, randomBytes(t, 3*swarm.ChunkSize), batchID)
+	assert.NoError(t, err)
+
+	retrievable, err := c.IsRetrievable(ctx, resp.Reference)
+	assert.NoError(t, err)
+	assert.True(t, retrievable.IsRetrievable)
+
+	assert.NoError(t, c.Pin(ctx, resp.Reference))
+	assert.NoError(t, c.Reupload(ctx, resp.Reference, batchID))
+}
+
 func (suite *TestSuite) TestUploadError() {
 	t := suite.T()
 	t.Parallel()
@@ -240,6 +569,44 @@ func uploadFeedUpdate(
 	assert.Equal(t, payload, client.RawDataFromSocResp(respData))
 }
 
+// waitUsable waits until the batch is usable, as Bee node waits for a few
+// blocks after the batch is bought.
+func waitUsable(t *testing.T, c client.Client, batchID client.BatchID) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		resp, err := c.Stamps(context.Background())
+		assert.NoError(t, err)
+
+		for _, st := range resp.Stamps {
+			if st.BatchID == batchID {
+				return st.Usable
+			}
+		}
+
+		return false
+	}, 5*time.Minute, 100*time.Millisecond)
+}
+
+// chunksOfBucket returns n chunks whose addresses fall into the same bucket.
+func chunksOfBucket(t *testing.T, n int) []swarm.Chunk {
+	t.Helper()
+
+	buckets := make(map[uint32][]swarm.Chunk)
+
+	for i := uint64(0); ; i++ {
+		ch, err := cac.New(binary.BigEndian.AppendUint64(nil, i))
+		assert.NoError(t, err)
+
+		bucket := stamps.Bucket(ch.Address(), 16)
+
+		buckets[bucket] = append(buckets[bucket], ch)
+		if len(buckets[bucket]) == n {
+			return buckets[bucket]
+		}
+	}
+}
+
 func randomBytes(t *testing.T, size int) []byte {
 	t.Helper()
 
@@ -0,0 +1,131 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethersphere/bee/pkg/crypto"
+)
+
+// EpochMaxLevel is level of the root epoch, which spans 2^32 seconds.
+const EpochMaxLevel = 32
+
+// Epoch identifies time range of epoch based feed. Epoch at level L starts at
+// multiple of 2^L and spans 2^L seconds. Every epoch is split into two child
+// epochs at level L-1, so epochs form binary tree rooted at the epoch which
+// spans whole time range.
+//
+// Every update of epoch feed is stored in epoch which contains time of the
+// update, and which is not used by any previous update. Update is then found
+// by descending the tree from the root towards the time of lookup, which
+// takes at most EpochMaxLevel steps.
+type Epoch struct {
+	Start uint64
+	Level uint8
+}
+
+// RootEpoch returns epoch of the first update of every feed.
+func RootEpoch() Epoch {
+	return Epoch{Start: 0, Level: EpochMaxLevel}
+}
+
+// Length returns number of seconds in the epoch.
+func (e Epoch) Length() uint64 {
+	return 1 << e.Level
+}
+
+// Parent returns epoch which is parent of this epoch.
+func (e Epoch) Parent() Epoch {
+	length := e.Length() << 1
+
+	return Epoch{Start: (e.Start / length) * length, Level: e.Level + 1}
+}
+
+// IsLeft reports whether the epoch is left child of its parent.
+func (e Epoch) IsLeft() bool {
+	return e.Start&e.Length() == 0
+}
+
+// Left returns left sibling of the epoch, which must be right child.
+func (e Epoch) Left() Epoch {
+	return Epoch{Start: e.Start - e.Length(), Level: e.Level}
+}
+
+// ChildAt returns child epoch which contains time at.
+func (e Epoch) ChildAt(at uint64) Epoch {
+	child := Epoch{Start: e.Start, Level: e.Level - 1}
+	if at&child.Length() > 0 {
+		child.Start |= child.Length()
+	}
+
+	return child
+}
+
+// Next returns epoch of the update at time at, following the update stored
+// in this epoch at time last. Time at must be greater than time last.
+func (e Epoch) Next(last, at uint64) Epoch {
+	if e.Start+e.Length() > at {
+		return e.ChildAt(at)
+	}
+
+	return epochLCA(at, last).ChildAt(at)
+}
+
+func (e Epoch) String() string {
+	return fmt.Sprintf("%d/%d", e.Start, e.Level)
+}
+
+// epochLCA returns lowest common ancestor epoch of times at and after.
+func epochLCA(at, after uint64) Epoch {
+	if after == 0 {
+		return RootEpoch()
+	}
+
+	diff := at - after
+	length := uint64(1)
+
+	var level uint8
+	for level < EpochMaxLevel && (length < diff || at/length != after/length) {
+		length <<= 1
+		level++
+	}
+
+	return Epoch{Start: (after / length) * length, Level: level}
+}
+
+// EpochFeedID returns id of Single Owner Chunk of epoch feed update.
+//
+//nolint:wrapcheck //relax
+func EpochFeedID(topic Topic, epoch Epoch) (SocID, error) {
+	data := make([]byte, 9)
+	binary.BigEndian.PutUint64(data, epoch.Start)
+	data[8] = epoch.Level
+
+	index, err := crypto.LegacyKeccak256(data)
+	if err != nil {
+		return nil, err
+	}
+
+	fid := make([]byte, 0, len(topic)+len(index))
+	fid = append(fid, topic...)
+	fid = append(fid, index...)
+
+	return crypto.LegacyKeccak256(fid)
+}
+
+// EpochFeedUpdateReference returns address of epoch feed update.
+//
+//nolint:wrapcheck //relax
+func EpochFeedUpdateReference(owner common.Address, topic Topic, epoch Epoch) ([]byte, error) {
+	feedID, err := EpochFeedID(topic, epoch)
+	if err != nil {
+		return nil, err
+	}
+
+	return SocAddress(owner, feedID)
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+func Test_Epoch(t *testing.T) {
+	t.Parallel()
+
+	root := client.RootEpoch()
+	assert.Equal(t, uint64(1<<32), root.Length())
+
+	child := root.ChildAt(1 << 31)
+	assert.Equal(t, client.Epoch{Start: 1 << 31, Level: 31}, child)
+	assert.Equal(t, root, child.Parent())
+
+	// Every update is stored in unused epoch which contains time of update
+	times := []uint64{1000, 1001, 1002, 1500, 1501, 100000, 100001, 1 << 31}
+	used := map[client.Epoch]struct{}{root: {}}
+	epoch, last := root, times[0]
+
+	for _, at := range times[1:] {
+		epoch = epoch.Next(last, at)
+
+		assert.LessOrEqual(t, epoch.Start, at)
+		assert.Greater(t, epoch.Start+epoch.Length(), at)
+		assert.NotContains(t, used, epoch)
+
+		used[epoch] = struct{}{}
+		last = at
+	}
+}
+
+func Test_EpochFeedID(t *testing.T) {
+	t.Parallel()
+
+	topic := make(client.Topic, 32)
+
+	rootID, err := client.EpochFeedID(topic, client.RootEpoch())
+	assert.NoError(t, err)
+
+	childID, err := client.EpochFeedID(topic, client.RootEpoch().ChildAt(0))
+	assert.NoError(t, err)
+	assert.NotEqual(t, rootID, childID)
+
+	seqID, err := client.FeedID(topic, 0)
+	assert.NoError(t, err)
+	assert.NotEqual(t, rootID, seqID)
+}
@@ -8,6 +8,7 @@ import (
 	"crypto/ecdsa"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -73,10 +74,17 @@ func FeedUpdateReference(owner common.Address, topic Topic, index uint64) ([]byt
 		return nil, err
 	}
 
+	return SocAddress(owner, feedID)
+}
+
+// SocAddress returns address of Single Owner Chunk with given id and owner.
+//
+//nolint:wrapcheck //relax
+func SocAddress(owner common.Address, id SocID) ([]byte, error) {
 	ownerBytes := owner.Bytes()
 
-	ref := make([]byte, 0, len(feedID)+len(ownerBytes))
-	ref = append(ref, feedID...)
+	ref := make([]byte, 0, len(id)+len(ownerBytes))
+	ref = append(ref, id...)
 	ref = append(ref, ownerBytes...)
 
 	return crypto.LegacyKeccak256(ref)
@@ -95,9 +103,35 @@ func PayloadStripTime(payload []byte) []byte {
 	return payload[8:]
 }
 
+func PayloadTime(payload []byte) time.Time {
+	return time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+}
+
 //nolint:wrapcheck //relax
 func OwnerFromKey(key *ecdsa.PrivateKey) (common.Address, error) {
 	signer := crypto.NewDefaultSigner(key)
 
 	return signer.EthereumAddress()
 }
+
+// NewProgressReader returns reader which calls progress with number of bytes
+// read from the reader so far.
+func NewProgressReader(reader io.Reader, progress UploadProgress) io.Reader {
+	return &progressReader{reader: reader, progress: progress}
+}
+
+type progressReader struct {
+	reader   io.Reader
+	read     int64
+	progress UploadProgress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		r.progress(r.read)
+	}
+
+	return n, err //nolint:wrapcheck //relax
+}
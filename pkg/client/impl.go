@@ -10,14 +10,19 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/websocket"
 
 	"github.com/ethersphere/bee/pkg/api"
 	"github.com/ethersphere/bee/pkg/swarm"
@@ -30,13 +35,24 @@ const (
 
 	headerImmutable        = "Immutable"
 	headerBatchID          = api.SwarmPostageBatchIdHeader
+	headerPostageStamp     = "Swarm-Postage-Stamp"
+	headerRange            = "Range"
+	headerTag              = api.SwarmTagHeader
 	headerFeedCurrentIndex = api.SwarmFeedIndexHeader
 	headerFeedNextIndex    = api.SwarmFeedIndexNextHeader
 
-	portAPI  = 1633
-	portAPId = 1635
+	defaultAPIPort      = 1633
+	defaultDebugAPIPort = 1635
+
+	chunkStreamCloseTimeout = time.Second
+
+	// closeReasonOverissued is reason of close message, which Bee node sends
+	// when chunk of the stream can not be stamped because its bucket is full.
+	closeReasonOverissued = "batch is overissued"
 )
 
+var errChunkStreamAck = fmt.Errorf("unexpected chunk acknowledgment")
+
 type client struct {
 	cfg        Config
 	httpClient *http.Client
@@ -44,9 +60,22 @@ type client struct {
 
 type Config struct {
 	NodeURL string
+
+	// APIPort and DebugAPIPort are ports of Bee API and Bee debug API. Default
+	// ports of Bee node are used when they are not set.
+	APIPort      int
+	DebugAPIPort int
 }
 
 func NewClient(cfg Config) Client {
+	if cfg.APIPort == 0 {
+		cfg.APIPort = defaultAPIPort
+	}
+
+	if cfg.DebugAPIPort == 0 {
+		cfg.DebugAPIPort = defaultDebugAPIPort
+	}
+
 	return &client{
 		cfg:        cfg,
 		httpClient: http.DefaultClient,
@@ -60,7 +89,7 @@ func (c *client) Stamps(
 
 	h := http.Header{}
 
-	endpoint := c.makeEndpoint(portAPId, "stamps")
+	endpoint := c.makeEndpoint(c.cfg.DebugAPIPort, "stamps")
 
 	//nolint:bodyclose // body is closed after handling error
 	httpResp, err := c.doRequest(ctx, http.MethodGet, endpoint, h, nil)
@@ -82,13 +111,17 @@ func (c *client) BuyStamp(
 	amount *big.Int,
 	depth uint8,
 	immutable bool,
+	opts BuyStampOptions,
 ) (BuyStampResponse, error) {
 	var resp BuyStampResponse
 
 	h := http.Header{}
 	h.Add(headerImmutable, strconv.FormatBool(immutable))
 
-	endpoint := c.makeEndpoint(portAPId, "stamps", amount.Text(10), strconv.Itoa(int(depth)))
+	endpoint := c.makeEndpoint(c.cfg.DebugAPIPort, "stamps", amount.Text(10), strconv.Itoa(int(depth)))
+	if opts.Label != "" {
+		endpoint += "?" + url.Values{"label": {opts.Label}}.Encode()
+	}
 
 	//nolint:bodyclose // body is closed after handling error
 	httpResp, err := c.doRequest(ctx, http.MethodPost, endpoint, h, nil)
@@ -105,21 +138,142 @@ func (c *client) BuyStamp(
 	return resp, nil
 }
 
+func (c *client) TopUpBatch(
+	ctx context.Context,
+	batchID BatchID,
+	amount *big.Int,
+) (TopUpBatchResponse, error) {
+	var resp TopUpBatchResponse
+
+	endpoint := c.makeEndpoint(c.cfg.DebugAPIPort, "stamps", "topup", string(batchID), amount.Text(10))
+
+	//nolint:bodyclose // body is closed after handling error
+	httpResp, err := c.doRequest(ctx, http.MethodPatch, endpoint, http.Header{}, nil)
+	if err != nil {
+		return resp, fmt.Errorf("top up batch request failed: %w", err)
+	}
+
+	defer closeBody(httpResp)
+
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return resp, fmt.Errorf("failed to decode response from top up batch endpoint: %w", err)
+	}
+
+	return resp, nil
+}
+
+func (c *client) DiluteBatch(
+	ctx context.Context,
+	batchID BatchID,
+	depth uint8,
+) (DiluteBatchResponse, error) {
+	var resp DiluteBatchResponse
+
+	endpoint := c.makeEndpoint(c.cfg.DebugAPIPort, "stamps", "dilute", string(batchID), strconv.Itoa(int(depth)))
+
+	//nolint:bodyclose // body is closed after handling error
+	httpResp, err := c.doRequest(ctx, http.MethodPatch, endpoint, http.Header{}, nil)
+	if err != nil {
+		return resp, fmt.Errorf("dilute batch request failed: %w", err)
+	}
+
+	defer closeBody(httpResp)
+
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return resp, fmt.Errorf("failed to decode response from dilute batch endpoint: %w", err)
+	}
+
+	return resp, nil
+}
+
+func (c *client) CreateTag(
+	ctx context.Context,
+) (TagResponse, error) {
+	var resp TagResponse
+
+	h := http.Header{}
+
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "tags")
+
+	//nolint:bodyclose // body is closed after handling error
+	httpResp, err := c.doRequest(ctx, http.MethodPost, endpoint, h, nil)
+	if err != nil {
+		return resp, fmt.Errorf("create tag request failed: %w", err)
+	}
+
+	defer closeBody(httpResp)
+
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return resp, fmt.Errorf("failed to decode response from tags endpoint: %w", err)
+	}
+
+	return resp, nil
+}
+
+func (c *client) Tag(
+	ctx context.Context,
+	uid TagID,
+) (TagResponse, error) {
+	var resp TagResponse
+
+	h := http.Header{}
+
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "tags", strconv.FormatUint(uint64(uid), 10))
+
+	//nolint:bodyclose // body is closed after handling error
+	httpResp, err := c.doRequest(ctx, http.MethodGet, endpoint, h, nil)
+	if err != nil {
+		return resp, fmt.Errorf("tag request failed: %w", err)
+	}
+
+	defer closeBody(httpResp)
+
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return resp, fmt.Errorf("failed to decode response from tags endpoint: %w", err)
+	}
+
+	return resp, nil
+}
+
 func (c *client) UploadBytes(
 	ctx context.Context,
 	data []byte,
 	batchID BatchID,
+) (UploadResponse, error) {
+	return c.UploadBytesStream(ctx, bytes.NewReader(data), int64(len(data)), batchID, nil)
+}
+
+func (c *client) UploadBytesStream(
+	ctx context.Context,
+	reader io.Reader,
+	size int64,
+	batchID BatchID,
+	progress UploadProgress,
 ) (UploadResponse, error) {
 	var resp UploadResponse
 
 	h := http.Header{}
 	h.Add(headerBatchID, string(batchID))
+	addTagHeader(ctx, h)
 
-	dataReader := bytes.NewReader(data)
-	endpoint := c.makeEndpoint(portAPI, "bytes")
+	if progress != nil {
+		reader = NewProgressReader(reader, progress)
+	}
+
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "bytes")
+
+	req, err := c.newRequest(ctx, http.MethodPost, endpoint, h, reader)
+	if err != nil {
+		return resp, fmt.Errorf("upload request failed: %w", err)
+	}
+
+	// Data of unknown size is sent with chunked transfer encoding.
+	if size >= 0 {
+		req.ContentLength = size
+	}
 
 	//nolint:bodyclose // body is closed after handling error
-	httpResp, err := c.doRequest(ctx, http.MethodPost, endpoint, h, dataReader)
+	httpResp, err := c.do(req)
 	if err != nil {
 		return resp, fmt.Errorf("upload request failed: %w", err)
 	}
@@ -138,7 +292,7 @@ func (c *client) DownloadBytes(
 	addr swarm.Address,
 ) (io.ReadCloser, error) {
 	header := http.Header{}
-	endpoint := c.makeEndpoint(portAPI, "bytes", addr.String())
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "bytes", addr.String())
 
 	httpResp, err := c.doRequest(ctx, http.MethodGet, endpoint, header, nil)
 	if err != nil {
@@ -148,12 +302,91 @@ func (c *client) DownloadBytes(
 	return httpResp.Body, nil
 }
 
+func (c *client) DownloadBytesRange(
+	ctx context.Context,
+	addr swarm.Address,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	if length == 0 {
+		return c.emptyRange(ctx, addr, offset)
+	}
+
+	header := http.Header{}
+	if offset > 0 || length > 0 {
+		header.Set(headerRange, byteRange(offset, length))
+	}
+
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "bytes", addr.String())
+
+	httpResp, err := c.doRequest(ctx, http.MethodGet, endpoint, header, nil)
+	if err != nil {
+		return nil, fmt.Errorf("download range request failed: %w", err)
+	}
+
+	if httpResp.StatusCode == http.StatusPartialContent {
+		return httpResp.Body, nil
+	}
+
+	// Range is not applied when node responds with the whole data.
+	if _, err := io.CopyN(io.Discard, httpResp.Body, offset); err != nil {
+		closeBody(httpResp)
+
+		return nil, fmt.Errorf("download range request failed: %w", err)
+	}
+
+	if length < 0 {
+		return httpResp.Body, nil
+	}
+
+	return &limitedReadCloser{
+		Reader: io.LimitReader(httpResp.Body, length),
+		Closer: httpResp.Body,
+	}, nil
+}
+
+// emptyRange checks that data exists and that offset is within the data, since
+// Bee node can not serve range of zero length, and returns empty reader.
+func (c *client) emptyRange(ctx context.Context, addr swarm.Address, offset int64) (io.ReadCloser, error) {
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "bytes", addr.String())
+
+	httpResp, err := c.doRequest(ctx, http.MethodHead, endpoint, http.Header{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("download range request failed: %w", err)
+	}
+
+	closeBody(httpResp)
+
+	if offset < 0 || offset > httpResp.ContentLength {
+		return nil, swarmAPIError{
+			Code:    http.StatusRequestedRangeNotSatisfiable,
+			Message: http.StatusText(http.StatusRequestedRangeNotSatisfiable),
+		}
+	}
+
+	return io.NopCloser(bytes.NewReader(nil)), nil
+}
+
+// byteRange returns value of Range header, which requests length bytes
+// starting at offset.
+func byteRange(offset, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
 func (c *client) DownloadChunk(
 	ctx context.Context,
 	addr swarm.Address,
 ) (io.ReadCloser, error) {
 	header := http.Header{}
-	endpoint := c.makeEndpoint(portAPI, "chunks", addr.String())
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "chunks", addr.String())
 
 	httpResp, err := c.doRequest(ctx, http.MethodGet, endpoint, header, nil)
 	if err != nil {
@@ -163,6 +396,138 @@ func (c *client) DownloadChunk(
 	return httpResp.Body, nil
 }
 
+func (c *client) UploadChunk(
+	ctx context.Context,
+	data []byte,
+	batchID BatchID,
+) (UploadResponse, error) {
+	h := http.Header{}
+	h.Add(headerBatchID, string(batchID))
+
+	return c.uploadChunk(ctx, data, h)
+}
+
+func (c *client) UploadStampedChunk(
+	ctx context.Context,
+	data []byte,
+	stamp []byte,
+) (UploadResponse, error) {
+	h := http.Header{}
+	h.Add(headerPostageStamp, hex.EncodeToString(stamp))
+
+	return c.uploadChunk(ctx, data, h)
+}
+
+func (c *client) uploadChunk(
+	ctx context.Context,
+	data []byte,
+	h http.Header,
+) (UploadResponse, error) {
+	var resp UploadResponse
+
+	addTagHeader(ctx, h)
+
+	dataReader := bytes.NewReader(data)
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "chunks")
+
+	//nolint:bodyclose // body is closed after handling error
+	httpResp, err := c.doRequest(ctx, http.MethodPost, endpoint, h, dataReader)
+	if err != nil {
+		return resp, fmt.Errorf("upload request failed: %w", err)
+	}
+
+	defer closeBody(httpResp)
+
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return resp, fmt.Errorf("failed to decode response from upload chunk endpoint: %w", err)
+	}
+
+	return resp, nil
+}
+
+func (c *client) UploadChunkStream(
+	ctx context.Context,
+	batchID BatchID,
+) (ChunkStream, error) {
+	h := http.Header{}
+	h.Add(headerBatchID, string(batchID))
+	h.Set("User-Agent", userAgent)
+	addTagHeader(ctx, h)
+
+	// Websocket URL has ws or wss scheme instead of http or https.
+	endpoint := "ws" + strings.TrimPrefix(c.makeEndpoint(c.cfg.APIPort, "chunks", "stream"), "http")
+
+	conn, httpResp, err := websocket.DefaultDialer.DialContext(ctx, endpoint, h)
+	if err != nil {
+		if httpResp != nil {
+			defer closeBody(httpResp)
+
+			if apiErr := responseErrorHandler(httpResp); apiErr != nil {
+				err = apiErr
+			}
+		}
+
+		return nil, fmt.Errorf("chunk stream request failed: %w", err)
+	}
+
+	return &chunkStream{conn: conn}, nil
+}
+
+// chunkStream uploads chunks as binary websocket messages. Node acknowledges
+// every stored chunk with empty binary message.
+type chunkStream struct {
+	conn *websocket.Conn
+	lock sync.Mutex
+}
+
+func (s *chunkStream) UploadChunk(data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		return fmt.Errorf("failed writing chunk: %w", err)
+	}
+
+	msgType, msg, err := s.conn.ReadMessage()
+	if err != nil {
+		return fmt.Errorf("failed reading chunk acknowledgment: %w", chunkStreamError(err))
+	}
+
+	if msgType != websocket.BinaryMessage || len(msg) != 0 {
+		return fmt.Errorf("%w: %s", errChunkStreamAck, msg)
+	}
+
+	return nil
+}
+
+// chunkStreamError maps close message, which Bee node sends when chunk upload
+// fails, to API error with matching status.
+func chunkStreamError(err error) error {
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code == websocket.CloseNormalClosure {
+		return err
+	}
+
+	code := http.StatusInternalServerError
+	if closeErr.Text == closeReasonOverissued {
+		code = http.StatusPaymentRequired
+	}
+
+	return swarmAPIError{Code: code, Message: closeErr.Text}
+}
+
+func (s *chunkStream) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+
+	//nolint:errcheck // connection is closed regardless of close message
+	s.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(chunkStreamCloseTimeout))
+
+	return s.conn.Close() //nolint:wrapcheck //relax
+}
+
 func (c *client) UploadSoc(
 	ctx context.Context,
 	owner common.Address,
@@ -175,12 +540,13 @@ func (c *client) UploadSoc(
 
 	h := http.Header{}
 	h.Add(headerBatchID, string(batchID))
+	addTagHeader(ctx, h)
 
 	dataReader := bytes.NewReader(data)
 
 	ownerParam := hex.EncodeToString(owner.Bytes())
 	idParam := hex.EncodeToString(id)
-	endpoint := c.makeEndpoint(portAPI, "soc", ownerParam, idParam)
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "soc", ownerParam, idParam)
 	endpoint += "?sig=" + hex.EncodeToString(signature)
 
 	//nolint:bodyclose // body is closed after handling error
@@ -198,6 +564,141 @@ func (c *client) UploadSoc(
 	return resp, nil
 }
 
+func (c *client) Pin(
+	ctx context.Context,
+	addr swarm.Address,
+) error {
+	h := http.Header{}
+
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "pins", addr.String())
+
+	//nolint:bodyclose // body is closed after handling error
+	httpResp, err := c.doRequest(ctx, http.MethodPost, endpoint, h, nil)
+	if err != nil {
+		return fmt.Errorf("pin request failed: %w", err)
+	}
+
+	closeBody(httpResp)
+
+	return nil
+}
+
+func (c *client) Unpin(
+	ctx context.Context,
+	addr swarm.Address,
+) error {
+	h := http.Header{}
+
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "pins", addr.String())
+
+	//nolint:bodyclose // body is closed after handling error
+	httpResp, err := c.doRequest(ctx, http.MethodDelete, endpoint, h, nil)
+	if err != nil {
+		return fmt.Errorf("unpin request failed: %w", err)
+	}
+
+	closeBody(httpResp)
+
+	return nil
+}
+
+func (c *client) ListPins(
+	ctx context.Context,
+) (PinsResponse, error) {
+	var resp PinsResponse
+
+	h := http.Header{}
+
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "pins")
+
+	//nolint:bodyclose // body is closed after handling error
+	httpResp, err := c.doRequest(ctx, http.MethodGet, endpoint, h, nil)
+	if err != nil {
+		return resp, fmt.Errorf("list pins request failed: %w", err)
+	}
+
+	defer closeBody(httpResp)
+
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return resp, fmt.Errorf("failed to decode response from pins endpoint: %w", err)
+	}
+
+	return resp, nil
+}
+
+func (c *client) CheckPin(
+	ctx context.Context,
+	addr swarm.Address,
+) (PinIntegrityResponse, error) {
+	var resp PinIntegrityResponse
+
+	h := http.Header{}
+
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "pins", "check")
+	endpoint += "?" + url.Values{"ref": {addr.String()}}.Encode()
+
+	//nolint:bodyclose // body is closed after handling error
+	httpResp, err := c.doRequest(ctx, http.MethodGet, endpoint, h, nil)
+	if err != nil {
+		return resp, fmt.Errorf("check pin request failed: %w", err)
+	}
+
+	defer closeBody(httpResp)
+
+	// Node streams result of every checked pin, which is single pin here.
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return resp, fmt.Errorf("failed to decode response from check pins endpoint: %w", err)
+	}
+
+	return resp, nil
+}
+
+func (c *client) IsRetrievable(
+	ctx context.Context,
+	addr swarm.Address,
+) (IsRetrievableResponse, error) {
+	var resp IsRetrievableResponse
+
+	h := http.Header{}
+
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "stewardship", addr.String())
+
+	//nolint:bodyclose // body is closed after handling error
+	httpResp, err := c.doRequest(ctx, http.MethodGet, endpoint, h, nil)
+	if err != nil {
+		return resp, fmt.Errorf("is retrievable request failed: %w", err)
+	}
+
+	defer closeBody(httpResp)
+
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return resp, fmt.Errorf("failed to decode response from stewardship endpoint: %w", err)
+	}
+
+	return resp, nil
+}
+
+func (c *client) Reupload(
+	ctx context.Context,
+	addr swarm.Address,
+	batchID BatchID,
+) error {
+	h := http.Header{}
+	h.Add(headerBatchID, string(batchID))
+
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "stewardship", addr.String())
+
+	//nolint:bodyclose // body is closed after handling error
+	httpResp, err := c.doRequest(ctx, http.MethodPut, endpoint, h, nil)
+	if err != nil {
+		return fmt.Errorf("reupload request failed: %w", err)
+	}
+
+	closeBody(httpResp)
+
+	return nil
+}
+
 func (c *client) FeedIndexLatest(
 	ctx context.Context,
 	owner common.Address,
@@ -209,7 +710,7 @@ func (c *client) FeedIndexLatest(
 
 	ownerParam := hex.EncodeToString(owner.Bytes())
 	topicParam := hex.EncodeToString(topic)
-	endpoint := c.makeEndpoint(portAPI, "feeds", ownerParam, topicParam)
+	endpoint := c.makeEndpoint(c.cfg.APIPort, "feeds", ownerParam, topicParam)
 
 	//nolint:bodyclose // body is closed after handling error
 	httpResp, err := c.doRequest(ctx, http.MethodGet, endpoint, h, nil)
@@ -254,6 +755,13 @@ func decodeIndexFromHeader(val string) (uint64, error) {
 	return binary.BigEndian.Uint64(ds), nil
 }
 
+// addTagHeader tags upload with the tag of the context, if there is any.
+func addTagHeader(ctx context.Context, h http.Header) {
+	if uid, ok := TagFromContext(ctx); ok {
+		h.Set(headerTag, strconv.FormatUint(uint64(uid), 10))
+	}
+}
+
 func (c *client) makeEndpoint(port int, parts ...string) string {
 	resource := strings.Join(parts, "/")
 
@@ -266,6 +774,20 @@ func (c *client) doRequest(
 	header http.Header,
 	body io.Reader,
 ) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, path, header, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.do(req)
+}
+
+func (c *client) newRequest(
+	ctx context.Context,
+	method, path string,
+	header http.Header,
+	body io.Reader,
+) (*http.Request, error) {
 	req, err := http.NewRequestWithContext(ctx, method, path, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new request: %w", err)
@@ -275,6 +797,10 @@ func (c *client) doRequest(
 	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("Accept", contentType)
 
+	return req, nil
+}
+
+func (c *client) do(req *http.Request) (*http.Response, error) {
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		closeBody(resp)
@@ -300,14 +826,21 @@ func (e swarmAPIError) Error() string {
 	return fmt.Sprintf("api error: code %d, message: %v", e.Code, e.Message)
 }
 
+// Is reports whether the error matches target, see StatusIs.
+func (e swarmAPIError) Is(target error) bool {
+	return StatusIs(e.Code, target)
+}
+
 func responseErrorHandler(r *http.Response) error {
 	if r.StatusCode >= http.StatusOK && r.StatusCode < http.StatusMultipleChoices {
 		return nil
 	}
 
+	// Some errors, such as unsatisfiable range, are not responded with JSON
+	// body, so status text is used as message.
 	var eResp swarmAPIError
 	if err := json.NewDecoder(r.Body).Decode(&eResp); err != nil {
-		return fmt.Errorf("failed to decode swarm api error response: %w", err)
+		eResp.Message = http.StatusText(r.StatusCode)
 	}
 
 	eResp.Code = r.StatusCode
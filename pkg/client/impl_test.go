@@ -0,0 +1,94 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/ethersphere/bee/pkg/swarm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/clienttest"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mockserver"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage"
+)
+
+func Test_Client(t *testing.T) {
+	t.Parallel()
+
+	key, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	suite.Run(t, &clienttest.TestSuite{
+		ClientFact: func() client.Client {
+			return newTestClient(t)
+		},
+		PostageFact: postage.New,
+		PrivateKey:  key,
+	})
+}
+
+func Test_Client_Errors(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := c.DownloadBytes(ctx, swarm.NewAddress(make([]byte, swarm.HashSize)))
+	assert.ErrorIs(t, err, client.ErrNotFound)
+
+	_, err = c.UploadBytes(ctx, []byte{1}, client.BatchID("invalid"))
+	assert.ErrorIs(t, err, client.ErrStampUnusable)
+	assert.NotErrorIs(t, err, client.ErrNotFound)
+
+	// Feed without updates is not an error
+	resp, err := c.FeedIndexLatest(ctx, common.Address{}, client.Topic(make([]byte, swarm.HashSize)))
+	assert.NoError(t, err)
+	assert.Equal(t, client.FeedIndexResponse{}, resp)
+
+	stamp, err := c.BuyStamp(ctx, big.NewInt(10000000), 17, true, client.BuyStampOptions{Label: "label"})
+	assert.NoError(t, err)
+
+	stamps, err := c.Stamps(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, stamps.Stamps, 1)
+	assert.Equal(t, stamp.BatchID, stamps.Stamps[0].BatchID)
+	assert.Equal(t, "label", stamps.Stamps[0].Label)
+	assert.Equal(t, uint8(17), stamps.Stamps[0].Depth)
+	assert.Equal(t, 0, big.NewInt(10000000).Cmp(stamps.Stamps[0].Amount.Int))
+}
+
+func Test_StatusCode(t *testing.T) {
+	t.Parallel()
+
+	for _, target := range []error{client.ErrNotFound, client.ErrStampUnusable, client.ErrRejected} {
+		code, ok := client.StatusCode(fmt.Errorf("wrapped: %w", target))
+		assert.True(t, ok)
+		assert.True(t, client.StatusIs(code, target))
+		assert.True(t, client.StatusIs(code, client.ErrRejected))
+	}
+
+	_, ok := client.StatusCode(errors.New("other"))
+	assert.False(t, ok)
+	assert.False(t, client.StatusIs(http.StatusInternalServerError, client.ErrRejected))
+}
+
+func newTestClient(t *testing.T) client.Client {
+	t.Helper()
+
+	server := mockserver.New()
+	t.Cleanup(server.Close)
+
+	return client.NewClient(server.Config())
+}
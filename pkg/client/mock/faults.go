@@ -0,0 +1,265 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mock
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+// Method identifies method of mock client, whose calls are affected by
+// injected faults.
+type Method string
+
+const (
+	MethodStamps             Method = "Stamps"
+	MethodBuyStamp           Method = "BuyStamp"
+	MethodTopUpBatch         Method = "TopUpBatch"
+	MethodDiluteBatch        Method = "DiluteBatch"
+	MethodCreateTag          Method = "CreateTag"
+	MethodTag                Method = "Tag"
+	MethodUploadBytes        Method = "UploadBytes"
+	MethodUploadChunk        Method = "UploadChunk"
+	MethodUploadChunkStream  Method = "UploadChunkStream"
+	MethodUploadStampedChunk Method = "UploadStampedChunk"
+	MethodDownloadBytes      Method = "DownloadBytes"
+	MethodDownloadChunk      Method = "DownloadChunk"
+	MethodUploadSoc          Method = "UploadSoc"
+	MethodPin                Method = "Pin"
+	MethodUnpin              Method = "Unpin"
+	MethodListPins           Method = "ListPins"
+	MethodCheckPin           Method = "CheckPin"
+	MethodIsRetrievable      Method = "IsRetrievable"
+	MethodReupload           Method = "Reupload"
+	MethodFeedIndexLatest    Method = "FeedIndexLatest"
+)
+
+// APIError resembles error response of Bee API. It matches client errors the
+// same way as errors of Bee client, see client.StatusIs.
+type APIError struct {
+	Code    int
+	Message string
+}
+
+func (e APIError) Error() string {
+	return fmt.Sprintf("api error: code %d, message: %v", e.Code, e.Message)
+}
+
+func (e APIError) Is(target error) bool {
+	return client.StatusIs(e.Code, target)
+}
+
+//nolint:gochecknoglobals
+var (
+	ErrNotFound            = APIError{Code: http.StatusNotFound, Message: "Not Found"}
+	ErrPaymentRequired     = APIError{Code: http.StatusPaymentRequired, Message: "batch is overissued"}
+	ErrServiceUnavailable  = APIError{Code: http.StatusServiceUnavailable, Message: "Service Unavailable"}
+	ErrRangeNotSatisfiable = APIError{
+		Code:    http.StatusRequestedRangeNotSatisfiable,
+		Message: "Requested Range Not Satisfiable",
+	}
+)
+
+// Fault makes calls of the method fail. Call fails either with probability,
+// or on every Nth call, whichever is set.
+type Fault struct {
+	// Method is method whose calls fail. Empty method matches all methods.
+	Method Method
+	// Probability is probability that call fails.
+	Probability float64
+	// Nth makes every Nth call of the method fail.
+	Nth int
+	// Err is error returned by failed call. ErrServiceUnavailable is
+	// returned when it is not set.
+	Err error
+}
+
+// Latency returns delay of single call.
+type Latency func(rnd *rand.Rand) time.Duration
+
+// FixedLatency delays every call by d.
+func FixedLatency(d time.Duration) Latency {
+	return func(*rand.Rand) time.Duration { return d }
+}
+
+// UniformLatency delays calls by duration uniformly distributed between min
+// and max.
+func UniformLatency(min, max time.Duration) Latency {
+	return func(rnd *rand.Rand) time.Duration {
+		return min + time.Duration(rnd.Int63n(int64(max-min)+1))
+	}
+}
+
+// ExponentialLatency delays calls by exponentially distributed duration with
+// the mean, which resembles latency of network with occasional slow calls.
+func ExponentialLatency(mean time.Duration) Latency {
+	return func(rnd *rand.Rand) time.Duration {
+		return time.Duration(rnd.ExpFloat64() * float64(mean))
+	}
+}
+
+// WithFaults makes calls fail as described by faults.
+func WithFaults(faults ...Fault) Option {
+	return func(o *options) {
+		o.faults = append(o.faults, faults...)
+	}
+}
+
+// WithLatency delays every call by duration drawn from latency distribution.
+func WithLatency(latency Latency) Option {
+	return func(o *options) {
+		o.latency = latency
+	}
+}
+
+// WithPartialReads makes readers returned by downloads return data in short
+// reads of random size. With given probability, reader fails with
+// io.ErrUnexpectedEOF before all data is read.
+func WithPartialReads(probability float64) Option {
+	return func(o *options) {
+		o.partialReads = true
+		o.truncateProbability = probability
+	}
+}
+
+// WithSeed sets seed of randomness used by faults, latency and partial reads,
+// so that failures are reproducible.
+func WithSeed(seed int64) Option {
+	return func(o *options) {
+		o.seed = seed
+	}
+}
+
+// chaos injects faults and latency into calls of mock client.
+type chaos struct {
+	opts  options
+	rnd   *rand.Rand
+	calls map[Method]int
+	lock  sync.Mutex
+}
+
+func newChaos(opts options) *chaos {
+	return &chaos{
+		opts:  opts,
+		rnd:   rand.New(rand.NewSource(opts.seed)), //nolint:gosec // no need for secure randomness
+		calls: make(map[Method]int),
+	}
+}
+
+// inject delays the call of the method and returns error, when the call
+// should fail.
+func (c *chaos) inject(ctx context.Context, method Method) error {
+	delay, err := c.next(method)
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck //relax
+		}
+	}
+
+	return err
+}
+
+func (c *chaos) next(method Method) (time.Duration, error) {
+	if len(c.opts.faults) == 0 && c.opts.latency == nil {
+		return 0, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.calls[method]++
+	n := c.calls[method]
+
+	var delay time.Duration
+	if c.opts.latency != nil {
+		delay = c.opts.latency(c.rnd)
+	}
+
+	for _, f := range c.opts.faults {
+		if f.Method != "" && f.Method != method {
+			continue
+		}
+
+		if (f.Nth > 0 && n%f.Nth == 0) || (f.Probability > 0 && c.rnd.Float64() < f.Probability) {
+			if f.Err != nil {
+				return delay, f.Err
+			}
+
+			return delay, ErrServiceUnavailable
+		}
+	}
+
+	return delay, nil
+}
+
+// reader returns reader of downloaded data.
+func (c *chaos) reader(data []byte) io.Reader {
+	if !c.opts.partialReads {
+		return bytes.NewReader(data)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	r := &partialReader{
+		data: data,
+		size: len(data),
+		rnd:  rand.New(rand.NewSource(c.rnd.Int63())), //nolint:gosec // no need for secure randomness
+	}
+
+	if len(data) > 0 && c.rnd.Float64() < c.opts.truncateProbability {
+		r.size = c.rnd.Intn(len(data))
+		r.truncated = true
+	}
+
+	return r
+}
+
+// partialReader returns at most size bytes of data in reads of random size.
+type partialReader struct {
+	data      []byte
+	size      int
+	offset    int
+	truncated bool
+	rnd       *rand.Rand
+}
+
+func (r *partialReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		if r.truncated {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		return 0, io.EOF
+	}
+
+	n := r.size - r.offset
+	if n > len(p) {
+		n = len(p)
+	}
+
+	if n > 1 {
+		n = 1 + r.rnd.Intn(n)
+	}
+
+	copy(p, r.data[r.offset:r.offset+n])
+	r.offset += n
+
+	return n, nil
+}
@@ -5,51 +5,135 @@
 package mock
 
 import (
-	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"math"
 	"math/big"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethersphere/bee/pkg/bigint"
+	"github.com/ethersphere/bee/pkg/cac"
 	"github.com/ethersphere/bee/pkg/crypto"
 	"github.com/ethersphere/bee/pkg/postage/testing"
 	"github.com/ethersphere/bee/pkg/swarm"
 
 	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage/stamps"
 )
 
 var (
-	errInvalidStamp          = fmt.Errorf("invalid stamp")
-	errStampUsageExceeded    = fmt.Errorf("stamp usage exceeded")
+	errInvalidStamp          = fmt.Errorf("invalid stamp: %w", client.ErrStampUnusable)
+	errStampOwnerMismatch    = fmt.Errorf("stamp is not signed by batch owner: %w", client.ErrStampUnusable)
+	errStampNotUsable        = fmt.Errorf("stamp is not usable yet: %w", client.ErrStampUnusable)
+	errStampUsageExceeded    = fmt.Errorf("stamp usage exceeded: %w", client.ErrStampUnusable)
+	errStampExpired          = fmt.Errorf("stamp expired: %w", client.ErrStampUnusable)
 	errBuyStampInvalidAmount = fmt.Errorf("amount must be positive non zero value")
 	errBuyStampInvalidDepth  = fmt.Errorf("depth is not in acceptable range")
+	errTopUpInvalidAmount    = fmt.Errorf("top up amount must be positive non zero value")
+	errDiluteInvalidDepth    = fmt.Errorf("dilute depth must be greater than batch depth")
+	errUploadSizeMismatch    = fmt.Errorf("uploaded data does not match its size")
+	errChunkStreamClosed     = fmt.Errorf("chunk stream is closed")
 )
 
 func NewClient() client.Client {
+	return NewClientWithOptions()
+}
+
+// NewClientWithOptions creates mock client which simulates behavior of Bee
+// node configured by the options.
+func NewClientWithOptions(opts ...Option) client.Client {
+	o := makeOptions(opts)
+
 	return &mockClient{
+		opts:   o,
+		chaos:  newChaos(o),
 		stamps: make(map[client.BatchID]*stampData),
 		data:   make(map[string][]byte),
+		chunks: make(map[string][]byte),
 		feeds:  make(map[string]swarm.Address),
+		tags:   make(map[client.TagID]*tagData),
+		pins:   make(map[string]struct{}),
+		lost:   make(map[string]struct{}),
 	}
 }
 
 type mockClient struct {
+	opts   options
+	chaos  *chaos
 	stamps map[client.BatchID]*stampData
 	data   map[string][]byte
+	chunks map[string][]byte // content addressed chunks, span and payload
 	feeds  map[string]swarm.Address
+	tags   map[client.TagID]*tagData
+	pins   map[string]struct{}
+	lost   map[string]struct{} // addresses of chunks lost by the network
 	lock   sync.Mutex
+
+	lastTag client.TagID
 }
 
 type stampData struct {
 	amount    *big.Int
 	depth     uint8
 	immutable bool
-	usage     int
+	label     string
+	owner     common.Address
+	usableAt  time.Time
+
+	// buckets counts chunks stamped in every bucket, and usage is count of
+	// the fullest bucket.
+	buckets map[uint32]int
+	usage   int
+
+	// balance is remaining amount per chunk, which was last charged at time
+	// settled.
+	balance *big.Int
+	settled time.Time
+}
+
+func (s *stampData) expired() bool {
+	return s.balance.Sign() <= 0
+}
+
+// settle charges the batch for storage since it was charged last time, and
+// returns the stamp.
+func (c *mockClient) settle(s *stampData) *stampData {
+	if c.opts.price == nil || c.opts.price.Sign() == 0 {
+		return s
+	}
+
+	seconds := int64(c.opts.clock.Now().Sub(s.settled) / time.Second)
+	if seconds <= 0 {
+		return s
+	}
+
+	s.balance.Sub(s.balance, new(big.Int).Mul(c.opts.price, big.NewInt(seconds)))
+	if s.balance.Sign() < 0 {
+		s.balance.SetInt64(0)
+	}
+
+	s.settled = s.settled.Add(time.Duration(seconds) * time.Second)
+
+	return s
+}
+
+func (c *mockClient) usable(s *stampData) bool {
+	return !s.expired() && !c.opts.clock.Now().Before(s.usableAt)
+}
+
+// ttl returns number of seconds until the batch expires. Negative value tells
+// that batch never expires, because storage is free.
+func (c *mockClient) ttl(s *stampData) int64 {
+	if c.opts.price == nil || c.opts.price.Sign() == 0 {
+		return -1
+	}
+
+	return new(big.Int).Div(s.balance, c.opts.price).Int64()
 }
 
 const (
@@ -57,17 +141,26 @@ const (
 	bucketDepth = 16
 	minDepth    = bucketDepth + 1
 	maxDepth    = 255
+
+	// dataBucket is bucket which uploaded data and single owner chunks are
+	// accounted to. Mock stores them whole instead of splitting them into
+	// chunks, so all their chunks are accounted as if they fell into the
+	// same bucket.
+	dataBucket = 0
 )
 
-func (s *stampData) incUsage(size int) error {
+func (s *stampData) incUsage(bucket uint32, size int) error {
 	requiredChunks := int(math.Ceil(float64(size) / chunkSize))
 	maxChunks := 1 << (s.depth - bucketDepth)
 
-	if s.usage+requiredChunks > maxChunks {
+	if s.buckets[bucket]+requiredChunks > maxChunks {
 		return errStampUsageExceeded
 	}
 
-	s.usage += requiredChunks
+	s.buckets[bucket] += requiredChunks
+	if s.buckets[bucket] > s.usage {
+		s.usage = s.buckets[bucket]
+	}
 
 	return nil
 }
@@ -75,17 +168,29 @@ func (s *stampData) incUsage(size int) error {
 func (c *mockClient) Stamps(
 	ctx context.Context,
 ) (client.StampsResponse, error) {
+	if err := c.chaos.inject(ctx, MethodStamps); err != nil {
+		return client.StampsResponse{}, err
+	}
+
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
 	stamps := make([]client.Stamp, 0, len(c.stamps))
 	for batchID, st := range c.stamps {
+		c.settle(st)
+
 		s := client.Stamp{
 			Amount:        bigint.Wrap(big.NewInt(0).Set(st.amount)),
 			Depth:         st.depth,
 			ImmutableFlag: st.immutable,
+			Label:         st.label,
 			BatchID:       batchID,
-			Usable:        true,
+			Usable:        c.usable(st),
+			Exists:        !st.expired(),
+			Expired:       st.expired(),
+			BucketDepth:   bucketDepth,
+			Utilization:   uint32(st.usage),
+			BatchTTL:      c.ttl(st),
 		}
 
 		stamps = append(stamps, s)
@@ -99,7 +204,12 @@ func (c *mockClient) BuyStamp(
 	amount *big.Int,
 	depth uint8,
 	immutable bool,
+	opts client.BuyStampOptions,
 ) (client.BuyStampResponse, error) {
+	if err := c.chaos.inject(ctx, MethodBuyStamp); err != nil {
+		return client.BuyStampResponse{}, err
+	}
+
 	if amount.Cmp(big.NewInt(0)) <= 0 {
 		return client.BuyStampResponse{}, errBuyStampInvalidAmount
 	}
@@ -111,40 +221,148 @@ func (c *mockClient) BuyStamp(
 	batchID := client.BatchID(hex.EncodeToString(testing.MustNewID()))
 
 	c.lock.Lock()
+	now := c.opts.clock.Now()
 	c.stamps[batchID] = &stampData{
 		amount:    big.NewInt(0).Set(amount),
 		depth:     depth,
 		immutable: immutable,
+		label:     opts.Label,
+		owner:     c.opts.batchOwner,
+		usableAt:  now.Add(c.opts.usableDelay),
+		buckets:   make(map[uint32]int),
+		balance:   big.NewInt(0).Set(amount),
+		settled:   now,
 	}
 	c.lock.Unlock()
 
 	return client.BuyStampResponse{BatchID: batchID}, nil
 }
 
+func (c *mockClient) TopUpBatch(
+	ctx context.Context,
+	batchID client.BatchID,
+	amount *big.Int,
+) (client.TopUpBatchResponse, error) {
+	if err := c.chaos.inject(ctx, MethodTopUpBatch); err != nil {
+		return client.TopUpBatchResponse{}, err
+	}
+
+	if amount.Cmp(big.NewInt(0)) <= 0 {
+		return client.TopUpBatchResponse{}, errTopUpInvalidAmount
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stamp, exists := c.stamps[batchID]
+	if !exists {
+		return client.TopUpBatchResponse{}, errInvalidStamp
+	}
+
+	if c.settle(stamp).expired() {
+		return client.TopUpBatchResponse{}, errStampExpired
+	}
+
+	stamp.amount.Add(stamp.amount, amount)
+	stamp.balance.Add(stamp.balance, amount)
+
+	return client.TopUpBatchResponse{BatchID: batchID}, nil
+}
+
+func (c *mockClient) DiluteBatch(
+	ctx context.Context,
+	batchID client.BatchID,
+	depth uint8,
+) (client.DiluteBatchResponse, error) {
+	if err := c.chaos.inject(ctx, MethodDiluteBatch); err != nil {
+		return client.DiluteBatchResponse{}, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stamp, exists := c.stamps[batchID]
+	if !exists {
+		return client.DiluteBatchResponse{}, errInvalidStamp
+	}
+
+	if depth <= stamp.depth {
+		return client.DiluteBatchResponse{}, errDiluteInvalidDepth
+	}
+
+	if c.settle(stamp).expired() {
+		return client.DiluteBatchResponse{}, errStampExpired
+	}
+
+	// Every additional level of depth halves amount per chunk, so that value
+	// of the batch stays the same.
+	stamp.amount.Rsh(stamp.amount, uint(depth-stamp.depth))
+	stamp.balance.Rsh(stamp.balance, uint(depth-stamp.depth))
+	stamp.depth = depth
+
+	return client.DiluteBatchResponse{BatchID: batchID}, nil
+}
+
 func (c *mockClient) UploadBytes(
 	ctx context.Context,
 	data []byte,
 	batchID client.BatchID,
 ) (client.UploadResponse, error) {
+	if err := c.chaos.inject(ctx, MethodUploadBytes); err != nil {
+		return client.UploadResponse{}, err
+	}
+
+	c.lock.Lock()
+	addr, err := c.upload(ctx, newCacAddress(data), data, batchID, stamps.ChunkCount(len(data)))
+	c.lock.Unlock()
+
+	return client.UploadResponse{Reference: addr}, err
+}
+
+func (c *mockClient) UploadBytesStream(
+	ctx context.Context,
+	reader io.Reader,
+	size int64,
+	batchID client.BatchID,
+	progress client.UploadProgress,
+) (client.UploadResponse, error) {
+	if err := c.chaos.inject(ctx, MethodUploadBytes); err != nil {
+		return client.UploadResponse{}, err
+	}
+
+	if progress != nil {
+		reader = client.NewProgressReader(reader, progress)
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return client.UploadResponse{}, fmt.Errorf("failed reading data: %w", err)
+	}
+
+	if size >= 0 && int64(len(data)) != size {
+		return client.UploadResponse{}, errUploadSizeMismatch
+	}
+
 	c.lock.Lock()
-	addr, err := c.upload(newCacAddress(data), data, batchID)
+	addr, err := c.upload(ctx, newCacAddress(data), data, batchID, stamps.ChunkCount(len(data)))
 	c.lock.Unlock()
 
 	return client.UploadResponse{Reference: addr}, err
 }
 
 func (c *mockClient) upload(
+	ctx context.Context,
 	addresser addresser,
 	data []byte,
 	batchID client.BatchID,
+	chunks int,
 ) (swarm.Address, error) {
-	stamp, exists := c.stamps[batchID]
-	if !exists {
-		return swarm.ZeroAddress, errInvalidStamp
+	if err := c.checkTag(ctx); err != nil {
+		return swarm.ZeroAddress, err
 	}
 
-	if err := stamp.incUsage(len(data)); err != nil {
-		return swarm.ZeroAddress, fmt.Errorf("stamp usage exceeded: %w", err)
+	if err := c.useStamp(batchID, dataBucket, len(data)); err != nil {
+		return swarm.ZeroAddress, err
 	}
 
 	addr, err := addresser()
@@ -153,35 +371,307 @@ func (c *mockClient) upload(
 	}
 
 	c.data[addr.ByteString()] = data
-	c.stamps[batchID] = stamp
+	delete(c.lost, addr.ByteString())
+	c.tagUpload(ctx, chunks)
 
 	return addr, nil
 }
 
+// useStamp accounts data of the size to the bucket of the batch. It must be
+// called with lock held.
+func (c *mockClient) useStamp(batchID client.BatchID, bucket uint32, size int) error {
+	stamp, exists := c.stamps[batchID]
+	if !exists {
+		return errInvalidStamp
+	}
+
+	if c.settle(stamp).expired() {
+		return errStampExpired
+	}
+
+	if !c.usable(stamp) {
+		return errStampNotUsable
+	}
+
+	return stamp.incUsage(bucket, size)
+}
+
+func (c *mockClient) UploadChunk(
+	ctx context.Context,
+	data []byte,
+	batchID client.BatchID,
+) (client.UploadResponse, error) {
+	if err := c.chaos.inject(ctx, MethodUploadChunk); err != nil {
+		return client.UploadResponse{}, err
+	}
+
+	ch, err := cac.NewWithDataSpan(data)
+	if err != nil {
+		return client.UploadResponse{}, fmt.Errorf("invalid chunk: %w", err)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.checkTag(ctx); err != nil {
+		return client.UploadResponse{}, err
+	}
+
+	bucket := stamps.Bucket(ch.Address(), bucketDepth)
+	if err := c.useStamp(batchID, bucket, len(data)-swarm.SpanSize); err != nil {
+		return client.UploadResponse{}, err
+	}
+
+	c.chunks[ch.Address().ByteString()] = ch.Data()
+	delete(c.lost, ch.Address().ByteString())
+	c.tagUpload(ctx, 1)
+
+	return client.UploadResponse{Reference: ch.Address()}, nil
+}
+
+func (c *mockClient) UploadChunkStream(
+	ctx context.Context,
+	batchID client.BatchID,
+) (client.ChunkStream, error) {
+	if err := c.chaos.inject(ctx, MethodUploadChunkStream); err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Bee node checks the batch and the tag before connection is opened.
+	if _, exists := c.stamps[batchID]; !exists {
+		return nil, errInvalidStamp
+	}
+
+	if err := c.checkTag(ctx); err != nil {
+		return nil, err
+	}
+
+	uid, tagged := client.TagFromContext(ctx)
+
+	return &chunkStream{client: c, batchID: batchID, tag: uid, tagged: tagged}, nil
+}
+
+// chunkStream uploads chunks one by one, as if they were sent over single
+// connection.
+type chunkStream struct {
+	client  *mockClient
+	batchID client.BatchID
+	tag     client.TagID
+	tagged  bool
+	closed  bool
+	lock    sync.Mutex
+}
+
+func (s *chunkStream) UploadChunk(data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return errChunkStreamClosed
+	}
+
+	// Context applies only to opening of the stream, except for its tag.
+	ctx := context.Background()
+	if s.tagged {
+		ctx = client.WithTag(ctx, s.tag)
+	}
+
+	_, err := s.client.UploadChunk(ctx, data, s.batchID)
+
+	return err
+}
+
+func (s *chunkStream) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+
+	return nil
+}
+
+func (c *mockClient) UploadStampedChunk(
+	ctx context.Context,
+	data []byte,
+	stamp []byte,
+) (client.UploadResponse, error) {
+	if err := c.chaos.inject(ctx, MethodUploadStampedChunk); err != nil {
+		return client.UploadResponse{}, err
+	}
+
+	ch, err := cac.NewWithDataSpan(data)
+	if err != nil {
+		return client.UploadResponse{}, fmt.Errorf("invalid chunk: %w", err)
+	}
+
+	// Stamp must be issued for bucket which chunk address falls into.
+	bucket, _ := stamps.Index(stamp)
+	if len(stamp) != stamps.Size || bucket != stamps.Bucket(ch.Address(), bucketDepth) {
+		return client.UploadResponse{}, errInvalidStamp
+	}
+
+	signer, err := stampSigner(ch.Address(), stamp)
+	if err != nil {
+		return client.UploadResponse{}, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.checkTag(ctx); err != nil {
+		return client.UploadResponse{}, err
+	}
+
+	batchID := stamps.BatchID(stamp)
+	if st, exists := c.stamps[batchID]; exists && st.owner != signer {
+		return client.UploadResponse{}, errStampOwnerMismatch
+	}
+
+	if err := c.useStamp(batchID, bucket, len(data)-swarm.SpanSize); err != nil {
+		return client.UploadResponse{}, err
+	}
+
+	c.chunks[ch.Address().ByteString()] = ch.Data()
+	delete(c.lost, ch.Address().ByteString())
+	c.tagUpload(ctx, 1)
+
+	return client.UploadResponse{Reference: ch.Address()}, nil
+}
+
+// stampSigner recovers address which signed the stamp of the chunk address.
+func stampSigner(addr swarm.Address, stamp []byte) (common.Address, error) {
+	sigOffset := len(stamp) - swarm.SocSignatureSize
+
+	digest, err := crypto.LegacyKeccak256(append(append([]byte{}, addr.Bytes()...), stamp[:sigOffset]...))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed hashing stamp: %w", err)
+	}
+
+	publicKey, err := crypto.Recover(stamp[sigOffset:], digest)
+	if err != nil {
+		return common.Address{}, errInvalidStamp
+	}
+
+	signer, err := crypto.NewEthereumAddress(*publicKey)
+	if err != nil {
+		return common.Address{}, errInvalidStamp
+	}
+
+	return common.BytesToAddress(signer), nil
+}
+
 func (c *mockClient) DownloadBytes(
 	ctx context.Context,
 	addr swarm.Address,
 ) (io.ReadCloser, error) {
+	if err := c.chaos.inject(ctx, MethodDownloadBytes); err != nil {
+		return nil, err
+	}
+
+	return c.download(addr)
+}
+
+func (c *mockClient) DownloadBytesRange(
+	ctx context.Context,
+	addr swarm.Address,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	if err := c.chaos.inject(ctx, MethodDownloadBytes); err != nil {
+		return nil, err
+	}
+
+	return c.downloadRange(addr, offset, length)
+}
+
+func (c *mockClient) download(addr swarm.Address) (io.ReadCloser, error) {
+	return c.downloadRange(addr, 0, -1)
+}
+
+func (c *mockClient) downloadRange(addr swarm.Address, offset, length int64) (io.ReadCloser, error) {
 	c.lock.Lock()
-	data, exists := c.data[addr.ByteString()]
+	data, err := c.bytes(addr)
+	available := c.available(addr)
 	c.lock.Unlock()
 
-	if !exists {
+	if err != nil {
+		return nil, err
+	}
+
+	if !available {
 		return nil, client.ErrNotFound
 	}
 
+	if offset < 0 || offset > int64(len(data)) {
+		return nil, ErrRangeNotSatisfiable
+	}
+
+	data = data[offset:]
+	if length >= 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+
 	rc := &dataReadCloser{
-		Reader: bytes.NewReader(data),
+		Reader: c.chaos.reader(data),
 	}
 
 	return rc, nil
 }
 
+// bytes returns data uploaded via /bytes endpoint, or data of tree of
+// chunks uploaded one by one. It must be called with lock held.
+func (c *mockClient) bytes(addr swarm.Address) ([]byte, error) {
+	if data, exists := c.data[addr.ByteString()]; exists {
+		return data, nil
+	}
+
+	chunk, exists := c.chunks[addr.ByteString()]
+	if !exists {
+		return nil, client.ErrNotFound
+	}
+
+	if binary.LittleEndian.Uint64(chunk[:swarm.SpanSize]) <= swarm.ChunkSize {
+		return chunk[swarm.SpanSize:], nil
+	}
+
+	var data []byte
+
+	for refs := chunk[swarm.SpanSize:]; len(refs) >= swarm.HashSize; refs = refs[swarm.HashSize:] {
+		child, err := c.bytes(swarm.NewAddress(refs[:swarm.HashSize]))
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(data, child...)
+	}
+
+	return data, nil
+}
+
 func (c *mockClient) DownloadChunk(
 	ctx context.Context,
 	addr swarm.Address,
 ) (io.ReadCloser, error) {
-	return c.DownloadBytes(ctx, addr)
+	if err := c.chaos.inject(ctx, MethodDownloadChunk); err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	chunk, exists := c.chunks[addr.ByteString()]
+	available := c.available(addr)
+	c.lock.Unlock()
+
+	if exists && !available {
+		return nil, client.ErrNotFound
+	}
+
+	if exists {
+		return &dataReadCloser{Reader: c.chaos.reader(chunk)}, nil
+	}
+
+	return c.download(addr)
 }
 
 func (c *mockClient) UploadSoc(
@@ -192,10 +682,14 @@ func (c *mockClient) UploadSoc(
 	signature client.SocSignature,
 	batchID client.BatchID,
 ) (client.UploadSocResponse, error) {
+	if err := c.chaos.inject(ctx, MethodUploadSoc); err != nil {
+		return client.UploadSocResponse{}, err
+	}
+
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	addr, err := c.upload(newSocAddresser(owner, socID), makeSOCData(data), batchID)
+	addr, err := c.upload(ctx, newSocAddresser(owner, socID), makeSOCData(data), batchID, 1)
 	if err != nil {
 		return client.UploadSocResponse{}, err
 	}
@@ -210,13 +704,16 @@ func (c *mockClient) FeedIndexLatest(
 	owner common.Address,
 	topic client.Topic,
 ) (client.FeedIndexResponse, error) {
+	if err := c.chaos.inject(ctx, MethodFeedIndexLatest); err != nil {
+		return client.FeedIndexResponse{}, err
+	}
+
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
 	var (
-		addr   swarm.Address
-		exists bool
-		count  int
+		addr  swarm.Address
+		count int
 	)
 
 	for i := uint64(0); ; i++ {
@@ -225,11 +722,12 @@ func (c *mockClient) FeedIndexLatest(
 			return client.FeedIndexResponse{}, fmt.Errorf("failed to generate feed id: %w", err)
 		}
 
-		addr, exists = c.feeds[feedID(owner, hex.EncodeToString(socID))]
+		next, exists := c.feeds[feedID(owner, hex.EncodeToString(socID))]
 		if !exists {
 			break
 		}
 
+		addr = next
 		count++
 	}
 
@@ -5,15 +5,23 @@
 package mock_test
 
 import (
+	"context"
+	"errors"
+	"io"
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/ethersphere/bee/pkg/swarm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
 	"github.com/ethersphere/eth-on-bzz/pkg/client/clienttest"
 	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
 	"github.com/ethersphere/eth-on-bzz/pkg/postage"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage/stamps"
 )
 
 func Test_Mock_Client(t *testing.T) {
@@ -28,3 +36,316 @@ func Test_Mock_Client(t *testing.T) {
 		PrivateKey:  key,
 	})
 }
+
+func Test_Mock_Client_Chaos(t *testing.T) {
+	t.Parallel()
+
+	key, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	suite.Run(t, &clienttest.TestSuite{
+		ClientFact: func() client.Client {
+			return mock.NewClientWithOptions(
+				mock.WithLatency(mock.ExponentialLatency(100*time.Microsecond)),
+				mock.WithPartialReads(0),
+			)
+		},
+		PostageFact: postage.New,
+		PrivateKey:  key,
+	})
+}
+
+func Test_Mock_Faults(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	errCustom := errors.New("custom")
+
+	c := mock.NewClientWithOptions(
+		mock.WithFaults(
+			mock.Fault{Method: mock.MethodStamps, Nth: 3},
+			mock.Fault{Method: mock.MethodDownloadBytes, Probability: 1, Err: mock.ErrNotFound},
+			mock.Fault{Method: mock.MethodBuyStamp, Probability: 1, Err: errCustom},
+		),
+	)
+
+	for i := 1; i <= 6; i++ {
+		_, err := c.Stamps(ctx)
+		if i%3 == 0 {
+			assert.ErrorIs(t, err, mock.ErrServiceUnavailable)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+
+	_, err := c.DownloadBytes(ctx, swarm.NewAddress(make([]byte, swarm.HashSize)))
+	assert.ErrorIs(t, err, client.ErrNotFound)
+
+	_, err = c.BuyStamp(ctx, big.NewInt(1), 20, true, client.BuyStampOptions{})
+	assert.ErrorIs(t, err, errCustom)
+
+	assert.ErrorIs(t, mock.ErrPaymentRequired, client.ErrStampUnusable)
+	assert.NotErrorIs(t, mock.ErrServiceUnavailable, client.ErrNotFound)
+}
+
+func Test_Mock_Latency(t *testing.T) {
+	t.Parallel()
+
+	c := mock.NewClientWithOptions(mock.WithLatency(mock.FixedLatency(time.Hour)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := c.Stamps(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func Test_Mock_PartialReads(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	data := make([]byte, 3*swarm.ChunkSize)
+
+	for _, tc := range []struct {
+		probability float64
+		err         error
+	}{
+		{probability: 0},
+		{probability: 1, err: io.ErrUnexpectedEOF},
+	} {
+		c := mock.NewClientWithOptions(mock.WithPartialReads(tc.probability))
+
+		resp, err := c.BuyStamp(ctx, big.NewInt(1), 20, true, client.BuyStampOptions{})
+		assert.NoError(t, err)
+
+		uploadResp, err := c.UploadBytes(ctx, data, resp.BatchID)
+		assert.NoError(t, err)
+
+		reader, err := c.DownloadBytes(ctx, uploadResp.Reference)
+		assert.NoError(t, err)
+
+		// Every read returns less than requested
+		buf := make([]byte, len(data))
+		n, err := reader.Read(buf)
+		assert.NoError(t, err)
+		assert.Less(t, n, len(data))
+
+		rest, err := io.ReadAll(reader)
+		if tc.err != nil {
+			assert.ErrorIs(t, err, tc.err)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, len(data), n+len(rest))
+		}
+	}
+}
+
+func Test_Mock_TopUpDilute(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := mock.NewClient()
+
+	resp, err := c.BuyStamp(ctx, big.NewInt(1000), 17, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	_, err = c.TopUpBatch(ctx, resp.BatchID, big.NewInt(24))
+	assert.NoError(t, err)
+
+	st := findStamp(t, c, resp.BatchID)
+	assert.Equal(t, int64(1024), st.Amount.Int64())
+
+	_, err = c.DiluteBatch(ctx, resp.BatchID, 19)
+	assert.NoError(t, err)
+
+	st = findStamp(t, c, resp.BatchID)
+	assert.Equal(t, int64(256), st.Amount.Int64())
+	assert.Equal(t, uint8(19), st.Depth)
+
+	_, err = c.TopUpBatch(ctx, client.BatchID("unknown"), big.NewInt(1))
+	assert.ErrorIs(t, err, client.ErrStampUnusable)
+}
+
+func Test_Mock_Expiry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := mock.NewManualClock(time.Unix(1_000_000, 0))
+	c := mock.NewClientWithOptions(mock.WithClock(clock), mock.WithPrice(big.NewInt(2)))
+
+	resp, err := c.BuyStamp(ctx, big.NewInt(2*3600), 20, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	st := findStamp(t, c, resp.BatchID)
+	assert.Equal(t, int64(3600), st.BatchTTL)
+	assert.True(t, st.Usable)
+	assert.True(t, st.Exists)
+	assert.False(t, st.Expired)
+
+	_, err = c.UploadBytes(ctx, []byte("data"), resp.BatchID)
+	assert.NoError(t, err)
+
+	clock.Advance(30 * time.Minute)
+	assert.Equal(t, int64(1800), findStamp(t, c, resp.BatchID).BatchTTL)
+
+	_, err = c.TopUpBatch(ctx, resp.BatchID, big.NewInt(2*3600))
+	assert.NoError(t, err)
+
+	st = findStamp(t, c, resp.BatchID)
+	assert.Equal(t, int64(5400), st.BatchTTL)
+	assert.Equal(t, uint32(1), st.Utilization)
+
+	clock.Advance(90 * time.Minute)
+
+	st = findStamp(t, c, resp.BatchID)
+	assert.Equal(t, int64(0), st.BatchTTL)
+	assert.False(t, st.Usable)
+	assert.False(t, st.Exists)
+	assert.True(t, st.Expired)
+
+	_, err = c.UploadBytes(ctx, []byte("data"), resp.BatchID)
+	assert.ErrorIs(t, err, client.ErrStampUnusable)
+
+	_, err = c.TopUpBatch(ctx, resp.BatchID, big.NewInt(1))
+	assert.ErrorIs(t, err, client.ErrStampUnusable)
+}
+
+func Test_Mock_UsableDelay(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := mock.NewManualClock(time.Unix(1_000_000, 0))
+	c := mock.NewClientWithOptions(mock.WithClock(clock), mock.WithUsableDelay(time.Minute))
+
+	resp, err := c.BuyStamp(ctx, big.NewInt(1), 20, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	assert.False(t, findStamp(t, c, resp.BatchID).Usable)
+
+	_, err = c.UploadBytes(ctx, []byte("data"), resp.BatchID)
+	assert.ErrorIs(t, err, client.ErrStampUnusable)
+
+	clock.Advance(time.Minute)
+	assert.True(t, findStamp(t, c, resp.BatchID).Usable)
+
+	_, err = c.UploadBytes(ctx, []byte("data"), resp.BatchID)
+	assert.NoError(t, err)
+}
+
+func Test_Mock_SyncDelay(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := mock.NewManualClock(time.Unix(1_000_000, 0))
+	c := mock.NewClientWithOptions(mock.WithClock(clock), mock.WithSyncDelay(time.Minute))
+
+	resp, err := c.BuyStamp(ctx, big.NewInt(1), 20, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	tag, err := c.CreateTag(ctx)
+	assert.NoError(t, err)
+	assert.False(t, tag.Done())
+
+	tagCtx := client.WithTag(ctx, tag.UID)
+
+	_, err = c.UploadBytes(tagCtx, make([]byte, 2*swarm.ChunkSize), resp.BatchID)
+	assert.NoError(t, err)
+
+	stream, err := c.UploadChunkStream(tagCtx, resp.BatchID)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.UploadChunk([]byte{1, 0, 0, 0, 0, 0, 0, 0, 1}))
+	assert.NoError(t, stream.Close())
+
+	tag, err = c.Tag(ctx, tag.UID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), tag.Split)
+	assert.Equal(t, int64(4), tag.Stored)
+	assert.Zero(t, tag.Synced)
+	assert.False(t, tag.Done())
+
+	clock.Advance(time.Minute)
+
+	tag, err = c.Tag(ctx, tag.UID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), tag.Synced)
+	assert.True(t, tag.Done())
+}
+
+func Test_Mock_Stewardship(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := mock.NewClient()
+
+	resp, err := c.BuyStamp(ctx, big.NewInt(1), 20, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	pinned, err := c.UploadBytes(ctx, []byte("pinned"), resp.BatchID)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Pin(ctx, pinned.Reference))
+
+	unpinned, err := c.UploadBytes(ctx, []byte("unpinned"), resp.BatchID)
+	assert.NoError(t, err)
+
+	mock.Forget(c, pinned.Reference)
+	mock.Forget(c, unpinned.Reference)
+
+	// Lost data is downloaded from the node only when it is pinned
+	for _, addr := range []swarm.Address{pinned.Reference, unpinned.Reference} {
+		retrievable, err := c.IsRetrievable(ctx, addr)
+		assert.NoError(t, err)
+		assert.False(t, retrievable.IsRetrievable)
+	}
+
+	_, err = c.DownloadBytes(ctx, pinned.Reference)
+	assert.NoError(t, err)
+
+	_, err = c.DownloadBytes(ctx, unpinned.Reference)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+
+	// Only data stored by the node can be re-uploaded
+	assert.ErrorIs(t, c.Reupload(ctx, unpinned.Reference, resp.BatchID), client.ErrNotFound)
+
+	utilization := findStamp(t, c, resp.BatchID).Utilization
+	assert.NoError(t, c.Reupload(ctx, pinned.Reference, resp.BatchID))
+	assert.Equal(t, utilization+1, findStamp(t, c, resp.BatchID).Utilization)
+
+	retrievable, err := c.IsRetrievable(ctx, pinned.Reference)
+	assert.NoError(t, err)
+	assert.True(t, retrievable.IsRetrievable)
+
+	// Data uploaded again is retrievable again
+	_, err = c.UploadBytes(ctx, []byte("unpinned"), resp.BatchID)
+	assert.NoError(t, err)
+
+	retrievable, err = c.IsRetrievable(ctx, unpinned.Reference)
+	assert.NoError(t, err)
+	assert.True(t, retrievable.IsRetrievable)
+
+	// Re-upload stamps every chunk of the tree
+	large, err := c.UploadBytes(ctx, make([]byte, 3*swarm.ChunkSize), resp.BatchID)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Pin(ctx, large.Reference))
+
+	utilization = findStamp(t, c, resp.BatchID).Utilization
+	assert.NoError(t, c.Reupload(ctx, large.Reference, resp.BatchID))
+	assert.Equal(t, utilization+uint32(stamps.ChunkCount(3*swarm.ChunkSize)),
+		findStamp(t, c, resp.BatchID).Utilization)
+}
+
+func findStamp(t *testing.T, c client.Client, batchID client.BatchID) client.Stamp {
+	t.Helper()
+
+	resp, err := c.Stamps(context.Background())
+	assert.NoError(t, err)
+
+	for _, st := range resp.Stamps {
+		if st.BatchID == batchID {
+			return st
+		}
+	}
+
+	t.Fatalf("stamp %s not found", batchID)
+
+	return client.Stamp{}
+}
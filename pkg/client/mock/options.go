@@ -0,0 +1,120 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mock
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Option configures behavior of mock client.
+type Option func(*options)
+
+type options struct {
+	usableDelay time.Duration
+	clock       Clock
+	price       *big.Int
+	syncDelay   time.Duration
+	batchOwner  common.Address
+
+	faults              []Fault
+	latency             Latency
+	partialReads        bool
+	truncateProbability float64
+	seed                int64
+}
+
+// WithUsableDelay makes bought batches usable only after the delay, as Bee
+// node waits for a few blocks after the batch is bought.
+func WithUsableDelay(delay time.Duration) Option {
+	return func(o *options) {
+		o.usableDelay = delay
+	}
+}
+
+// WithClock makes mock client use the clock instead of system time, so that
+// batch usability and expiry can be controlled by tests.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithPrice sets price of storage per chunk per second. Every batch is
+// charged the price from its balance, which is amount per chunk it was bought
+// with, and expires once the balance is spent. Batches never expire when
+// price is not set.
+func WithPrice(price *big.Int) Option {
+	return func(o *options) {
+		o.price = price
+	}
+}
+
+// WithSyncDelay makes tagged chunks synced to the network only after the
+// delay since they were uploaded. Chunks are synced right away by default.
+func WithSyncDelay(delay time.Duration) Option {
+	return func(o *options) {
+		o.syncDelay = delay
+	}
+}
+
+// WithBatchOwner makes bought batches owned by the address, so that chunks
+// stamped by its key can be uploaded with UploadStampedChunk. Stamps which are
+// not signed by the batch owner are rejected.
+func WithBatchOwner(owner common.Address) Option {
+	return func(o *options) {
+		o.batchOwner = owner
+	}
+}
+
+func makeOptions(opts []Option) options {
+	o := options{
+		clock: systemClock{},
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// Clock is source of time of mock client.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// ManualClock is Clock which moves only when it is advanced.
+type ManualClock struct {
+	now  time.Time
+	lock sync.Mutex
+}
+
+// NewManualClock creates clock set to time now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+}
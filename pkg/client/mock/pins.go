@@ -0,0 +1,136 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/ethersphere/bee/pkg/swarm"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage/stamps"
+)
+
+var errPinNotFound = fmt.Errorf("pin not found: %w", client.ErrNotFound)
+
+func (c *mockClient) Pin(
+	ctx context.Context,
+	addr swarm.Address,
+) error {
+	if err := c.chaos.inject(ctx, MethodPin); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Bee node pins only data which it stores.
+	if _, err := c.bytes(addr); err != nil {
+		return err
+	}
+
+	c.pins[addr.ByteString()] = struct{}{}
+
+	return nil
+}
+
+func (c *mockClient) Unpin(
+	ctx context.Context,
+	addr swarm.Address,
+) error {
+	if err := c.chaos.inject(ctx, MethodUnpin); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, pinned := c.pins[addr.ByteString()]; !pinned {
+		return errPinNotFound
+	}
+
+	delete(c.pins, addr.ByteString())
+
+	return nil
+}
+
+func (c *mockClient) ListPins(
+	ctx context.Context,
+) (client.PinsResponse, error) {
+	if err := c.chaos.inject(ctx, MethodListPins); err != nil {
+		return client.PinsResponse{}, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	refs := make([]swarm.Address, 0, len(c.pins))
+	for key := range c.pins {
+		refs = append(refs, swarm.NewAddress([]byte(key)))
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		return bytes.Compare(refs[i].Bytes(), refs[j].Bytes()) < 0
+	})
+
+	return client.PinsResponse{References: refs}, nil
+}
+
+func (c *mockClient) CheckPin(
+	ctx context.Context,
+	addr swarm.Address,
+) (client.PinIntegrityResponse, error) {
+	if err := c.chaos.inject(ctx, MethodCheckPin); err != nil {
+		return client.PinIntegrityResponse{}, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, pinned := c.pins[addr.ByteString()]; !pinned {
+		return client.PinIntegrityResponse{}, errPinNotFound
+	}
+
+	total, missing := c.treeChunks(addr)
+
+	return client.PinIntegrityResponse{
+		Reference: addr,
+		Total:     total,
+		Missing:   missing,
+	}, nil
+}
+
+// treeChunks returns number of chunks of the tree with the root address, and
+// number of those which are not stored. Data uploaded at once is stored
+// whole, so its chunks are counted by its size. It must be called with lock
+// held.
+func (c *mockClient) treeChunks(addr swarm.Address) (int, int) {
+	if data, exists := c.data[addr.ByteString()]; exists {
+		return stamps.ChunkCount(len(data)), 0
+	}
+
+	chunk, exists := c.chunks[addr.ByteString()]
+	if !exists {
+		return 1, 1
+	}
+
+	total, missing := 1, 0
+
+	if binary.LittleEndian.Uint64(chunk[:swarm.SpanSize]) <= swarm.ChunkSize {
+		return total, missing
+	}
+
+	for refs := chunk[swarm.SpanSize:]; len(refs) >= swarm.HashSize; refs = refs[swarm.HashSize:] {
+		childTotal, childMissing := c.treeChunks(swarm.NewAddress(refs[:swarm.HashSize]))
+		total += childTotal
+		missing += childMissing
+	}
+
+	return total, missing
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mock
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethersphere/bee/pkg/swarm"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+var errNotStoredLocally = fmt.Errorf("data is not stored by the node: %w", client.ErrNotFound)
+
+// Forget makes data with the address lost by the network, as if nodes which
+// stored it garbage collected it. Pinned data is still stored by the node, so
+// it can be downloaded and re-uploaded. Clients other than mock client are
+// not affected.
+func Forget(c client.Client, addr swarm.Address) {
+	mc, ok := c.(*mockClient)
+	if !ok {
+		return
+	}
+
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	mc.lost[addr.ByteString()] = struct{}{}
+}
+
+func (c *mockClient) IsRetrievable(
+	ctx context.Context,
+	addr swarm.Address,
+) (client.IsRetrievableResponse, error) {
+	if err := c.chaos.inject(ctx, MethodIsRetrievable); err != nil {
+		return client.IsRetrievableResponse{}, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return client.IsRetrievableResponse{IsRetrievable: c.retrievable(addr)}, nil
+}
+
+func (c *mockClient) Reupload(
+	ctx context.Context,
+	addr swarm.Address,
+	batchID client.BatchID,
+) error {
+	if err := c.chaos.inject(ctx, MethodReupload); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.available(addr) {
+		return errNotStoredLocally
+	}
+
+	// Re-uploaded chunks are stamped again, including intermediate chunks of
+	// the tree.
+	chunks, _ := c.treeChunks(addr)
+	if err := c.useStamp(batchID, dataBucket, chunks*chunkSize); err != nil {
+		return err
+	}
+
+	c.restore(addr)
+
+	return nil
+}
+
+// retrievable tells whether all chunks of the tree with the root address are
+// stored by the network. It must be called with lock held.
+func (c *mockClient) retrievable(addr swarm.Address) bool {
+	if _, lost := c.lost[addr.ByteString()]; lost {
+		return false
+	}
+
+	if _, exists := c.data[addr.ByteString()]; exists {
+		return true
+	}
+
+	chunk, exists := c.chunks[addr.ByteString()]
+	if !exists {
+		return false
+	}
+
+	if binary.LittleEndian.Uint64(chunk[:swarm.SpanSize]) <= swarm.ChunkSize {
+		return true
+	}
+
+	for refs := chunk[swarm.SpanSize:]; len(refs) >= swarm.HashSize; refs = refs[swarm.HashSize:] {
+		if !c.retrievable(swarm.NewAddress(refs[:swarm.HashSize])) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// available tells whether data with the root address can be downloaded,
+// either from the network or from pinned data of the node. It must be
+// called with lock held.
+func (c *mockClient) available(addr swarm.Address) bool {
+	if _, pinned := c.pins[addr.ByteString()]; pinned {
+		return true
+	}
+
+	return c.retrievable(addr)
+}
+
+// restore makes all chunks of the tree with the root address stored by the
+// network again. It must be called with lock held.
+func (c *mockClient) restore(addr swarm.Address) {
+	delete(c.lost, addr.ByteString())
+
+	chunk, exists := c.chunks[addr.ByteString()]
+	if !exists || binary.LittleEndian.Uint64(chunk[:swarm.SpanSize]) <= swarm.ChunkSize {
+		return
+	}
+
+	for refs := chunk[swarm.SpanSize:]; len(refs) >= swarm.HashSize; refs = refs[swarm.HashSize:] {
+		c.restore(swarm.NewAddress(refs[:swarm.HashSize]))
+	}
+}
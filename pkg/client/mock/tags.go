@@ -0,0 +1,111 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+var errTagNotFound = fmt.Errorf("tag not found: %w", client.ErrNotFound)
+
+// tagData holds chunks of uploads tagged with the tag. Chunks are stored
+// right away, and they are synced once sync delay passes since the upload.
+type tagData struct {
+	startedAt time.Time
+	uploads   []taggedUpload
+}
+
+type taggedUpload struct {
+	chunks int64
+	at     time.Time
+}
+
+func (c *mockClient) CreateTag(
+	ctx context.Context,
+) (client.TagResponse, error) {
+	if err := c.chaos.inject(ctx, MethodCreateTag); err != nil {
+		return client.TagResponse{}, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.lastTag++
+	c.tags[c.lastTag] = &tagData{startedAt: c.opts.clock.Now()}
+
+	return c.tagResponse(c.lastTag), nil
+}
+
+func (c *mockClient) Tag(
+	ctx context.Context,
+	uid client.TagID,
+) (client.TagResponse, error) {
+	if err := c.chaos.inject(ctx, MethodTag); err != nil {
+		return client.TagResponse{}, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, exists := c.tags[uid]; !exists {
+		return client.TagResponse{}, errTagNotFound
+	}
+
+	return c.tagResponse(uid), nil
+}
+
+// tagResponse returns progress of the tag. It must be called with lock held.
+func (c *mockClient) tagResponse(uid client.TagID) client.TagResponse {
+	tag := c.tags[uid]
+	resp := client.TagResponse{
+		UID:       uid,
+		StartedAt: tag.startedAt,
+	}
+
+	now := c.opts.clock.Now()
+
+	for _, u := range tag.uploads {
+		resp.Split += u.chunks
+		resp.Stored += u.chunks
+
+		if !now.Before(u.at.Add(c.opts.syncDelay)) {
+			resp.Sent += u.chunks
+			resp.Synced += u.chunks
+		}
+	}
+
+	return resp
+}
+
+// checkTag fails when the context tags uploads with tag, which does not
+// exist. It must be called with lock held.
+func (c *mockClient) checkTag(ctx context.Context) error {
+	if uid, ok := client.TagFromContext(ctx); ok {
+		if _, exists := c.tags[uid]; !exists {
+			return errTagNotFound
+		}
+	}
+
+	return nil
+}
+
+// tagUpload accounts uploaded chunks to the tag of the context. It must be
+// called with lock held, after checkTag.
+func (c *mockClient) tagUpload(ctx context.Context, chunks int) {
+	uid, ok := client.TagFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	tag := c.tags[uid]
+	tag.uploads = append(tag.uploads, taggedUpload{
+		chunks: int64(chunks),
+		at:     c.opts.clock.Now(),
+	})
+}
@@ -0,0 +1,657 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mockserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethersphere/bee/pkg/api"
+	"github.com/ethersphere/bee/pkg/swarm"
+	"github.com/gorilla/websocket"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
+)
+
+const (
+	apiPrefix = "/v1/"
+
+	headerImmutable    = "Immutable"
+	headerPostageStamp = "Swarm-Postage-Stamp"
+
+	closeReasonOverissued = "batch is overissued"
+)
+
+//nolint:gochecknoglobals
+var (
+	errMethodNotAllowed = errors.New("method not allowed")
+	errInvalidParam     = errors.New("invalid parameter")
+)
+
+type statusResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Server is local stand-in of Bee node, which serves Bee API and Bee debug
+// API over HTTP on ports of the loopback interface. Requests are served by
+// the client, which is mock client by default, so that real HTTP client can
+// be tested without Bee node.
+type Server struct {
+	beeCli client.Client
+	api    *httptest.Server
+	debug  *httptest.Server
+}
+
+// New starts server backed by new mock client.
+func New() *Server {
+	return NewWithClient(mock.NewClient())
+}
+
+// NewWithClient starts server which serves requests by calling the client.
+func NewWithClient(beeCli client.Client) *Server {
+	s := &Server{beeCli: beeCli}
+
+	s.api = httptest.NewServer(http.HandlerFunc(s.serveAPI))
+	s.debug = httptest.NewServer(http.HandlerFunc(s.serveDebugAPI))
+
+	return s
+}
+
+// Config returns config of HTTP client which connects to the server.
+func (s *Server) Config() client.Config {
+	apiURL, _ := url.Parse(s.api.URL)
+	debugURL, _ := url.Parse(s.debug.URL)
+
+	apiPort, _ := strconv.Atoi(apiURL.Port())
+	debugPort, _ := strconv.Atoi(debugURL.Port())
+
+	return client.Config{
+		NodeURL:      apiURL.Scheme + "://" + apiURL.Hostname(),
+		APIPort:      apiPort,
+		DebugAPIPort: debugPort,
+	}
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.api.Close()
+	s.debug.Close()
+}
+
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	resource, params := splitPath(r.URL.Path)
+
+	var err error
+
+	switch {
+	case resource == "tags" && r.Method == http.MethodPost && len(params) == 0:
+		err = s.createTag(w, r)
+	case resource == "tags" && r.Method == http.MethodGet && len(params) == 1:
+		err = s.tag(w, r, params[0])
+	case resource == "bytes" && r.Method == http.MethodPost && len(params) == 0:
+		err = s.uploadBytes(w, r)
+	case resource == "bytes" && (r.Method == http.MethodGet || r.Method == http.MethodHead) && len(params) == 1:
+		err = s.downloadBytes(w, r, params[0])
+	case resource == "chunks" && r.Method == http.MethodPost && len(params) == 0:
+		err = s.uploadChunk(w, r)
+	case resource == "chunks" && r.Method == http.MethodGet && len(params) == 1 && params[0] == "stream":
+		err = s.uploadChunkStream(w, r)
+	case resource == "chunks" && r.Method == http.MethodGet && len(params) == 1:
+		err = s.downloadChunk(w, r, params[0])
+	case resource == "soc" && r.Method == http.MethodPost && len(params) == 2:
+		err = s.uploadSoc(w, r, params[0], params[1])
+	case resource == "pins" && r.Method == http.MethodGet && len(params) == 0:
+		err = s.listPins(w, r)
+	case resource == "pins" && r.Method == http.MethodGet && len(params) == 1 && params[0] == "check":
+		err = s.checkPin(w, r)
+	case resource == "pins" && r.Method == http.MethodPost && len(params) == 1:
+		err = s.pin(w, r, params[0])
+	case resource == "pins" && r.Method == http.MethodDelete && len(params) == 1:
+		err = s.unpin(w, r, params[0])
+	case resource == "stewardship" && r.Method == http.MethodGet && len(params) == 1:
+		err = s.isRetrievable(w, r, params[0])
+	case resource == "stewardship" && r.Method == http.MethodPut && len(params) == 1:
+		err = s.reupload(w, r, params[0])
+	case resource == "feeds" && r.Method == http.MethodGet && len(params) == 2:
+		err = s.feedIndexLatest(w, r, params[0], params[1])
+	default:
+		err = errMethodNotAllowed
+	}
+
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func (s *Server) serveDebugAPI(w http.ResponseWriter, r *http.Request) {
+	resource, params := splitPath(r.URL.Path)
+
+	var err error
+
+	switch {
+	case resource == "stamps" && r.Method == http.MethodGet && len(params) == 0:
+		err = s.stamps(w, r)
+	case resource == "stamps" && r.Method == http.MethodPost && len(params) == 2:
+		err = s.buyStamp(w, r, params[0], params[1])
+	case resource == "stamps" && r.Method == http.MethodPatch && len(params) == 3 && params[0] == "topup":
+		err = s.topUpBatch(w, r, params[1], params[2])
+	case resource == "stamps" && r.Method == http.MethodPatch && len(params) == 3 && params[0] == "dilute":
+		err = s.diluteBatch(w, r, params[1], params[2])
+	default:
+		err = errMethodNotAllowed
+	}
+
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func (s *Server) stamps(w http.ResponseWriter, r *http.Request) error {
+	resp, err := s.beeCli.Stamps(r.Context())
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+
+	return nil
+}
+
+func (s *Server) buyStamp(w http.ResponseWriter, r *http.Request, amountParam, depthParam string) error {
+	amount, ok := new(big.Int).SetString(amountParam, 10)
+	if !ok {
+		return fmt.Errorf("%w: amount %s", errInvalidParam, amountParam)
+	}
+
+	depth, err := strconv.ParseUint(depthParam, 10, 8)
+	if err != nil {
+		return fmt.Errorf("%w: depth %s", errInvalidParam, depthParam)
+	}
+
+	immutable := r.Header.Get(headerImmutable) != "false"
+
+	opts := client.BuyStampOptions{Label: r.URL.Query().Get("label")}
+
+	resp, err := s.beeCli.BuyStamp(r.Context(), amount, uint8(depth), immutable, opts)
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+
+	return nil
+}
+
+func (s *Server) topUpBatch(w http.ResponseWriter, r *http.Request, batchID, amountParam string) error {
+	amount, ok := new(big.Int).SetString(amountParam, 10)
+	if !ok {
+		return fmt.Errorf("%w: amount %s", errInvalidParam, amountParam)
+	}
+
+	resp, err := s.beeCli.TopUpBatch(r.Context(), client.BatchID(batchID), amount)
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeJSON(w, http.StatusAccepted, resp)
+
+	return nil
+}
+
+func (s *Server) diluteBatch(w http.ResponseWriter, r *http.Request, batchID, depthParam string) error {
+	depth, err := strconv.ParseUint(depthParam, 10, 8)
+	if err != nil {
+		return fmt.Errorf("%w: depth %s", errInvalidParam, depthParam)
+	}
+
+	resp, err := s.beeCli.DiluteBatch(r.Context(), client.BatchID(batchID), uint8(depth))
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeJSON(w, http.StatusAccepted, resp)
+
+	return nil
+}
+
+func (s *Server) createTag(w http.ResponseWriter, r *http.Request) error {
+	resp, err := s.beeCli.CreateTag(r.Context())
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+
+	return nil
+}
+
+func (s *Server) tag(w http.ResponseWriter, r *http.Request, uidParam string) error {
+	uid, err := strconv.ParseUint(uidParam, 10, 32)
+	if err != nil {
+		return fmt.Errorf("%w: tag %s", errInvalidParam, uidParam)
+	}
+
+	resp, err := s.beeCli.Tag(r.Context(), client.TagID(uid))
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+
+	return nil
+}
+
+func (s *Server) uploadBytes(w http.ResponseWriter, r *http.Request) error {
+	ctx, err := uploadContext(r)
+	if err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("failed reading body: %w", err)
+	}
+
+	batchID := client.BatchID(r.Header.Get(api.SwarmPostageBatchIdHeader))
+
+	resp, err := s.beeCli.UploadBytes(ctx, data, batchID)
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+
+	return nil
+}
+
+func (s *Server) downloadBytes(w http.ResponseWriter, r *http.Request, addrParam string) error {
+	addr, err := swarm.ParseHexAddress(addrParam)
+	if err != nil {
+		return fmt.Errorf("%w: address %s", errInvalidParam, addrParam)
+	}
+
+	reader, err := s.beeCli.DownloadBytes(r.Context(), addr)
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed reading data: %w", err)
+	}
+
+	// Bee node serves range requests of bytes.
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+
+	return nil
+}
+
+func (s *Server) uploadChunk(w http.ResponseWriter, r *http.Request) error {
+	ctx, err := uploadContext(r)
+	if err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("failed reading body: %w", err)
+	}
+
+	var resp client.UploadResponse
+
+	if stampHeader := r.Header.Get(headerPostageStamp); stampHeader != "" {
+		stamp, err := hex.DecodeString(stampHeader)
+		if err != nil {
+			return fmt.Errorf("%w: stamp", errInvalidParam)
+		}
+
+		resp, err = s.beeCli.UploadStampedChunk(ctx, data, stamp)
+		if err != nil {
+			return err //nolint:wrapcheck //relax
+		}
+	} else {
+		batchID := client.BatchID(r.Header.Get(api.SwarmPostageBatchIdHeader))
+
+		resp, err = s.beeCli.UploadChunk(ctx, data, batchID)
+		if err != nil {
+			return err //nolint:wrapcheck //relax
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+
+	return nil
+}
+
+// uploadChunkStream upgrades connection to websocket and uploads chunk of
+// every binary message. Stored chunk is acknowledged with empty binary
+// message, and connection is closed with error message when upload fails.
+func (s *Server) uploadChunkStream(w http.ResponseWriter, r *http.Request) error {
+	ctx, err := uploadContext(r)
+	if err != nil {
+		return err
+	}
+
+	batchID := client.BatchID(r.Header.Get(api.SwarmPostageBatchIdHeader))
+
+	stream, err := s.beeCli.UploadChunkStream(ctx, batchID)
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	defer stream.Close()
+
+	// Upgrader responds with error itself when upgrade fails.
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return nil //nolint:nilerr // error is already responded
+	}
+
+	defer conn.Close()
+
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil //nolint:nilerr // connection is closed by client
+		}
+
+		if msgType != websocket.BinaryMessage {
+			continue
+		}
+
+		if err := stream.UploadChunk(data); err != nil {
+			// Bee node closes the stream with the reason of failed upload.
+			reason := err.Error()
+			if errors.Is(err, client.ErrStampUnusable) {
+				reason = closeReasonOverissued
+			}
+
+			msg := websocket.FormatCloseMessage(websocket.CloseInternalServerErr, reason)
+			_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+
+			return nil
+		}
+
+		if err := conn.WriteMessage(websocket.BinaryMessage, nil); err != nil {
+			return nil //nolint:nilerr // connection is closed by client
+		}
+	}
+}
+
+func (s *Server) downloadChunk(w http.ResponseWriter, r *http.Request, addrParam string) error {
+	addr, err := swarm.ParseHexAddress(addrParam)
+	if err != nil {
+		return fmt.Errorf("%w: address %s", errInvalidParam, addrParam)
+	}
+
+	reader, err := s.beeCli.DownloadChunk(r.Context(), addr)
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	return writeData(w, reader)
+}
+
+func (s *Server) uploadSoc(w http.ResponseWriter, r *http.Request, ownerParam, idParam string) error {
+	owner, err := hex.DecodeString(ownerParam)
+	if err != nil || len(owner) != common.AddressLength {
+		return fmt.Errorf("%w: owner %s", errInvalidParam, ownerParam)
+	}
+
+	id, err := hex.DecodeString(idParam)
+	if err != nil || len(id) != swarm.HashSize {
+		return fmt.Errorf("%w: id %s", errInvalidParam, idParam)
+	}
+
+	sig, err := hex.DecodeString(r.URL.Query().Get("sig"))
+	if err != nil || len(sig) != swarm.SocSignatureSize {
+		return fmt.Errorf("%w: signature", errInvalidParam)
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("failed reading body: %w", err)
+	}
+
+	batchID := client.BatchID(r.Header.Get(api.SwarmPostageBatchIdHeader))
+
+	ctx, err := uploadContext(r)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.beeCli.UploadSoc(ctx, common.BytesToAddress(owner), id, data, sig, batchID)
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+
+	return nil
+}
+
+func (s *Server) pin(w http.ResponseWriter, r *http.Request, addrParam string) error {
+	addr, err := swarm.ParseHexAddress(addrParam)
+	if err != nil {
+		return fmt.Errorf("%w: address %s", errInvalidParam, addrParam)
+	}
+
+	if err := s.beeCli.Pin(r.Context(), addr); err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeStatus(w, http.StatusCreated)
+
+	return nil
+}
+
+func (s *Server) unpin(w http.ResponseWriter, r *http.Request, addrParam string) error {
+	addr, err := swarm.ParseHexAddress(addrParam)
+	if err != nil {
+		return fmt.Errorf("%w: address %s", errInvalidParam, addrParam)
+	}
+
+	if err := s.beeCli.Unpin(r.Context(), addr); err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeStatus(w, http.StatusOK)
+
+	return nil
+}
+
+func (s *Server) listPins(w http.ResponseWriter, r *http.Request) error {
+	resp, err := s.beeCli.ListPins(r.Context())
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+
+	return nil
+}
+
+func (s *Server) checkPin(w http.ResponseWriter, r *http.Request) error {
+	addrParam := r.URL.Query().Get("ref")
+
+	addr, err := swarm.ParseHexAddress(addrParam)
+	if err != nil {
+		return fmt.Errorf("%w: address %s", errInvalidParam, addrParam)
+	}
+
+	resp, err := s.beeCli.CheckPin(r.Context(), addr)
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+
+	return nil
+}
+
+func (s *Server) isRetrievable(w http.ResponseWriter, r *http.Request, addrParam string) error {
+	addr, err := swarm.ParseHexAddress(addrParam)
+	if err != nil {
+		return fmt.Errorf("%w: address %s", errInvalidParam, addrParam)
+	}
+
+	resp, err := s.beeCli.IsRetrievable(r.Context(), addr)
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+
+	return nil
+}
+
+func (s *Server) reupload(w http.ResponseWriter, r *http.Request, addrParam string) error {
+	addr, err := swarm.ParseHexAddress(addrParam)
+	if err != nil {
+		return fmt.Errorf("%w: address %s", errInvalidParam, addrParam)
+	}
+
+	batchID := client.BatchID(r.Header.Get(api.SwarmPostageBatchIdHeader))
+
+	if err := s.beeCli.Reupload(r.Context(), addr, batchID); err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	writeStatus(w, http.StatusOK)
+
+	return nil
+}
+
+func (s *Server) feedIndexLatest(w http.ResponseWriter, r *http.Request, ownerParam, topicParam string) error {
+	owner, err := hex.DecodeString(ownerParam)
+	if err != nil || len(owner) != common.AddressLength {
+		return fmt.Errorf("%w: owner %s", errInvalidParam, ownerParam)
+	}
+
+	topic, err := hex.DecodeString(topicParam)
+	if err != nil {
+		return fmt.Errorf("%w: topic %s", errInvalidParam, topicParam)
+	}
+
+	resp, err := s.beeCli.FeedIndexLatest(r.Context(), common.BytesToAddress(owner), topic)
+	if err != nil {
+		return err //nolint:wrapcheck //relax
+	}
+
+	// Bee responds with Not Found status when feed has no updates.
+	if resp.Reference.IsZero() {
+		return client.ErrNotFound
+	}
+
+	w.Header().Set(api.SwarmFeedIndexHeader, encodeIndex(resp.Current))
+	w.Header().Set(api.SwarmFeedIndexNextHeader, encodeIndex(resp.Next))
+
+	writeJSON(w, http.StatusOK, resp)
+
+	return nil
+}
+
+// uploadContext returns context of the request, which tags upload with the
+// tag passed in the header.
+func uploadContext(r *http.Request) (context.Context, error) {
+	uidHeader := r.Header.Get(api.SwarmTagHeader)
+	if uidHeader == "" {
+		return r.Context(), nil
+	}
+
+	uid, err := strconv.ParseUint(uidHeader, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: tag %s", errInvalidParam, uidHeader)
+	}
+
+	return client.WithTag(r.Context(), client.TagID(uid)), nil
+}
+
+// splitPath returns resource and parameters of API path.
+func splitPath(path string) (string, []string) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, apiPrefix), "/"), "/")
+
+	return parts[0], parts[1:]
+}
+
+func encodeIndex(index uint64) string {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, index)
+
+	return hex.EncodeToString(buf)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	//nolint:errchkjson // status is already written
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeStatus writes response without data, whose body is status message as
+// Bee node writes it.
+func writeStatus(w http.ResponseWriter, code int) {
+	writeJSON(w, code, statusResponse{
+		Code:    code,
+		Message: http.StatusText(code),
+	})
+}
+
+func writeData(w http.ResponseWriter, reader io.ReadCloser) error {
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed reading data: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+
+	return nil
+}
+
+// writeError writes error response with status code, which Bee node uses for
+// the error.
+func writeError(w http.ResponseWriter, err error) {
+	code, ok := client.StatusCode(err)
+
+	var apiErr mock.APIError
+
+	switch {
+	case errors.As(err, &apiErr):
+		code = apiErr.Code
+	case errors.Is(err, errMethodNotAllowed):
+		code = http.StatusMethodNotAllowed
+	case !ok:
+		code = http.StatusBadRequest
+	}
+
+	writeJSON(w, code, statusResponse{
+		Code:    code,
+		Message: err.Error(),
+	})
+}
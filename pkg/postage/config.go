@@ -0,0 +1,145 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postage
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Selection is strategy of choosing batch among existing usable batches.
+type Selection int
+
+const (
+	// SelectMostCapacity chooses batch with the most remaining capacity.
+	SelectMostCapacity Selection = iota
+	// SelectLongestTTL chooses batch with the longest time to live.
+	SelectLongestTTL
+	// SelectLabel chooses only batches with label of the config, preferring
+	// the one with the most remaining capacity.
+	SelectLabel
+)
+
+// Bounds of batch depth accepted by Bee node.
+const (
+	minDepth = 17
+	maxDepth = 255
+)
+
+//nolint:gochecknoglobals
+var (
+	errInvalidAmount    = errors.New("amount must be positive")
+	errInvalidDepth     = errors.New("depth is not in acceptable range")
+	errInvalidSelection = errors.New("unknown selection strategy")
+	errMissingLabel     = errors.New("label is required by label selection")
+	errInvalidTopUp     = errors.New("invalid top up settings")
+	errInvalidDilute    = errors.New("invalid dilute settings")
+	errInvalidBackoff   = errors.New("invalid usable backoff settings")
+)
+
+// Config is policy of buying and selecting postage batches.
+type Config struct {
+	// Amount is amount per chunk paid for bought batch.
+	Amount *big.Int
+	// Depth is depth of bought batch, which holds 2^Depth chunks.
+	Depth uint8
+	// Immutable tells whether bought batch is immutable.
+	Immutable bool
+	// Label is label of bought batch.
+	Label string
+	// MaxSpend limits total cost of bought batches and top ups, which is
+	// amount times 2^depth for every batch. Spend is counted per Postage
+	// instance and it is not persisted, so the limit applies to single run
+	// of the process and it is reset by restart. nil means no limit.
+	MaxSpend *big.Int
+	// AutoBuy allows buying new batch when there is no usable batch.
+	AutoBuy bool
+	// Selection is strategy of choosing batch among existing ones.
+	Selection Selection
+
+	// TopUpTTL is time to live of the used batch below which the batch is
+	// topped up by TopUpAmount. Zero disables top up.
+	TopUpTTL time.Duration
+	// TopUpAmount is amount per chunk added to the batch by top up.
+	TopUpAmount *big.Int
+	// DiluteUtilization is fraction of used batch capacity above which the
+	// batch is diluted by DiluteDepth. Zero disables dilution.
+	DiluteUtilization float64
+	// DiluteDepth is increase of batch depth by dilution.
+	DiluteDepth uint8
+
+	// UsableTimeout limits waiting until bought batch becomes usable. Zero
+	// means waiting is limited only by context.
+	UsableTimeout time.Duration
+	// UsableBackoff is initial interval of polling whether bought batch is
+	// usable. Interval doubles after every poll up to UsableMaxBackoff.
+	UsableBackoff    time.Duration
+	UsableMaxBackoff time.Duration
+}
+
+// DefaultConfig returns config which buys immutable batches of depth 22 when
+// there is no usable batch, and waits up to 10 minutes until bought batch is
+// usable.
+func DefaultConfig() Config {
+	return Config{
+		Amount:    big.NewInt(10000000),
+		Depth:     22,
+		Immutable: true,
+		AutoBuy:   true,
+		Selection: SelectMostCapacity,
+
+		UsableTimeout:    10 * time.Minute,
+		UsableBackoff:    time.Second,
+		UsableMaxBackoff: 30 * time.Second,
+	}
+}
+
+func (c Config) validate() error {
+	if c.AutoBuy {
+		if c.Amount == nil || c.Amount.Sign() <= 0 {
+			return errInvalidAmount
+		}
+
+		if c.Depth < minDepth || c.Depth > maxDepth {
+			return fmt.Errorf("%w: %d", errInvalidDepth, c.Depth)
+		}
+
+		if c.UsableBackoff <= 0 || c.UsableMaxBackoff < c.UsableBackoff || c.UsableTimeout < 0 {
+			return errInvalidBackoff
+		}
+	}
+
+	// Batch must be maintained before it is considered unusable.
+	if c.TopUpTTL > 0 {
+		if c.TopUpTTL <= minBatchTTL || c.TopUpAmount == nil || c.TopUpAmount.Sign() <= 0 {
+			return errInvalidTopUp
+		}
+	}
+
+	if c.DiluteUtilization != 0 {
+		if c.DiluteUtilization < 0 || c.DiluteUtilization >= maxUtilization || c.DiluteDepth == 0 {
+			return errInvalidDilute
+		}
+	}
+
+	switch c.Selection {
+	case SelectMostCapacity, SelectLongestTTL:
+	case SelectLabel:
+		if c.Label == "" {
+			return errMissingLabel
+		}
+	default:
+		return fmt.Errorf("%w: %d", errInvalidSelection, c.Selection)
+	}
+
+	return nil
+}
+
+// batchCost returns total cost of batch bought with the config.
+func (c Config) batchCost() *big.Int {
+	return new(big.Int).Lsh(c.Amount, uint(c.Depth))
+}
@@ -0,0 +1,228 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+// BatchForecast is prediction of when the batch is full and when it expires.
+type BatchForecast struct {
+	BatchID client.BatchID
+	// Utilization is fraction of batch capacity which is used.
+	Utilization float64
+	// Rate is number of chunks per second recently stamped with the batch.
+	Rate float64
+	// FullAt is time when the batch is full at current rate. It is zero when
+	// nothing is written to the batch.
+	FullAt time.Time
+	// ExpiresAt is time when the batch expires. It is zero when time to live
+	// of the batch is not known.
+	ExpiresAt time.Time
+}
+
+// ForecastAlert is called with forecast of the batch, which is full or
+// expires within horizon of the alert.
+type ForecastAlert func(BatchForecast)
+
+// ForecastErrorHandler is called with error of forecast made by Watch.
+type ForecastErrorHandler func(error)
+
+// ForecastOption configures Forecaster.
+type ForecastOption func(*Forecaster)
+
+// WithFullAlert calls alert for every batch which is full within horizon.
+func WithFullAlert(horizon time.Duration, alert ForecastAlert) ForecastOption {
+	return func(f *Forecaster) {
+		f.fullHorizon = horizon
+		f.fullAlert = alert
+	}
+}
+
+// WithExpiryAlert calls alert for every batch which expires within horizon.
+func WithExpiryAlert(horizon time.Duration, alert ForecastAlert) ForecastOption {
+	return func(f *Forecaster) {
+		f.expiryHorizon = horizon
+		f.expiryAlert = alert
+	}
+}
+
+// WithErrorHandler calls handler with errors of forecasts made by Watch.
+func WithErrorHandler(handler ForecastErrorHandler) ForecastOption {
+	return func(f *Forecaster) {
+		f.errorHandler = handler
+	}
+}
+
+// Forecaster predicts capacity and expiry of batches from stamps listing and
+// rate of writes recorded by usage meter. Alert is called once when the batch
+// comes within its horizon, and again only after the batch left the horizon,
+// such as when it was topped up.
+type Forecaster struct {
+	beeCli client.Client
+	meter  *UsageMeter
+
+	fullHorizon   time.Duration
+	fullAlert     ForecastAlert
+	expiryHorizon time.Duration
+	expiryAlert   ForecastAlert
+	errorHandler  ForecastErrorHandler
+
+	// fullAlerted and expiryAlerted are batches which are within horizon
+	// of the alert, and were alerted already.
+	fullAlerted   map[client.BatchID]struct{}
+	expiryAlerted map[client.BatchID]struct{}
+	lock          sync.Mutex
+}
+
+// NewForecaster creates Forecaster of batches listed by the client.
+func NewForecaster(beeCli client.Client, meter *UsageMeter, opts ...ForecastOption) *Forecaster {
+	f := &Forecaster{
+		beeCli:        beeCli,
+		meter:         meter,
+		fullAlerted:   make(map[client.BatchID]struct{}),
+		expiryAlerted: make(map[client.BatchID]struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// Forecast returns forecast of every existing batch, and calls alerts of
+// batches which came to be full or expire soon since the last forecast.
+func (f *Forecaster) Forecast(ctx context.Context) ([]BatchForecast, error) {
+	resp, err := f.beeCli.Stamps(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetching stamps: %w", err)
+	}
+
+	now := time.Now()
+	forecasts := make([]BatchForecast, 0, len(resp.Stamps))
+
+	for _, st := range resp.Stamps {
+		if !st.Exists || st.Expired {
+			continue
+		}
+
+		fc := BatchForecast{
+			BatchID:     st.BatchID,
+			Utilization: Utilization(st),
+			Rate:        f.meter.Rate(st.BatchID),
+		}
+
+		if fc.Rate > 0 {
+			fc.FullAt = now.Add(secondsDuration(remainingCapacity(st) / fc.Rate))
+		}
+
+		if st.BatchTTL > 0 {
+			fc.ExpiresAt = now.Add(time.Duration(st.BatchTTL) * time.Second)
+		}
+
+		f.alert(now, fc)
+
+		forecasts = append(forecasts, fc)
+	}
+
+	f.prune(forecasts)
+
+	return forecasts, nil
+}
+
+// Watch calls Forecast in intervals, so that alerts are raised, until context
+// is canceled. Failed forecast is reported to error handler, and is made
+// again on the next tick.
+func (f *Forecaster) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := f.Forecast(ctx); err != nil && ctx.Err() == nil && f.errorHandler != nil {
+			f.errorHandler(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// alert calls alerts of the batch, which came within their horizon. Alerts
+// are called without lock held, so that they can call Forecast.
+func (f *Forecaster) alert(now time.Time, fc BatchForecast) {
+	full := f.fullAlert != nil &&
+		f.raise(f.fullAlerted, fc.BatchID, !fc.FullAt.IsZero() && fc.FullAt.Sub(now) < f.fullHorizon)
+	expiring := f.expiryAlert != nil &&
+		f.raise(f.expiryAlerted, fc.BatchID, !fc.ExpiresAt.IsZero() && fc.ExpiresAt.Sub(now) < f.expiryHorizon)
+
+	if full {
+		f.fullAlert(fc)
+	}
+
+	if expiring {
+		f.expiryAlert(fc)
+	}
+}
+
+// raise records whether the batch is within horizon of the alert, and tells
+// whether the alert should be called, which is when the batch was not within
+// the horizon before.
+func (f *Forecaster) raise(alerted map[client.BatchID]struct{}, batchID client.BatchID, within bool) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !within {
+		delete(alerted, batchID)
+
+		return false
+	}
+
+	if _, ok := alerted[batchID]; ok {
+		return false
+	}
+
+	alerted[batchID] = struct{}{}
+
+	return true
+}
+
+// prune forgets alerted batches which do not exist anymore.
+func (f *Forecaster) prune(forecasts []BatchForecast) {
+	existing := make(map[client.BatchID]struct{}, len(forecasts))
+	for _, fc := range forecasts {
+		existing[fc.BatchID] = struct{}{}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, alerted := range []map[client.BatchID]struct{}{f.fullAlerted, f.expiryAlerted} {
+		for batchID := range alerted {
+			if _, ok := existing[batchID]; !ok {
+				delete(alerted, batchID)
+			}
+		}
+	}
+}
+
+// secondsDuration converts seconds to duration, which is capped at maximum
+// duration.
+func secondsDuration(seconds float64) time.Duration {
+	if seconds >= float64(math.MaxInt64)/float64(time.Second) {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
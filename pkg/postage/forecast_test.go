@@ -0,0 +1,109 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postage_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage"
+)
+
+func Test_Forecast(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	beeCli := &ttlClient{Client: mock.NewClient(), ttl: 2 * 3600}
+
+	// Capacity of the batch is 2^20 chunks
+	written, err := beeCli.BuyStamp(ctx, big.NewInt(1), 20, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	idle, err := beeCli.BuyStamp(ctx, big.NewInt(1), 20, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	meter := postage.NewUsageMeter(time.Minute)
+	meter.Add(written.BatchID, 1000)
+
+	var full, expiring []postage.BatchForecast
+
+	forecaster := postage.NewForecaster(beeCli, meter,
+		postage.WithFullAlert(time.Hour, func(fc postage.BatchForecast) { full = append(full, fc) }),
+		postage.WithExpiryAlert(time.Hour, func(fc postage.BatchForecast) { expiring = append(expiring, fc) }),
+	)
+
+	start := time.Now()
+
+	forecasts, err := forecaster.Forecast(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, forecasts, 2)
+
+	for _, fc := range forecasts {
+		assert.WithinDuration(t, start.Add(2*time.Hour), fc.ExpiresAt, time.Minute)
+
+		switch fc.BatchID {
+		case written.BatchID:
+			// Rate is at most 1000 chunks per second, so batch is full
+			// in at least 2^20/1000 seconds.
+			assert.Greater(t, fc.Rate, 0.0)
+			assert.LessOrEqual(t, fc.Rate, 1000.0)
+			assert.True(t, fc.FullAt.After(start.Add(17*time.Minute)))
+		case idle.BatchID:
+			assert.Zero(t, fc.Rate)
+			assert.True(t, fc.FullAt.IsZero())
+		}
+	}
+
+	assert.Len(t, full, 1)
+	assert.Equal(t, written.BatchID, full[0].BatchID)
+	assert.Empty(t, expiring)
+
+	// Batch which stays within horizon is alerted only once
+	_, err = forecaster.Forecast(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, full, 1)
+}
+
+func Test_ForecastWatch(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	beeCli := mock.NewClientWithOptions(mock.WithFaults(mock.Fault{Method: mock.MethodStamps, Probability: 1}))
+
+	errC := make(chan error, 1)
+
+	forecaster := postage.NewForecaster(beeCli, postage.NewUsageMeter(time.Minute),
+		postage.WithErrorHandler(func(err error) {
+			select {
+			case errC <- err:
+			default:
+			}
+		}),
+	)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		forecaster.Watch(ctx, time.Millisecond)
+	}()
+
+	// Watch keeps forecasting after failure
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, <-errC, mock.ErrServiceUnavailable)
+	}
+
+	cancel()
+	<-done
+}
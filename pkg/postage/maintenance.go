@@ -0,0 +1,129 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postage
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+// maintenanceTimeout is time after which top up or dilution, which is not
+// yet reflected by the stamp, is considered failed and is requested again.
+const maintenanceTimeout = 10 * time.Minute
+
+// pendingMaintenance records changes of the batch which were requested, but
+// may not be confirmed on chain yet.
+type pendingMaintenance struct {
+	// amount is amount of the batch at the time of top up.
+	amount   *big.Int
+	toppedUp time.Time
+	// depth is depth which the batch was diluted to.
+	depth   uint8
+	diluted time.Time
+}
+
+// maintainAsync maintains the batch in background, so that on-chain
+// transactions do not delay uploads. Only one maintenance runs at a time, and
+// batch which still needs maintenance is maintained on the next check.
+func (p *postage) maintainAsync(st client.Stamp) {
+	if !p.needsTopUp(st) && !p.needsDilute(st) {
+		return
+	}
+
+	select {
+	case p.maintainC <- struct{}{}:
+	default:
+		return
+	}
+
+	go func() {
+		defer func() { <-p.maintainC }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), maintenanceTimeout)
+		defer cancel()
+
+		p.maintain(ctx, st)
+	}()
+}
+
+// maintain tops up and dilutes the batch according to the config, so that a
+// single batch can be used for a long time. Maintenance is best effort, and
+// failed requests are retried on the next check.
+func (p *postage) maintain(ctx context.Context, st client.Stamp) {
+	if !p.needsTopUp(st) && !p.needsDilute(st) {
+		return
+	}
+
+	p.lock.Lock()
+	pending := p.pending[st.BatchID]
+	p.lock.Unlock()
+
+	amount := stampAmount(st)
+
+	if p.needsTopUp(st) && !isPending(pending.amount != nil && pending.amount.Cmp(amount) == 0, pending.toppedUp) {
+		if p.topUp(ctx, st) {
+			pending.amount = amount
+			pending.toppedUp = time.Now()
+		}
+	}
+
+	if p.needsDilute(st) && !isPending(st.Depth < pending.depth, pending.diluted) {
+		depth := st.Depth + p.cfg.DiluteDepth
+
+		if _, err := p.beeCli.DiluteBatch(ctx, st.BatchID, depth); err == nil {
+			pending.depth = depth
+			pending.diluted = time.Now()
+		}
+	}
+
+	p.lock.Lock()
+	p.pending[st.BatchID] = pending
+	p.lock.Unlock()
+}
+
+func (p *postage) needsTopUp(st client.Stamp) bool {
+	return p.cfg.TopUpTTL > 0 &&
+		st.BatchTTL > 0 &&
+		time.Duration(st.BatchTTL)*time.Second < p.cfg.TopUpTTL
+}
+
+func (p *postage) needsDilute(st client.Stamp) bool {
+	return p.cfg.DiluteUtilization > 0 &&
+		Utilization(st) >= p.cfg.DiluteUtilization &&
+		int(st.Depth)+int(p.cfg.DiluteDepth) <= maxDepth
+}
+
+// topUp tops up the batch, if the cost does not exceed maximum spend.
+func (p *postage) topUp(ctx context.Context, st client.Stamp) bool {
+	cost := new(big.Int).Lsh(p.cfg.TopUpAmount, uint(st.Depth))
+	if err := p.reserveSpend(cost); err != nil {
+		return false
+	}
+
+	if _, err := p.beeCli.TopUpBatch(ctx, st.BatchID, p.cfg.TopUpAmount); err != nil {
+		p.releaseSpend(cost)
+
+		return false
+	}
+
+	return true
+}
+
+// isPending reports whether requested change, which is not yet reflected by
+// the stamp, can still be confirmed.
+func isPending(unconfirmed bool, requested time.Time) bool {
+	return unconfirmed && time.Since(requested) < maintenanceTimeout
+}
+
+func stampAmount(st client.Stamp) *big.Int {
+	if st.Amount == nil || st.Amount.Int == nil {
+		return new(big.Int)
+	}
+
+	return new(big.Int).Set(st.Amount.Int)
+}
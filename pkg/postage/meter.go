@@ -0,0 +1,103 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+// meterSlots is number of slots the window of usage meter is split into.
+const meterSlots = 60
+
+// UsageMeter records number of chunks stamped with every batch, so that
+// recent rate of writes can be estimated.
+type UsageMeter struct {
+	window time.Duration
+	slot   time.Duration
+	start  time.Time
+	usage  map[client.BatchID][]usageSlot
+	lock   sync.Mutex
+}
+
+type usageSlot struct {
+	index  int64
+	chunks int64
+}
+
+// NewUsageMeter creates meter which estimates rate of writes over the window.
+func NewUsageMeter(window time.Duration) *UsageMeter {
+	slot := window / meterSlots
+	if slot <= 0 {
+		slot = 1
+	}
+
+	return &UsageMeter{
+		window: window,
+		slot:   slot,
+		start:  time.Now(),
+		usage:  make(map[client.BatchID][]usageSlot),
+	}
+}
+
+// Add records chunks stamped with the batch.
+func (m *UsageMeter) Add(batchID client.BatchID, chunks int) {
+	index := time.Now().UnixNano() / int64(m.slot)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	slots := m.prune(m.usage[batchID], index)
+
+	if n := len(slots); n > 0 && slots[n-1].index == index {
+		slots[n-1].chunks += int64(chunks)
+	} else {
+		slots = append(slots, usageSlot{index: index, chunks: int64(chunks)})
+	}
+
+	m.usage[batchID] = slots
+}
+
+// Rate returns number of chunks per second stamped with the batch over the
+// window. Rate is averaged over shorter period, when meter is younger than
+// the window.
+func (m *UsageMeter) Rate(batchID client.BatchID) float64 {
+	now := time.Now()
+	index := now.UnixNano() / int64(m.slot)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	slots := m.prune(m.usage[batchID], index)
+	m.usage[batchID] = slots
+
+	var chunks int64
+	for _, s := range slots {
+		chunks += s.chunks
+	}
+
+	period := m.window
+	if elapsed := now.Sub(m.start); elapsed < period {
+		period = elapsed
+	}
+
+	if period < time.Second {
+		period = time.Second
+	}
+
+	return float64(chunks) / period.Seconds()
+}
+
+// prune drops slots which are older than the window.
+func (m *UsageMeter) prune(slots []usageSlot, index int64) []usageSlot {
+	first := 0
+	for first < len(slots) && slots[first].index <= index-meterSlots {
+		first++
+	}
+
+	return slots[first:]
+}
@@ -8,76 +8,433 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"sync"
+	"time"
 
 	"github.com/ethersphere/eth-on-bzz/pkg/client"
 )
 
+const (
+	// maxUtilization is fraction of batch capacity after which batch is
+	// replaced, so that uploads in flight do not hit saturated batch.
+	maxUtilization = 0.9
+
+	// minBatchTTL is remaining time to live after which batch is replaced.
+	minBatchTTL = time.Hour
+
+	// checkInterval is how often utilization of current batch is refreshed.
+	checkInterval = 30 * time.Second
+
+	// invalidTimeout is time after which invalidated batch can be selected
+	// again, in case its failure was transient.
+	invalidTimeout = 10 * time.Minute
+)
+
+//nolint:gochecknoglobals
+var (
+	// ErrNoUsableBatch is returned when there is no usable batch and buying
+	// is not allowed.
+	ErrNoUsableBatch = errors.New("no usable batch found")
+
+	// ErrSpendLimit is returned when buying batch would exceed the maximum
+	// spend of the config.
+	ErrSpendLimit = errors.New("batch purchase exceeds spend limit")
+
+	// ErrBatchNotUsable is returned when bought batch does not become usable
+	// in time.
+	ErrBatchNotUsable = errors.New("bought batch is not usable")
+)
+
 type Postage interface {
 	CurrentBatchID(context.Context) (client.BatchID, error)
+
+	// Invalidate tells that upload stamped with the batch failed because the
+	// batch is not usable anymore, so that following uploads use another
+	// batch. Batch can be selected again after it is topped up or diluted,
+	// or after a while, in case the failure was transient.
+	Invalidate(batchID client.BatchID)
 }
 
+// New creates Postage with default config.
 func New(beeCli client.Client) Postage {
+	return newPostage(beeCli, DefaultConfig())
+}
+
+// NewWithConfig creates Postage which buys and selects batches according to
+// the config.
+func NewWithConfig(beeCli client.Client, cfg Config) (Postage, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid postage config: %w", err)
+	}
+
+	return newPostage(beeCli, cfg), nil
+}
+
+func newPostage(beeCli client.Client, cfg Config) *postage {
 	return &postage{
-		beeCli: beeCli,
+		beeCli:    beeCli,
+		cfg:       cfg,
+		spent:     new(big.Int),
+		invalid:   make(map[client.BatchID]invalidBatch),
+		seen:      make(map[client.BatchID]client.Stamp),
+		pending:   make(map[client.BatchID]pendingMaintenance),
+		refreshC:  make(chan struct{}, 1),
+		maintainC: make(chan struct{}, 1),
 	}
 }
 
 type postage struct {
 	beeCli  client.Client
+	cfg     Config
+	spent   *big.Int
 	batchID client.BatchID
+	bought  client.BatchID // bought batch which is not usable yet
+	checked time.Time
+	invalid map[client.BatchID]invalidBatch
+	seen    map[client.BatchID]client.Stamp // stamps of the last listing
+	pending map[client.BatchID]pendingMaintenance
 	lock    sync.Mutex
+
+	// refreshC serializes refreshes of current batch.
+	refreshC chan struct{}
+	// maintainC is full while maintenance runs in background.
+	maintainC chan struct{}
 }
 
+// CurrentBatchID returns batch which should be used for uploads. Utilization
+// of the batch is checked periodically, and batch is replaced by another
+// usable batch, or newly bought one, before it saturates.
+//
+//nolint:wrapcheck //relax
 func (p *postage) CurrentBatchID(ctx context.Context) (client.BatchID, error) {
-	p.lock.Lock()
-	batchID := p.batchID
-	p.lock.Unlock()
+	current, ok := p.cachedBatchID()
+	if ok {
+		return current, nil
+	}
+
+	// Only one refresh runs at a time, so that batches are not bought in
+	// parallel. Callers which have no batch to use wait for the refresh and
+	// then use its result, while others keep using current batch.
+	select {
+	case p.refreshC <- struct{}{}:
+	default:
+		if current != "" {
+			return current, nil
+		}
 
-	if batchID != "" {
+		select {
+		case p.refreshC <- struct{}{}:
+		case <-ctx.Done():
+			return client.BatchID(""), ctx.Err()
+		}
+	}
+	defer func() { <-p.refreshC }()
+
+	batchID, ok := p.cachedBatchID()
+	if ok {
 		return batchID, nil
 	}
 
-	batchID, err := p.fetchOrBuyStamp(ctx)
+	batchID, err := p.fetchOrBuyStamp(ctx, batchID)
 	if err != nil {
 		return batchID, err
 	}
 
 	p.lock.Lock()
 	p.batchID = batchID
+	p.checked = time.Now()
 	p.lock.Unlock()
 
 	return batchID, nil
 }
 
-func (p *postage) fetchOrBuyStamp(ctx context.Context) (client.BatchID, error) {
-	batchID, err := p.fetchFirstUsableStamp(ctx)
-	if errors.Is(err, errNoUsableBatch) {
-		resp, err := p.beeCli.BuyStamp(ctx, big.NewInt(10000000), 22, true)
+// cachedBatchID returns current batch and reports whether it does not need
+// to be refreshed.
+func (p *postage) cachedBatchID() (client.BatchID, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.batchID, p.batchID != "" && time.Since(p.checked) < checkInterval
+}
+
+// invalidBatch records batch which was invalidated, together with its stamp
+// at the time, when it is known.
+type invalidBatch struct {
+	at    time.Time
+	stamp *client.Stamp
+}
+
+// cleared reports whether invalidated batch can be selected again, which is
+// when invalidation timed out, or when the batch was topped up or diluted
+// since.
+func (b invalidBatch) cleared(st client.Stamp) bool {
+	if time.Since(b.at) >= invalidTimeout {
+		return true
+	}
+
+	return b.stamp != nil &&
+		(b.stamp.Depth != st.Depth || stampAmount(*b.stamp).Cmp(stampAmount(st)) != 0)
+}
+
+func (p *postage) Invalidate(batchID client.BatchID) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	invalid := invalidBatch{at: time.Now()}
+	if st, ok := p.seen[batchID]; ok {
+		invalid.stamp = &st
+	}
+
+	p.invalid[batchID] = invalid
+
+	if p.batchID == batchID {
+		p.batchID = ""
+	}
+
+	if p.bought == batchID {
+		p.bought = ""
+	}
+}
+
+func (p *postage) fetchOrBuyStamp(ctx context.Context, current client.BatchID) (client.BatchID, error) {
+	st, err := p.fetchUsableStamp(ctx, current)
+	if errors.Is(err, ErrNoUsableBatch) && p.cfg.AutoBuy {
+		return p.buyUsableStamp(ctx)
+	}
+
+	if err != nil {
+		return client.BatchID(""), err
+	}
+
+	p.maintainAsync(st)
+
+	return st.BatchID, nil
+}
+
+// buyUsableStamp buys new batch and waits until it is usable. When waiting
+// fails, the batch is remembered, so that the next call waits for the same
+// batch instead of buying another one.
+func (p *postage) buyUsableStamp(ctx context.Context) (client.BatchID, error) {
+	p.lock.Lock()
+	batchID := p.bought
+	p.lock.Unlock()
+
+	if batchID == "" {
+		var err error
+
+		batchID, err = p.buyStamp(ctx)
 		if err != nil {
 			return client.BatchID(""), err
 		}
 
-		return resp.BatchID, nil
+		p.lock.Lock()
+		p.bought = batchID
+		p.lock.Unlock()
 	}
 
-	return batchID, err
+	if err := p.waitUsable(ctx, batchID); err != nil {
+		return client.BatchID(""), err
+	}
+
+	p.lock.Lock()
+	p.bought = ""
+	p.lock.Unlock()
+
+	return batchID, nil
 }
 
-var errNoUsableBatch = fmt.Errorf("no usable batch found")
+// waitUsable polls stamps with exponential backoff until the batch is usable.
+func (p *postage) waitUsable(ctx context.Context, batchID client.BatchID) error {
+	if p.cfg.UsableTimeout > 0 {
+		var cancel context.CancelFunc
 
-func (p *postage) fetchFirstUsableStamp(ctx context.Context) (client.BatchID, error) {
-	resp, err := p.beeCli.Stamps(ctx)
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.UsableTimeout)
+		defer cancel()
+	}
+
+	backoff := p.cfg.UsableBackoff
+
+	for {
+		resp, err := p.beeCli.Stamps(ctx)
+		if err != nil {
+			return fmt.Errorf("waiting for batch %s: %w", batchID, err)
+		}
+
+		for _, st := range resp.Stamps {
+			if st.BatchID == batchID && st.Usable && st.Exists {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return batchNotUsableError{batchID: batchID, err: ctx.Err()}
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > p.cfg.UsableMaxBackoff {
+			backoff = p.cfg.UsableMaxBackoff
+		}
+	}
+}
+
+// batchNotUsableError is returned when ctx is done before bought batch
+// becomes usable. It matches both ErrBatchNotUsable and error of the context.
+type batchNotUsableError struct {
+	batchID client.BatchID
+	err     error
+}
+
+func (e batchNotUsableError) Error() string {
+	return fmt.Sprintf("%v: %s: %v", ErrBatchNotUsable, e.batchID, e.err)
+}
+
+func (e batchNotUsableError) Unwrap() error {
+	return e.err
+}
+
+func (e batchNotUsableError) Is(target error) bool {
+	return target == ErrBatchNotUsable
+}
+
+//nolint:wrapcheck //relax
+func (p *postage) buyStamp(ctx context.Context) (client.BatchID, error) {
+	cost := p.cfg.batchCost()
+	if err := p.reserveSpend(cost); err != nil {
+		return client.BatchID(""), err
+	}
+
+	opts := client.BuyStampOptions{Label: p.cfg.Label}
+
+	resp, err := p.beeCli.BuyStamp(ctx, p.cfg.Amount, p.cfg.Depth, p.cfg.Immutable, opts)
 	if err != nil {
+		p.releaseSpend(cost)
+
 		return client.BatchID(""), err
 	}
 
+	return resp.BatchID, nil
+}
+
+// reserveSpend adds cost to total spend, unless it would exceed maximum spend
+// of the config.
+func (p *postage) reserveSpend(cost *big.Int) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.cfg.MaxSpend != nil && new(big.Int).Add(p.spent, cost).Cmp(p.cfg.MaxSpend) > 0 {
+		return ErrSpendLimit
+	}
+
+	p.spent.Add(p.spent, cost)
+
+	return nil
+}
+
+// releaseSpend subtracts cost of failed purchase from total spend.
+func (p *postage) releaseSpend(cost *big.Int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.spent.Sub(p.spent, cost)
+}
+
+// fetchUsableStamp returns current batch if it is still usable, otherwise
+// usable batch chosen by selection strategy of the config.
+//
+//nolint:wrapcheck //relax
+func (p *postage) fetchUsableStamp(ctx context.Context, current client.BatchID) (client.Stamp, error) {
+	resp, err := p.beeCli.Stamps(ctx)
+	if err != nil {
+		return client.Stamp{}, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.seen = make(map[client.BatchID]client.Stamp, len(resp.Stamps))
 	for _, st := range resp.Stamps {
-		if st.Usable && st.Exists {
-			return st.BatchID, nil
+		p.seen[st.BatchID] = st
+	}
+
+	var best *client.Stamp
+
+	for i, st := range resp.Stamps {
+		if !p.isUsable(st) {
+			continue
+		}
+
+		if p.cfg.Selection == SelectLabel && st.Label != p.cfg.Label {
+			continue
+		}
+
+		if st.BatchID == current {
+			return st, nil
+		}
+
+		if best == nil || p.isBetter(st, *best) {
+			best = &resp.Stamps[i]
+		}
+	}
+
+	if best == nil {
+		return client.Stamp{}, ErrNoUsableBatch
+	}
+
+	return *best, nil
+}
+
+// isUsable reports whether uploads can be stamped with the batch. It must be
+// called with lock held.
+func (p *postage) isUsable(st client.Stamp) bool {
+	if !st.Usable || !st.Exists || st.Expired {
+		return false
+	}
+
+	if invalid, ok := p.invalid[st.BatchID]; ok {
+		if !invalid.cleared(st) {
+			return false
 		}
+
+		delete(p.invalid, st.BatchID)
+	}
+
+	// Zero or negative TTL is reported when it is not known.
+	if st.BatchTTL > 0 && time.Duration(st.BatchTTL)*time.Second < minBatchTTL {
+		return false
+	}
+
+	return Utilization(st) < maxUtilization
+}
+
+// isBetter reports whether batch a is preferred over batch b by selection
+// strategy of the config.
+func (p *postage) isBetter(a, b client.Stamp) bool {
+	if p.cfg.Selection == SelectLongestTTL {
+		return a.BatchTTL > b.BatchTTL
 	}
 
-	return client.BatchID(""), errNoUsableBatch
+	return remainingCapacity(a) > remainingCapacity(b)
+}
+
+// Utilization returns fraction of batch capacity which is used. Stamp
+// utilization is number of chunks in the fullest bucket of the batch, and the
+// batch saturates when any of its buckets is full.
+func Utilization(st client.Stamp) float64 {
+	if st.Depth <= st.BucketDepth {
+		return 1
+	}
+
+	capacity := uint64(1) << (st.Depth - st.BucketDepth)
+
+	return float64(st.Utilization) / float64(capacity)
+}
+
+// remainingCapacity returns approximate number of chunks which can be still
+// stamped with the batch.
+func remainingCapacity(st client.Stamp) float64 {
+	return math.Ldexp(1-Utilization(st), int(st.Depth))
 }
@@ -0,0 +1,459 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postage_test
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/bee/pkg/swarm"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage"
+)
+
+func Test_Postage(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	beeCli := mock.NewClient()
+
+	// Capacity of the batch is 16 chunks
+	resp, err := beeCli.BuyStamp(ctx, big.NewInt(1), 20, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	// Existing usable batch is used
+	batchID, err := postage.New(beeCli).CurrentBatchID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, resp.BatchID, batchID)
+
+	fillBatch(t, beeCli, batchID, 15)
+
+	_, err = beeCli.UploadBytes(ctx, make([]byte, 2*swarm.ChunkSize), batchID)
+	assert.ErrorIs(t, err, client.ErrStampUnusable)
+
+	// Nearly saturated batch is not used
+	p := postage.New(beeCli)
+	newBatchID, err := p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+	assert.NotEqual(t, batchID, newBatchID)
+
+	// Invalidated batch is replaced
+	p.Invalidate(newBatchID)
+
+	otherBatchID, err := p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+	assert.NotEqual(t, batchID, otherBatchID)
+	assert.NotEqual(t, newBatchID, otherBatchID)
+}
+
+func Test_PostageInvalidate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	beeCli := mock.NewClient()
+
+	resp, err := beeCli.BuyStamp(ctx, big.NewInt(1000), 24, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	_, err = beeCli.BuyStamp(ctx, big.NewInt(1000), 22, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	p, err := postage.NewWithConfig(beeCli, postage.Config{})
+	assert.NoError(t, err)
+
+	batchID, err := p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, resp.BatchID, batchID)
+
+	// Invalidated batch is not selected while it does not change
+	p.Invalidate(batchID)
+
+	otherBatchID, err := p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+	assert.NotEqual(t, batchID, otherBatchID)
+
+	// Batch which was topped up since invalidation is selected again
+	_, err = beeCli.TopUpBatch(ctx, batchID, big.NewInt(1000))
+	assert.NoError(t, err)
+
+	p.Invalidate(otherBatchID)
+
+	batchID, err = p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, resp.BatchID, batchID)
+}
+
+func Test_PostageConfig(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("validation", func(t *testing.T) {
+		t.Parallel()
+
+		beeCli := mock.NewClient()
+
+		cfg := postage.DefaultConfig()
+		cfg.Amount = nil
+		_, err := postage.NewWithConfig(beeCli, cfg)
+		assert.Error(t, err)
+
+		cfg = postage.DefaultConfig()
+		cfg.Depth = 16
+		_, err = postage.NewWithConfig(beeCli, cfg)
+		assert.Error(t, err)
+
+		cfg = postage.DefaultConfig()
+		cfg.Selection = postage.SelectLabel
+		_, err = postage.NewWithConfig(beeCli, cfg)
+		assert.Error(t, err)
+
+		// Amount and depth are not needed when buying is not allowed
+		_, err = postage.NewWithConfig(beeCli, postage.Config{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("no auto buy", func(t *testing.T) {
+		t.Parallel()
+
+		beeCli := mock.NewClient()
+
+		p, err := postage.NewWithConfig(beeCli, postage.Config{})
+		assert.NoError(t, err)
+
+		_, err = p.CurrentBatchID(ctx)
+		assert.ErrorIs(t, err, postage.ErrNoUsableBatch)
+	})
+
+	t.Run("spend limit", func(t *testing.T) {
+		t.Parallel()
+
+		beeCli := mock.NewClient()
+
+		cfg := postage.DefaultConfig()
+		cfg.Amount = big.NewInt(10)
+		cfg.Depth = 20
+		cfg.MaxSpend = big.NewInt(10 << 20)
+
+		p, err := postage.NewWithConfig(beeCli, cfg)
+		assert.NoError(t, err)
+
+		batchID, err := p.CurrentBatchID(ctx)
+		assert.NoError(t, err)
+
+		p.Invalidate(batchID)
+
+		_, err = p.CurrentBatchID(ctx)
+		assert.ErrorIs(t, err, postage.ErrSpendLimit)
+	})
+
+	t.Run("label", func(t *testing.T) {
+		t.Parallel()
+
+		beeCli := mock.NewClient()
+
+		_, err := beeCli.BuyStamp(ctx, big.NewInt(1), 24, true, client.BuyStampOptions{Label: "other"})
+		assert.NoError(t, err)
+
+		cfg := postage.DefaultConfig()
+		cfg.Label = "mine"
+		cfg.Selection = postage.SelectLabel
+
+		p, err := postage.NewWithConfig(beeCli, cfg)
+		assert.NoError(t, err)
+
+		batchID, err := p.CurrentBatchID(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "mine", stampOf(t, beeCli, batchID).Label)
+	})
+
+	t.Run("most capacity", func(t *testing.T) {
+		t.Parallel()
+
+		beeCli := mock.NewClient()
+
+		for _, depth := range []uint8{20, 24, 22} {
+			_, err := beeCli.BuyStamp(ctx, big.NewInt(1), depth, true, client.BuyStampOptions{})
+			assert.NoError(t, err)
+		}
+
+		p, err := postage.NewWithConfig(beeCli, postage.Config{})
+		assert.NoError(t, err)
+
+		batchID, err := p.CurrentBatchID(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(24), stampOf(t, beeCli, batchID).Depth)
+	})
+}
+
+func Test_PostageMaintenance(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	beeCli := &ttlClient{Client: mock.NewClient(), ttl: 2 * 3600}
+
+	// Capacity of the batch is 16 chunks
+	resp, err := beeCli.BuyStamp(ctx, big.NewInt(1000), 20, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	fillBatch(t, beeCli, resp.BatchID, 9)
+
+	cfg := postage.DefaultConfig()
+	cfg.TopUpTTL = 3 * time.Hour
+	cfg.TopUpAmount = big.NewInt(1000)
+	cfg.DiluteUtilization = 0.5
+	cfg.DiluteDepth = 1
+
+	p, err := postage.NewWithConfig(beeCli, cfg)
+	assert.NoError(t, err)
+
+	batchID, err := p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, resp.BatchID, batchID)
+
+	// Batch is topped up to 2000 and then diluted, which halves the amount.
+	// Maintenance runs in background.
+	assert.Eventually(t, func() bool {
+		st := stampOf(t, beeCli, batchID)
+
+		return st.Depth == 21 && st.Amount.Int64() == 1000
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_PostageWaitUsable(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("usable", func(t *testing.T) {
+		t.Parallel()
+
+		delay := 100 * time.Millisecond
+		beeCli := mock.NewClientWithOptions(mock.WithUsableDelay(delay))
+
+		cfg := postage.DefaultConfig()
+		cfg.UsableBackoff = 10 * time.Millisecond
+
+		p, err := postage.NewWithConfig(beeCli, cfg)
+		assert.NoError(t, err)
+
+		start := time.Now()
+
+		batchID, err := p.CurrentBatchID(ctx)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), delay)
+
+		_, err = beeCli.UploadBytes(ctx, []byte("data"), batchID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Parallel()
+
+		beeCli := mock.NewClientWithOptions(mock.WithUsableDelay(time.Hour))
+
+		cfg := postage.DefaultConfig()
+		cfg.UsableTimeout = 50 * time.Millisecond
+		cfg.UsableBackoff = 10 * time.Millisecond
+
+		p, err := postage.NewWithConfig(beeCli, cfg)
+		assert.NoError(t, err)
+
+		_, err = p.CurrentBatchID(ctx)
+		assert.ErrorIs(t, err, postage.ErrBatchNotUsable)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// Batch which is not usable yet is not bought again
+		_, err = p.CurrentBatchID(ctx)
+		assert.ErrorIs(t, err, postage.ErrBatchNotUsable)
+
+		resp, err := beeCli.Stamps(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, resp.Stamps, 1)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		t.Parallel()
+
+		beeCli := mock.NewClientWithOptions(mock.WithUsableDelay(time.Hour))
+
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := postage.New(beeCli).CurrentBatchID(cctx)
+		assert.ErrorIs(t, err, postage.ErrBatchNotUsable)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func Test_PostageConcurrentPurchase(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	beeCli := &buyCountingClient{Client: mock.NewClient()}
+	p := postage.New(beeCli)
+
+	const callers = 64
+
+	var wg sync.WaitGroup
+
+	batchIDs := make([]client.BatchID, callers)
+	startC := make(chan struct{})
+
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			<-startC
+
+			batchID, err := p.CurrentBatchID(ctx)
+			assert.NoError(t, err)
+
+			batchIDs[i] = batchID
+		}(i)
+	}
+
+	close(startC)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), beeCli.buys.Load())
+
+	for _, batchID := range batchIDs {
+		assert.Equal(t, batchIDs[0], batchID)
+	}
+}
+
+func Test_PostageExpiry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := mock.NewManualClock(time.Unix(1_000_000, 0))
+	beeCli := mock.NewClientWithOptions(mock.WithClock(clock), mock.WithPrice(big.NewInt(1)))
+
+	// Batch lives for two hours
+	resp, err := beeCli.BuyStamp(ctx, big.NewInt(2*3600), 20, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	p := postage.New(beeCli)
+
+	batchID, err := p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, resp.BatchID, batchID)
+
+	// Batch which expires soon is not selected
+	clock.Advance(90 * time.Minute)
+
+	batchID, err = postage.New(beeCli).CurrentBatchID(ctx)
+	assert.NoError(t, err)
+	assert.NotEqual(t, resp.BatchID, batchID)
+
+	// Upload with expired batch fails, and batch is replaced once invalidated
+	clock.Advance(time.Hour)
+
+	_, err = beeCli.UploadBytes(ctx, []byte("data"), resp.BatchID)
+	assert.ErrorIs(t, err, client.ErrStampUnusable)
+
+	p.Invalidate(resp.BatchID)
+
+	batchID, err = p.CurrentBatchID(ctx)
+	assert.NoError(t, err)
+	assert.NotEqual(t, resp.BatchID, batchID)
+
+	_, err = beeCli.UploadBytes(ctx, []byte("data"), batchID)
+	assert.NoError(t, err)
+}
+
+func Test_Utilization(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		stamp client.Stamp
+		want  float64
+	}{
+		{stamp: client.Stamp{Depth: 20, BucketDepth: 16, Utilization: 0}, want: 0},
+		{stamp: client.Stamp{Depth: 20, BucketDepth: 16, Utilization: 4}, want: 0.25},
+		{stamp: client.Stamp{Depth: 17, BucketDepth: 16, Utilization: 2}, want: 1},
+		{stamp: client.Stamp{Depth: 16, BucketDepth: 16}, want: 1},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, postage.Utilization(tc.stamp))
+	}
+}
+
+func fillBatch(t *testing.T, beeCli client.Client, batchID client.BatchID, chunks int) {
+	t.Helper()
+
+	for i := 0; i < chunks; i++ {
+		data := make([]byte, swarm.ChunkSize)
+		data[0] = byte(i)
+
+		_, err := beeCli.UploadBytes(context.Background(), data, batchID)
+		assert.NoError(t, err)
+	}
+}
+
+func stampOf(t *testing.T, beeCli client.Client, batchID client.BatchID) client.Stamp {
+	t.Helper()
+
+	resp, err := beeCli.Stamps(context.Background())
+	assert.NoError(t, err)
+
+	for _, st := range resp.Stamps {
+		if st.BatchID == batchID {
+			return st
+		}
+	}
+
+	t.Fatalf("stamp %s not found", batchID)
+
+	return client.Stamp{}
+}
+
+// ttlClient reports the same time to live of every batch.
+type ttlClient struct {
+	client.Client
+	ttl int64
+}
+
+//nolint:wrapcheck //relax
+func (c *ttlClient) Stamps(ctx context.Context) (client.StampsResponse, error) {
+	resp, err := c.Client.Stamps(ctx)
+	for i := range resp.Stamps {
+		resp.Stamps[i].BatchTTL = c.ttl
+	}
+
+	return resp, err
+}
+
+// buyCountingClient counts bought batches. Purchase is delayed as on-chain
+// transaction would be.
+type buyCountingClient struct {
+	client.Client
+	buys atomic.Int64
+}
+
+//nolint:wrapcheck //relax
+func (c *buyCountingClient) BuyStamp(
+	ctx context.Context,
+	amount *big.Int,
+	depth uint8,
+	immutable bool,
+	opts client.BuyStampOptions,
+) (client.BuyStampResponse, error) {
+	c.buys.Add(1)
+	time.Sleep(10 * time.Millisecond)
+
+	return c.Client.BuyStamp(ctx, amount, depth, immutable, opts)
+}
@@ -0,0 +1,162 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postage
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/ethersphere/bee/pkg/swarm"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage/stamps"
+)
+
+//nolint:gochecknoglobals
+var (
+	// ErrBucketFull is returned when all stamps of the bucket, which chunk
+	// address falls into, are issued.
+	ErrBucketFull = fmt.Errorf("bucket is full: %w", client.ErrStampUnusable)
+
+	errInvalidBatchID = errors.New("invalid batch id")
+)
+
+// Stamper issues postage stamps of the batch on the client side, so that
+// chunks can be uploaded to any Bee node. Batch is split into 2^bucketDepth
+// buckets by leading bits of chunk address, and every bucket holds
+// 2^(depth-bucketDepth) chunks. Stamper counts issued stamps of every bucket
+// and persists counters in the store, so that the same index is never issued
+// twice.
+type Stamper struct {
+	batchID     []byte
+	depth       uint8
+	bucketDepth uint8
+	signer      crypto.Signer
+	store       StamperStore
+
+	counters map[uint32]uint32
+	max      uint32
+	lock     sync.Mutex
+}
+
+// NewStamper creates stamper of the batch owned by the key. Counters issued
+// before are loaded from the store.
+//
+//nolint:wrapcheck //relax
+func NewStamper(
+	batchID client.BatchID,
+	depth, bucketDepth uint8,
+	key *ecdsa.PrivateKey,
+	store StamperStore,
+) (*Stamper, error) {
+	id, err := hex.DecodeString(string(batchID))
+	if err != nil || len(id) != swarm.HashSize {
+		return nil, fmt.Errorf("%w: %s", errInvalidBatchID, batchID)
+	}
+
+	if bucketDepth == 0 || bucketDepth > 32 || depth < bucketDepth {
+		return nil, fmt.Errorf("%w: %d", errInvalidDepth, depth)
+	}
+
+	counters, err := store.Load(batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stamper{
+		batchID:     id,
+		depth:       depth,
+		bucketDepth: bucketDepth,
+		signer:      crypto.NewDefaultSigner(key),
+		store:       store,
+		counters:    counters,
+	}
+
+	for _, count := range counters {
+		if count > s.max {
+			s.max = count
+		}
+	}
+
+	return s, nil
+}
+
+// Stamp issues stamp of the chunk address. Returned stamp is sent along with
+// the chunk in Swarm-Postage-Stamp header.
+//
+//nolint:wrapcheck //relax
+func (s *Stamper) Stamp(addr swarm.Address) ([]byte, error) {
+	bucket := s.bucket(addr)
+
+	s.lock.Lock()
+
+	index := s.counters[bucket]
+	if index >= s.bucketCapacity() {
+		s.lock.Unlock()
+
+		return nil, ErrBucketFull
+	}
+
+	// Counter is saved before stamp is issued, so that index is not issued
+	// again after restart.
+	if err := s.store.Save(client.BatchID(hex.EncodeToString(s.batchID)), bucket, index+1); err != nil {
+		s.lock.Unlock()
+
+		return nil, err
+	}
+
+	s.counters[bucket] = index + 1
+	if index+1 > s.max {
+		s.max = index + 1
+	}
+
+	s.lock.Unlock()
+
+	stamp := make([]byte, 0, stamps.Size)
+	stamp = append(stamp, s.batchID...)
+	stamp = binary.BigEndian.AppendUint32(stamp, bucket)
+	stamp = binary.BigEndian.AppendUint32(stamp, index)
+	stamp = binary.BigEndian.AppendUint64(stamp, uint64(time.Now().UnixNano()))
+
+	digest, err := crypto.LegacyKeccak256(append(append([]byte{}, addr.Bytes()...), stamp...))
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := s.signer.Sign(digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed signing stamp: %w", err)
+	}
+
+	return append(stamp, sig...), nil
+}
+
+// Utilization returns number of stamps issued in the fullest bucket, which
+// is the same as utilization reported by Bee node.
+func (s *Stamper) Utilization() uint32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.max
+}
+
+func (s *Stamper) bucket(addr swarm.Address) uint32 {
+	return stamps.Bucket(addr, s.bucketDepth)
+}
+
+func (s *Stamper) bucketCapacity() uint32 {
+	if s.depth-s.bucketDepth >= 32 {
+		return math.MaxUint32
+	}
+
+	return 1 << (s.depth - s.bucketDepth)
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+//nolint:gochecknoglobals
+var errCorruptStamperState = errors.New("corrupt stamper state")
+
+// StamperStore persists counters of issued stamps of every bucket.
+type StamperStore interface {
+	// Load returns counters of all buckets of the batch, which have any
+	// stamps issued.
+	Load(batchID client.BatchID) (map[uint32]uint32, error)
+
+	// Save stores counter of the bucket.
+	Save(batchID client.BatchID, bucket, count uint32) error
+}
+
+// NewMemoryStamperStore creates StamperStore which keeps counters in memory,
+// so counters do not outlive the process.
+func NewMemoryStamperStore() StamperStore {
+	return &memoryStamperStore{
+		counters: make(map[client.BatchID]map[uint32]uint32),
+	}
+}
+
+type memoryStamperStore struct {
+	counters map[client.BatchID]map[uint32]uint32
+	lock     sync.Mutex
+}
+
+func (s *memoryStamperStore) Load(batchID client.BatchID) (map[uint32]uint32, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	counters := make(map[uint32]uint32, len(s.counters[batchID]))
+	for bucket, count := range s.counters[batchID] {
+		counters[bucket] = count
+	}
+
+	return counters, nil
+}
+
+func (s *memoryStamperStore) Save(batchID client.BatchID, bucket, count uint32) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.counters[batchID] == nil {
+		s.counters[batchID] = make(map[uint32]uint32)
+	}
+
+	s.counters[batchID][bucket] = count
+
+	return nil
+}
+
+// LevelDBStamperStore is StamperStore which keeps counters in LevelDB
+// database on local disk.
+type LevelDBStamperStore struct {
+	db *leveldb.DB
+}
+
+// NewLevelDBStamperStore opens LevelDB database at path.
+func NewLevelDBStamperStore(path string) (*LevelDBStamperStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening stamper store: %w", err)
+	}
+
+	return &LevelDBStamperStore{db: db}, nil
+}
+
+func (s *LevelDBStamperStore) Load(batchID client.BatchID) (map[uint32]uint32, error) {
+	counters := make(map[uint32]uint32)
+
+	it := s.db.NewIterator(util.BytesPrefix([]byte(batchID)), nil)
+	defer it.Release()
+
+	for it.Next() {
+		key, value := it.Key()[len(batchID):], it.Value()
+		if len(key) != 4 || len(value) != 4 {
+			return nil, errCorruptStamperState
+		}
+
+		counters[binary.BigEndian.Uint32(key)] = binary.BigEndian.Uint32(value)
+	}
+
+	if err := it.Error(); err != nil {
+		return nil, fmt.Errorf("failed loading stamper state: %w", err)
+	}
+
+	return counters, nil
+}
+
+// Save stores counter of the bucket. Write is synced to disk, because issuing
+// the same stamp index twice makes Bee node reject the chunk.
+func (s *LevelDBStamperStore) Save(batchID client.BatchID, bucket, count uint32) error {
+	key := binary.BigEndian.AppendUint32([]byte(batchID), bucket)
+	value := binary.BigEndian.AppendUint32(nil, count)
+
+	if err := s.db.Put(key, value, &opt.WriteOptions{Sync: true}); err != nil {
+		return fmt.Errorf("failed saving stamper state: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the database.
+func (s *LevelDBStamperStore) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("failed closing stamper store: %w", err)
+	}
+
+	return nil
+}
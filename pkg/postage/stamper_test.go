@@ -0,0 +1,188 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postage_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethersphere/bee/pkg/cac"
+	"github.com/ethersphere/bee/pkg/crypto"
+	"github.com/ethersphere/bee/pkg/swarm"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+	"github.com/ethersphere/eth-on-bzz/pkg/client/mock"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage"
+	"github.com/ethersphere/eth-on-bzz/pkg/postage/stamps"
+)
+
+func Test_Stamper(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	key, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	owner, err := client.OwnerFromKey(key)
+	assert.NoError(t, err)
+
+	beeCli := mock.NewClientWithOptions(mock.WithBatchOwner(owner))
+
+	resp, err := beeCli.BuyStamp(ctx, big.NewInt(1), 20, true, client.BuyStampOptions{})
+	assert.NoError(t, err)
+
+	stamper, err := postage.NewStamper(resp.BatchID, 20, 16, key, postage.NewMemoryStamperStore())
+	assert.NoError(t, err)
+
+	const chunks = 8
+
+	for i := 0; i < chunks; i++ {
+		ch, err := cac.New([]byte{byte(i)})
+		assert.NoError(t, err)
+
+		stamp, err := stamper.Stamp(ch.Address())
+		assert.NoError(t, err)
+		assert.Len(t, stamp, stamps.Size)
+		assert.Equal(t, resp.BatchID, stamps.BatchID(stamp))
+
+		bucket, _ := stamps.Index(stamp)
+		assert.Equal(t, stamps.Bucket(ch.Address(), 16), bucket)
+
+		uploadResp, err := beeCli.UploadStampedChunk(ctx, ch.Data(), stamp)
+		assert.NoError(t, err)
+		assert.Equal(t, ch.Address(), uploadResp.Reference)
+	}
+
+	// Node reports utilization of the fullest bucket, as the stamper does
+	assert.Equal(t, stamper.Utilization(), stampOf(t, beeCli, resp.BatchID).Utilization)
+
+	// Stamp of chunk from another bucket is rejected
+	ch, err := cac.New([]byte("chunk"))
+	assert.NoError(t, err)
+
+	other := chunkOfOtherBucket(t, ch.Address())
+
+	stamp, err := stamper.Stamp(other.Address())
+	assert.NoError(t, err)
+
+	_, err = beeCli.UploadStampedChunk(ctx, ch.Data(), stamp)
+	assert.ErrorIs(t, err, client.ErrStampUnusable)
+
+	// Stamp which is not signed by batch owner is rejected
+	otherKey, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	otherStamper, err := postage.NewStamper(resp.BatchID, 20, 16, otherKey, postage.NewMemoryStamperStore())
+	assert.NoError(t, err)
+
+	stamp, err = otherStamper.Stamp(ch.Address())
+	assert.NoError(t, err)
+
+	_, err = beeCli.UploadStampedChunk(ctx, ch.Data(), stamp)
+	assert.ErrorIs(t, err, client.ErrStampUnusable)
+
+	// Stamp with corrupted signature is rejected
+	stamp, err = stamper.Stamp(ch.Address())
+	assert.NoError(t, err)
+
+	stamp[len(stamp)-swarm.SocSignatureSize] ^= 0xff
+
+	_, err = beeCli.UploadStampedChunk(ctx, ch.Data(), stamp)
+	assert.ErrorIs(t, err, client.ErrStampUnusable)
+}
+
+func Test_StamperBucketFull(t *testing.T) {
+	t.Parallel()
+
+	key, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	// Every bucket holds 2 chunks
+	stamper, err := postage.NewStamper(testBatchID, 17, 16, key, postage.NewMemoryStamperStore())
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err := stamper.Stamp(bucketAddress(0xab, byte(i)))
+		assert.NoError(t, err)
+	}
+
+	_, err = stamper.Stamp(bucketAddress(0xab, 2))
+	assert.ErrorIs(t, err, postage.ErrBucketFull)
+	assert.ErrorIs(t, err, client.ErrStampUnusable)
+
+	// Other buckets are not affected
+	_, err = stamper.Stamp(bucketAddress(0xac, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), stamper.Utilization())
+}
+
+func Test_StamperPersistence(t *testing.T) {
+	t.Parallel()
+
+	key, err := crypto.GenerateSecp256k1Key()
+	assert.NoError(t, err)
+
+	path := t.TempDir()
+
+	store, err := postage.NewLevelDBStamperStore(path)
+	assert.NoError(t, err)
+
+	stamper, err := postage.NewStamper(testBatchID, 20, 16, key, store)
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := stamper.Stamp(bucketAddress(0x01, byte(i)))
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, store.Close())
+
+	// Reopened stamper continues with the next index
+	store, err = postage.NewLevelDBStamperStore(path)
+	assert.NoError(t, err)
+
+	stamper, err = postage.NewStamper(testBatchID, 20, 16, key, store)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(3), stamper.Utilization())
+
+	stamp, err := stamper.Stamp(bucketAddress(0x01, 3))
+	assert.NoError(t, err)
+
+	_, index := stamps.Index(stamp)
+	assert.Equal(t, uint32(3), index)
+
+	assert.NoError(t, store.Close())
+}
+
+const testBatchID = client.BatchID("0000000000000000000000000000000000000000000000000000000000000001")
+
+// chunkOfOtherBucket returns chunk whose address falls into other bucket than
+// the address.
+func chunkOfOtherBucket(t *testing.T, addr swarm.Address) swarm.Chunk {
+	t.Helper()
+
+	for i := 0; ; i++ {
+		ch, err := cac.New([]byte{byte(i)})
+		assert.NoError(t, err)
+
+		if stamps.Bucket(ch.Address(), 16) != stamps.Bucket(addr, 16) {
+			return ch
+		}
+	}
+}
+
+// bucketAddress returns address which falls into bucket given by the first
+// two bytes of the address.
+func bucketAddress(bucket, n byte) swarm.Address {
+	addr := make([]byte, swarm.HashSize)
+	addr[0] = bucket
+	addr[1] = bucket
+	addr[swarm.HashSize-1] = n
+
+	return swarm.NewAddress(addr)
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package stamps decodes postage stamps and computes buckets and number of
+// stamps that data consumes, so that postage and test doubles of the client
+// account batch usage the same way.
+package stamps
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/ethersphere/bee/pkg/swarm"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/client"
+)
+
+const (
+	// Size is size of serialized stamp, which is batch id, index, timestamp
+	// and signature.
+	Size = swarm.HashSize + indexSize + timestampSize + swarm.SocSignatureSize
+
+	indexSize     = 8
+	timestampSize = 8
+)
+
+// Bucket returns bucket which chunk address falls into, which is given by
+// leading bucketDepth bits of the address.
+func Bucket(addr swarm.Address, bucketDepth uint8) uint32 {
+	return binary.BigEndian.Uint32(addr.Bytes()[:4]) >> (32 - uint32(bucketDepth))
+}
+
+// BatchID returns id of the batch which issued the stamp.
+func BatchID(stamp []byte) client.BatchID {
+	if len(stamp) != Size {
+		return client.BatchID("")
+	}
+
+	return client.BatchID(hex.EncodeToString(stamp[:swarm.HashSize]))
+}
+
+// Index returns bucket and index within the bucket of the stamp.
+func Index(stamp []byte) (uint32, uint32) {
+	if len(stamp) != Size {
+		return 0, 0
+	}
+
+	data := stamp[swarm.HashSize:]
+
+	return binary.BigEndian.Uint32(data), binary.BigEndian.Uint32(data[4:])
+}
+
+// ChunkCount returns number of chunks which data of the size is split into
+// by Swarm, including intermediate chunks of the tree. Lone reference left
+// over at the end of a level is carried up to the next level, without being
+// wrapped in an intermediate chunk.
+func ChunkCount(size int) int {
+	refs := (size + swarm.ChunkSize - 1) / swarm.ChunkSize
+	if refs == 0 {
+		refs = 1
+	}
+
+	total := refs
+	for refs > 1 {
+		full, rest := refs/swarm.Branches, refs%swarm.Branches
+
+		total += full
+		if rest > 1 {
+			total++
+		}
+
+		refs = full
+		if rest > 0 {
+			refs++
+		}
+	}
+
+	return total
+}
@@ -0,0 +1,36 @@
+// Copyright 2023 The Swarm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package stamps_test
+
+import (
+	"testing"
+
+	"github.com/ethersphere/bee/pkg/swarm"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethersphere/eth-on-bzz/pkg/postage/stamps"
+)
+
+func Test_ChunkCount(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		size int
+		want int
+	}{
+		{size: 0, want: 1},
+		{size: swarm.ChunkSize, want: 1},
+		{size: swarm.ChunkSize + 1, want: 3},
+		{size: swarm.Branches * swarm.ChunkSize, want: swarm.Branches + 1},
+		// Last chunk is carried up and referenced by the root together with
+		// the full intermediate chunk.
+		{size: (swarm.Branches + 1) * swarm.ChunkSize, want: swarm.Branches + 1 + 1 + 1},
+		{size: (swarm.Branches + 2) * swarm.ChunkSize, want: swarm.Branches + 2 + 2 + 1},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, stamps.ChunkCount(tc.size))
+	}
+}